@@ -0,0 +1,44 @@
+// Временные образцы цифр для аудиозаданий: пока записей дикторов нет, цифры
+// произносятся формантным синтезом (internal/audio/synth) несколькими голосами
+// и сохраняются в internal/audio/samples как <цифра>-synth<n>.wav. Записанные
+// образцы кладутся туда же под своими именами, а временные после этого удаляются.
+//
+//	go run ./cmd/audiosamples -out internal/audio/samples
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/theborzet/captcha_service/internal/audio/synth"
+)
+
+// voices — голоса временных образцов, от низкого мужского до высокого женского
+var voices = []synth.Voice{
+	{Pitch: 100, Tract: 0.96, Tempo: 1.05},
+	{Pitch: 135, Tract: 1.02, Tempo: 0.9},
+	{Pitch: 170, Tract: 1.1, Tempo: 1},
+	{Pitch: 205, Tract: 1.15, Tempo: 0.95},
+}
+
+func main() {
+	out := flag.String("out", "internal/audio/samples", "directory to write the samples to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for n, v := range voices {
+		for d := 0; d < 10; d++ {
+			name := filepath.Join(*out, fmt.Sprintf("%d-synth%d.wav", d, n+1))
+			if err := os.WriteFile(name, synth.Digit(d, v).WAV(), 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+	fmt.Printf("wrote %d samples to %s\n", len(voices)*10, *out)
+}
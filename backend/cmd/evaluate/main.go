@@ -0,0 +1,273 @@
+// Оценка проверки на размеченном корпусе записанных сессий: каждая сессия
+// повторяется текущей версией сервиса (internal/replay), и по разбору уверенности
+// на каждом этапе считаются ROC, AUC, точность и полнота при нынешнем пороге
+// и предлагаемые пороги — отдельно по типу задания и complexity. Оцениваются
+// итоговая уверенность и каждый признак, который видит модель оценки, поэтому
+// кандидатную модель (-model) можно сравнить со встроенной формулой до выкатки.
+//
+// Метка задаётся для файла записи целиком: human=путь или bot=путь.
+//
+//	go run ./cmd/evaluate -roc roc.csv human=people.rec bot=redteam.rec
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/replay"
+	"github.com/theborzet/captcha_service/internal/risk"
+)
+
+// feature — признак этапа, по которому можно отделять людей от ботов
+type feature struct {
+	name string
+	// pass — признак сравнивается с risk.PassThreshold, как итоговая уверенность
+	pass bool
+}
+
+// value — значение признака на этапе; признака может не быть, если он на этапе не определён
+func (f feature) value(v challenge.Verdict) (float64, bool) {
+	if f.name == "confidence" {
+		return float64(v.Confidence), true
+	}
+	x, ok := v.Features[f.name]
+	return x, ok
+}
+
+// groupFeatures — итоговая уверенность и все признаки модели, встретившиеся в группе
+func groupFeatures(g *group) []feature {
+	seen := make(map[string]bool)
+	for _, v := range g.verdicts {
+		for name := range v.Features {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	features := []feature{{name: "confidence", pass: true}}
+	for _, name := range names {
+		features = append(features, feature{name: name, pass: name == "raw"})
+	}
+	return features
+}
+
+type groupKey struct {
+	kind       string
+	complexity int
+}
+
+// group — этапы одного типа и complexity
+type group struct {
+	verdicts []challenge.Verdict
+	humans   []bool
+	// сессии, в которых этап так и не был завершён
+	unfinishedHumans, unfinishedBots int
+}
+
+type options struct {
+	kind       string
+	maxBotPass float64
+	minSamples int
+	rocPath    string
+	model      string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.kind, "type", "", "evaluate only this challenge type")
+	flag.Float64Var(&opts.maxBotPass, "max-bot-pass", 0.01, "share of bots a suggested threshold may let through")
+	flag.IntVar(&opts.minSamples, "min-samples", 10, "skip groups with fewer human or bot stages")
+	flag.StringVar(&opts.rocPath, "roc", "", "write ROC points of every group and feature to this CSV file")
+	flag.StringVar(&opts.model, "model", "", "score stages with this model file instead of the built-in formula")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: evaluate [flags] human=recording|bot=recording...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	groups, err := load(flag.Args(), opts.kind, opts.model)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	keys := make([]groupKey, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].complexity < keys[j].complexity
+	})
+
+	var roc *csv.Writer
+	if opts.rocPath != "" {
+		f, err := os.Create(opts.rocPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		roc = csv.NewWriter(f)
+		defer roc.Flush()
+		roc.Write([]string{"type", "complexity", "feature", "pass_if", "threshold", "human_pass", "bot_pass"})
+	}
+
+	for _, k := range keys {
+		g := groups[k]
+		features := groupFeatures(g)
+		curves := make([]curve, len(features))
+		for i, f := range features {
+			samples := make([]sample, 0, len(g.verdicts))
+			for j, v := range g.verdicts {
+				if x, ok := f.value(v); ok {
+					samples = append(samples, sample{score: x, human: g.humans[j]})
+				}
+			}
+			curves[i] = rocCurve(samples)
+		}
+		printGroup(k, g, features, curves, opts)
+		if roc != nil {
+			writeROC(roc, k, features, curves)
+		}
+	}
+}
+
+// load повторяет сессии всех файлов и раскладывает этапы по группам
+func load(args []string, kind, model string) (map[groupKey]*group, error) {
+	replayer, err := replay.New(model)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[groupKey]*group)
+	get := func(k groupKey) *group {
+		g := groups[k]
+		if g == nil {
+			g = &group{}
+			groups[k] = g
+		}
+		return g
+	}
+	for _, arg := range args {
+		label, path, ok := strings.Cut(arg, "=")
+		if !ok || (label != "human" && label != "bot") {
+			return nil, fmt.Errorf("%q: expected human=recording or bot=recording", arg)
+		}
+		human := label == "human"
+		failed := 0
+		err := recording.ReadFile(path, func(s *recording.Session) {
+			res, err := replayer.Run(s)
+			if err != nil || res.Session == nil {
+				failed++
+				return
+			}
+			verdicts := res.Session.Verdicts()
+			for _, v := range verdicts {
+				if kind != "" && v.Type != kind {
+					continue
+				}
+				g := get(groupKey{v.Type, v.Complexity})
+				g.verdicts = append(g.verdicts, v)
+				g.humans = append(g.humans, human)
+			}
+			// Сессия оборвалась на этапе, который так и не был завершён
+			if res.Confidence == recording.NoResult && (kind == "" || s.Type == kind) {
+				g := get(groupKey{s.Type, s.Complexity})
+				if len(verdicts) > 0 {
+					last := verdicts[len(verdicts)-1]
+					g = get(groupKey{last.Type, last.Complexity})
+				}
+				if human {
+					g.unfinishedHumans++
+				} else {
+					g.unfinishedBots++
+				}
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %d sessions could not be replayed\n", path, failed)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("no finished stages in the corpus")
+	}
+	return groups, nil
+}
+
+func printGroup(k groupKey, g *group, features []feature, curves []curve, opts options) {
+	c0 := curves[0]
+	fmt.Printf("# %s, complexity %d: %d human and %d bot stages, unfinished %d human and %d bot\n",
+		k.kind, k.complexity, c0.humans, c0.bots, g.unfinishedHumans, g.unfinishedBots)
+	if c0.humans < opts.minSamples || c0.bots < opts.minSamples {
+		fmt.Printf("  too few stages, need %d of each\n\n", opts.minSamples)
+		return
+	}
+	fmt.Printf("  %-20s %5s %4s  %13s %6s  %10s %5s %4s  %15s %5s\n",
+		"feature", "auc", "pass", fmt.Sprintf("prec at %d", risk.PassThreshold), "recall",
+		"best thr", "human", "bot", fmt.Sprintf("thr bots<=%g%%", opts.maxBotPass*100), "human")
+	for i, f := range features {
+		c := curves[i]
+		dir := ">="
+		if c.lower {
+			dir = "<="
+		}
+		current := strings.Repeat(" ", 20)
+		if f.pass {
+			precision, recall := c.at(risk.PassThreshold)
+			current = fmt.Sprintf("%13.2f %6.2f", precision, recall)
+		}
+		best := fmt.Sprintf("%21s", "-")
+		if p, ok := c.youden(); ok {
+			best = fmt.Sprintf("%10s %5.2f %4.2f", formatThreshold(p.threshold), p.tpr, p.fpr)
+		}
+		strict := fmt.Sprintf("%15s %5s", "-", "")
+		if p, ok := c.forBotRate(opts.maxBotPass); ok {
+			strict = fmt.Sprintf("%15s %5.2f", formatThreshold(p.threshold), p.tpr)
+		}
+		if c.humans < opts.minSamples || c.bots < opts.minSamples {
+			fmt.Printf("  %-20s %5s  defined on %d human and %d bot stages\n", f.name, "-", c.humans, c.bots)
+			continue
+		}
+		fmt.Printf("  %-20s %5.3f %4s  %s  %s  %s\n", f.name, c.auc, dir, current, best, strict)
+	}
+	fmt.Println()
+}
+
+func writeROC(w *csv.Writer, k groupKey, features []feature, curves []curve) {
+	for i, f := range features {
+		c := curves[i]
+		dir := ">="
+		if c.lower {
+			dir = "<="
+		}
+		for _, p := range c.points {
+			w.Write([]string{
+				k.kind, strconv.Itoa(k.complexity), f.name, dir, formatThreshold(p.threshold),
+				strconv.FormatFloat(p.tpr, 'f', 4, 64), strconv.FormatFloat(p.fpr, 'f', 4, 64),
+			})
+		}
+	}
+}
+
+func formatThreshold(t float64) string {
+	return strconv.FormatFloat(t, 'f', -1, 64)
+}
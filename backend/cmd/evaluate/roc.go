@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// sample — значение признака на одном этапе и метка, человек ли его проходил
+type sample struct {
+	score float64
+	human bool
+}
+
+// point — точка ROC: доля пропущенных людей и ботов при пороге score >= threshold
+type point struct {
+	threshold float64
+	tpr, fpr  float64
+}
+
+// curve — ROC признака. Если люди набирают по признаку меньше ботов (AUC < 0.5),
+// кривая строится по перевёрнутому признаку и lower выставляется: пропуск — score <= threshold.
+type curve struct {
+	points       []point // от самого строгого порога к самому мягкому
+	auc          float64
+	lower        bool
+	humans, bots int
+	values       []sample
+}
+
+func rocCurve(samples []sample) curve {
+	c := curve{values: samples}
+	for _, s := range samples {
+		if s.human {
+			c.humans++
+		} else {
+			c.bots++
+		}
+	}
+	if c.humans == 0 || c.bots == 0 {
+		return c
+	}
+	c.auc = auc(samples, 1)
+	sign := 1.0
+	if c.auc < 0.5 {
+		c.auc, c.lower, sign = 1-c.auc, true, -1
+	}
+
+	sorted := append([]sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sign*sorted[i].score > sign*sorted[j].score })
+	tp, fp := 0, 0
+	for i := 0; i < len(sorted); {
+		t := sorted[i].score
+		for ; i < len(sorted) && sorted[i].score == t; i++ {
+			if sorted[i].human {
+				tp++
+			} else {
+				fp++
+			}
+		}
+		c.points = append(c.points, point{threshold: t, tpr: float64(tp) / float64(c.humans), fpr: float64(fp) / float64(c.bots)})
+	}
+	return c
+}
+
+// auc — вероятность, что у случайного человека признак выше, чем у случайного
+// бота (статистика Манна — Уитни), ничьи идут пополам
+func auc(samples []sample, sign float64) float64 {
+	sorted := append([]sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sign*sorted[i].score < sign*sorted[j].score })
+	var humans, bots, rankSum float64
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].score == sorted[i].score {
+			j++
+		}
+		// Средний ранг группы одинаковых значений, ранги с 1
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if sorted[k].human {
+				humans++
+				rankSum += rank
+			} else {
+				bots++
+			}
+		}
+		i = j
+	}
+	if humans == 0 || bots == 0 {
+		return math.NaN()
+	}
+	return (rankSum - humans*(humans+1)/2) / (humans * bots)
+}
+
+// at — точность и полнота пропуска людей при пороге threshold в исходном
+// направлении признака (score >= threshold)
+func (c curve) at(threshold float64) (precision, recall float64) {
+	var tp, fp int
+	for _, s := range c.values {
+		if s.score >= threshold {
+			if s.human {
+				tp++
+			} else {
+				fp++
+			}
+		}
+	}
+	if tp+fp > 0 {
+		precision = float64(tp) / float64(tp+fp)
+	}
+	if c.humans > 0 {
+		recall = float64(tp) / float64(c.humans)
+	}
+	return precision, recall
+}
+
+// youden — порог, при котором доля пропущенных людей больше всего отрывается
+// от доли пропущенных ботов
+func (c curve) youden() (point, bool) {
+	var best point
+	found := false
+	for _, p := range c.points {
+		if !found || p.tpr-p.fpr > best.tpr-best.fpr {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// forBotRate — самый мягкий порог, при котором проходит не больше maxFPR ботов
+func (c curve) forBotRate(maxFPR float64) (point, bool) {
+	var best point
+	found := false
+	for _, p := range c.points {
+		if p.fpr > maxFPR {
+			break
+		}
+		best, found = p, true
+	}
+	return best, found
+}
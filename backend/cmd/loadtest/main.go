@@ -0,0 +1,634 @@
+// Нагрузочный тест инстансов капчи: выдаёт задания через NewChallenge, по желанию
+// решает их через MakeEventStream и гасит полученные пропуски через VerifyToken,
+// а затем печатает пропускную способность, перцентили задержек, ошибки и память
+// сервера по его /metrics.
+//
+//	go run ./cmd/loadtest -targets localhost:39001=3,localhost:39002 -concurrency 50 -duration 30s
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/harness"
+	"github.com/theborzet/captcha_service/internal/risk"
+	"github.com/theborzet/captcha_service/internal/solver"
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Поведение клиента после получения задания
+const (
+	solveNone   = "none"   // только выдать задание, оно живёт в хранилище до истечения срока
+	solveReady  = "ready"  // открыть стрим и отправить ready, как это делает загруженный iframe
+	solveSubmit = "submit" // отправить заведомо неверный ответ и дождаться результата
+	solveBot    = "bot"    // ответить ботом из internal/solver и погасить полученный пропуск
+)
+
+// loadBot отвечает так же, как scraper из red team, но без человеческих пауз:
+// нагрузке нужна скорость ответов, а не их правдоподобие. Проходит он в основном
+// PoW, и именно по нему в нагрузку попадают выдача и проверка пропусков.
+var loadBot = solver.Style{Forge: true, Informed: true}
+
+type config struct {
+	targets      []target
+	concurrency  int
+	duration     time.Duration
+	requests     int
+	complexity   int
+	accessible   float64
+	clientKeys   int
+	solve        string
+	verifySecret string // секрет сервера сайта для VerifyToken в режиме bot
+	hold         time.Duration
+	timeout      time.Duration
+	metricsURL   string
+}
+
+// target — инстанс капчи и его доля в общем потоке запросов
+type target struct {
+	addr   string
+	weight int
+	client pb.CaptchaServiceClient
+}
+
+func main() {
+	cfg, err := parseFlags()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	for i := range cfg.targets {
+		conn, err := grpc.NewClient(cfg.targets[i].addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to connect to %s: %v\n", cfg.targets[i].addr, err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+		cfg.targets[i].client = pb.NewCaptchaServiceClient(conn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cfg.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+
+	var before, peak sample
+	var sampler sync.WaitGroup
+	samplerCtx, stopSampler := context.WithCancel(context.Background())
+	if cfg.metricsURL != "" {
+		if before, err = scrape(cfg.metricsURL); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to scrape metrics: %v\n", err)
+		}
+		sampler.Add(1)
+		go func() {
+			defer sampler.Done()
+			peak = watch(samplerCtx, cfg.metricsURL)
+		}()
+	}
+
+	stats := newStats()
+	start := time.Now()
+	run(ctx, cfg, stats)
+	elapsed := time.Since(start)
+
+	stopSampler()
+	sampler.Wait()
+	var after sample
+	if cfg.metricsURL != "" {
+		if after, err = scrape(cfg.metricsURL); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to scrape metrics: %v\n", err)
+		}
+	}
+
+	stats.report(os.Stdout, cfg, elapsed)
+	if cfg.metricsURL != "" {
+		reportMetrics(os.Stdout, before, peak, after)
+	}
+}
+
+func parseFlags() (*config, error) {
+	cfg := &config{}
+	targets := flag.String("targets", "localhost:39001", "gRPC addresses of captcha instances, host:port[=weight],...")
+	flag.IntVar(&cfg.concurrency, "concurrency", 50, "number of concurrent clients")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "test duration, 0 to rely on -requests")
+	flag.IntVar(&cfg.requests, "requests", 0, "total number of challenges to request, 0 for unlimited")
+	flag.IntVar(&cfg.complexity, "complexity", 1, "requested complexity, 0-100")
+	flag.Float64Var(&cfg.accessible, "accessible", 0, "share of requests asking for the accessible challenge, 0..1")
+	flag.IntVar(&cfg.clientKeys, "client-keys", 0, "number of distinct client keys for risk profiling, 0 to send none")
+	flag.StringVar(&cfg.solve, "solve", solveSubmit, "client behaviour: none, ready, submit or bot")
+	flag.StringVar(&cfg.verifySecret, "verify-secret", "", "verify secret of the default site, sent with VerifyToken in bot mode")
+	flag.DurationVar(&cfg.hold, "hold", 0, "how long a client keeps the challenge before solving it")
+	flag.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "timeout of a single request or stream")
+	flag.StringVar(&cfg.metricsURL, "metrics", "", "server metrics endpoint, e.g. http://localhost:8080/metrics")
+	flag.Parse()
+
+	for _, spec := range strings.Split(*targets, ",") {
+		addr, w, found := strings.Cut(strings.TrimSpace(spec), "=")
+		weight := 1
+		if found {
+			n, err := strconv.Atoi(w)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid weight in target %q", spec)
+			}
+			weight = n
+		}
+		if addr == "" {
+			return nil, fmt.Errorf("empty target in %q", *targets)
+		}
+		cfg.targets = append(cfg.targets, target{addr: addr, weight: weight})
+	}
+	switch {
+	case cfg.solve != solveNone && cfg.solve != solveReady && cfg.solve != solveSubmit && cfg.solve != solveBot:
+		return nil, fmt.Errorf("unknown -solve %q", cfg.solve)
+	case cfg.concurrency <= 0:
+		return nil, errors.New("-concurrency must be positive")
+	case cfg.duration <= 0 && cfg.requests <= 0:
+		return nil, errors.New("either -duration or -requests is required")
+	}
+	return cfg, nil
+}
+
+// pick выбирает инстанс пропорционально весам
+func (c *config) pick() *target {
+	total := 0
+	for _, t := range c.targets {
+		total += t.weight
+	}
+	n := rand.Intn(total)
+	for i := range c.targets {
+		if n -= c.targets[i].weight; n < 0 {
+			return &c.targets[i]
+		}
+	}
+	return &c.targets[len(c.targets)-1]
+}
+
+// run гоняет concurrency клиентов, пока не истечёт время или не кончатся запросы
+func run(ctx context.Context, cfg *config, stats *stats) {
+	var (
+		wg     sync.WaitGroup
+		issued sync.Mutex
+		left   = cfg.requests
+	)
+	next := func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if cfg.requests == 0 {
+			return true
+		}
+		issued.Lock()
+		defer issued.Unlock()
+		left--
+		return left >= 0
+	}
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next() {
+				session(ctx, cfg, stats)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// session — один пользователь: получает задание и ведёт себя согласно -solve
+func session(ctx context.Context, cfg *config, stats *stats) {
+	t := cfg.pick()
+	req := &pb.ChallengeRequest{
+		Complexity: int32(cfg.complexity),
+		Accessible: rand.Float64() < cfg.accessible,
+	}
+	if cfg.clientKeys > 0 {
+		req.ClientKey = fmt.Sprintf("loadtest-%d", rand.Intn(cfg.clientKeys))
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	started := time.Now()
+	resp, err := t.client.NewChallenge(reqCtx, req)
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			stats.fail("new_challenge", err)
+		}
+		return
+	}
+	stats.issued(resp.ChallengeType, time.Since(started), len(resp.Html))
+
+	if cfg.solve == solveNone {
+		return
+	}
+	page, err := harness.Parse(resp.ChallengeId, resp.ChallengeType, resp.Html)
+	if err != nil {
+		stats.fail("parse", err)
+		return
+	}
+	if cfg.hold > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.hold):
+		}
+	}
+
+	// Стрим доживает до результата, даже если время теста уже вышло
+	streamCtx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+	if cfg.solve == solveBot {
+		err = solveByBot(streamCtx, t.client, page, cfg.verifySecret, stats)
+	} else {
+		err = solve(streamCtx, t.client, page, cfg.solve, stats)
+	}
+	if err != nil {
+		stats.fail("stream", err)
+	}
+}
+
+// solveByBot проходит задание и цепочку ботом loadBot, а пропуск за пройденную
+// капчу гасит через VerifyToken, как это сделал бы сервер сайта
+func solveByBot(ctx context.Context, client pb.CaptchaServiceClient, page *harness.Page, secret string, stats *stats) error {
+	started := time.Now()
+	s, err := harness.Stream(ctx, client, page)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.ForgeProbes = loadBot.Forge
+
+	res, err := solver.Solve(ctx, s, loadBot)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(res.ChallengeID, "error:") {
+		return errors.New(res.ChallengeID)
+	}
+	stats.solved(time.Since(started), res.Confidence)
+	if res.Token == "" {
+		return nil
+	}
+
+	started = time.Now()
+	resp, err := client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: res.Token, Secret: secret})
+	if err != nil {
+		stats.fail("verify", err)
+		return nil
+	}
+	stats.verified(time.Since(started), resp.Success)
+	return nil
+}
+
+// solve открывает стрим задания, отправляет ready и, для submit, неверный ответ,
+// проходя все этапы цепочки до итогового результата
+func solve(ctx context.Context, client pb.CaptchaServiceClient, page *harness.Page, mode string, stats *stats) error {
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.CloseSend()
+
+	events := make(chan *pb.ServerEvent, 16)
+	go func() {
+		defer close(events)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	send := func(e harness.Event) error {
+		return stream.Send(&pb.ClientEvent{
+			EventType:   pb.ClientEvent_FRONTEND_EVENT,
+			ChallengeId: page.ChallengeID,
+			Data:        page.Encode(e),
+		})
+	}
+	defer stream.Send(&pb.ClientEvent{EventType: pb.ClientEvent_CONNECTION_CLOSED, ChallengeId: page.ChallengeID})
+
+	if err := send(harness.Event{Name: "ready"}); err != nil {
+		return err
+	}
+	if mode == solveReady {
+		return nil
+	}
+
+	for {
+		started := time.Now()
+		answer := junkAnswers[page.Type]
+		if answer == nil {
+			return fmt.Errorf("no answer for challenge type %q", page.Type)
+		}
+		var (
+			result *pb.ServerEvent
+			err    error
+		)
+		for _, e := range answer {
+			if err = send(e); err != nil {
+				return err
+			}
+			// Задание может завершиться раньше, чем кончатся события ответа
+			if result, err = await(ctx, events, time.Millisecond); result != nil || err != nil {
+				break
+			}
+		}
+		if result == nil && err == nil {
+			result, err = await(ctx, events, 0)
+		}
+		if err != nil {
+			return err
+		}
+
+		if stage := result.GetStage(); stage != nil {
+			stats.stage(time.Since(started))
+			next, err := harness.Parse(stage.ChallengeId, stage.ChallengeType, stage.Html)
+			if err != nil {
+				return err
+			}
+			page = next
+			continue
+		}
+		r := result.GetResult()
+		if strings.HasPrefix(r.ChallengeId, "error:") {
+			return errors.New(r.ChallengeId)
+		}
+		stats.solved(time.Since(started), int(r.ConfidencePercent))
+		return nil
+	}
+}
+
+// await ждёт результат или следующий этап. wait > 0 ограничивает ожидание,
+// и тогда его истечение — не ошибка: ответ ещё не закончен.
+func await(ctx context.Context, events <-chan *pb.ServerEvent, wait time.Duration) (*pb.ServerEvent, error) {
+	var expired <-chan time.Time
+	if wait > 0 {
+		expired = time.After(wait)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, nil
+		case ev, ok := <-events:
+			if !ok {
+				return nil, errors.New("stream closed before result")
+			}
+			// Проверки окружения и обновления задания нагрузочному клиенту не нужны
+			if ev.GetResult() != nil || ev.GetStage() != nil {
+				return ev, nil
+			}
+		}
+	}
+}
+
+// junkAnswers — события, которые завершают задание каждого типа неверным ответом.
+// Сервер проверяет их полностью, поэтому нагрузка та же, что и от настоящих ответов.
+var junkAnswers = map[string][]harness.Event{
+	"drag-drop-v1":      {{Name: "drag_start"}, {Name: "drag_drop", X: 1, Y: 1, Success: true}},
+	"pow-v1":            {{Name: "pow", Data: "0"}},
+	"pow-memory-v1":     {{Name: "pow", Data: "0"}},
+	"click-sequence-v1": clickMisses(),
+	"rotate-v1":         {{Name: "rotate_start"}, {Name: "rotate"}},
+	"odd-one-out-v1":    oddOneTaps(),
+	"maze-v1":           {{Name: "trace_end"}},
+	"audio-v1":          {{Name: "audio_answer", Data: "0"}},
+	"rhythm-v1":         {{Name: "rhythm_start"}, {Name: "rhythm_end"}},
+}
+
+// clickMisses — клики мимо знаков, их хватает на самую длинную последовательность
+func clickMisses() []harness.Event {
+	clicks := make([]harness.Event, 5)
+	for i := range clicks {
+		clicks[i] = harness.Event{Name: "click", X: 1, Y: 1}
+	}
+	return clicks
+}
+
+// oddOneTaps обходит сетку нажатиями, пока сервер не завершит задание по ошибкам
+// или отмеченным плиткам; точки попадают в разные плитки любой сетки до 4x4
+func oddOneTaps() []harness.Event {
+	var taps []harness.Event
+	for round := 0; round < 3; round++ {
+		for row := 0; row < 4; row++ {
+			for col := 0; col < 4; col++ {
+				taps = append(taps, harness.Event{Name: "tap", X: 30 + col*60, Y: 30 + row*60})
+			}
+		}
+	}
+	return taps
+}
+
+// stats собирает результаты всех клиентов
+type stats struct {
+	mu         sync.Mutex
+	newLatency []time.Duration
+	solveTime  []time.Duration
+	stageTime  []time.Duration
+	verifyTime []time.Duration
+	htmlBytes  int64
+	types      map[string]int
+	errors     map[string]int
+	lastError  map[string]string
+	passed     int
+	redeemed   int
+}
+
+func newStats() *stats {
+	return &stats{types: make(map[string]int), errors: make(map[string]int), lastError: make(map[string]string)}
+}
+
+func (s *stats) issued(kind string, latency time.Duration, size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.newLatency = append(s.newLatency, latency)
+	s.htmlBytes += int64(size)
+	s.types[kind]++
+}
+
+func (s *stats) stage(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stageTime = append(s.stageTime, latency)
+}
+
+func (s *stats) solved(latency time.Duration, confidence int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.solveTime = append(s.solveTime, latency)
+	if confidence >= risk.PassThreshold {
+		s.passed++
+	}
+}
+
+func (s *stats) verified(latency time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifyTime = append(s.verifyTime, latency)
+	if ok {
+		s.redeemed++
+	}
+}
+
+func (s *stats) fail(stage string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[stage]++
+	s.lastError[stage] = err.Error()
+}
+
+func (s *stats) report(w *os.File, cfg *config, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issued := len(s.newLatency)
+	failed := 0
+	for _, n := range s.errors {
+		failed += n
+	}
+	fmt.Fprintf(w, "duration       %v, concurrency %d, solve=%s\n", elapsed.Round(time.Millisecond), cfg.concurrency, cfg.solve)
+	fmt.Fprintf(w, "challenges     %d issued, %.1f rps\n", issued, float64(issued)/elapsed.Seconds())
+	if issued > 0 {
+		fmt.Fprintf(w, "html size      %d bytes avg\n", s.htmlBytes/int64(issued))
+	}
+	fmt.Fprintf(w, "errors         %d (%.2f%%)\n", failed, 100*float64(failed)/float64(max(1, issued+s.errors["new_challenge"])))
+	for _, stage := range sortedKeys(s.errors) {
+		fmt.Fprintf(w, "  %-12s %d, last: %s\n", stage, s.errors[stage], s.lastError[stage])
+	}
+	fmt.Fprintln(w, "types")
+	for _, kind := range sortedKeys(s.types) {
+		fmt.Fprintf(w, "  %-18s %d\n", kind, s.types[kind])
+	}
+	printLatency(w, "new_challenge", s.newLatency)
+	if len(s.stageTime) > 0 {
+		printLatency(w, "next_stage", s.stageTime)
+	}
+	if len(s.solveTime) > 0 {
+		printLatency(w, "result", s.solveTime)
+		fmt.Fprintf(w, "results        %d, %.1f rps, %d passed\n", len(s.solveTime), float64(len(s.solveTime))/elapsed.Seconds(), s.passed)
+	}
+	if len(s.verifyTime) > 0 {
+		printLatency(w, "verify_token", s.verifyTime)
+		fmt.Fprintf(w, "pass tokens    %d verified, %d redeemed\n", len(s.verifyTime), s.redeemed)
+	}
+}
+
+func printLatency(w *os.File, name string, d []time.Duration) {
+	if len(d) == 0 {
+		return
+	}
+	slices.Sort(d)
+	at := func(q float64) time.Duration {
+		return d[min(len(d)-1, int(q*float64(len(d))))].Round(10 * time.Microsecond)
+	}
+	fmt.Fprintf(w, "%-14s p50 %v  p90 %v  p99 %v  max %v\n", name, at(0.5), at(0.9), at(0.99), d[len(d)-1].Round(10*time.Microsecond))
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// sample — метрики сервера, важные для теста; значения с метками суммируются
+type sample map[string]float64
+
+var watched = []string{
+	"captcha_active_challenges",
+	"go_memstats_heap_inuse_bytes",
+	"go_memstats_sys_bytes",
+	"go_goroutines",
+}
+
+func scrape(url string) (sample, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics endpoint returned %s", resp.Status)
+	}
+
+	s := make(sample)
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			continue
+		}
+		name := line[:i]
+		if j := strings.IndexByte(name, '{'); j >= 0 {
+			name = name[:j]
+		}
+		s[name] += value
+	}
+	return s, sc.Err()
+}
+
+// watch раз в секунду снимает метрики и запоминает максимум каждой за время теста
+func watch(ctx context.Context, url string) sample {
+	peak := make(sample)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return peak
+		case <-tick.C:
+		}
+		s, err := scrape(url)
+		if err != nil {
+			continue
+		}
+		for name, v := range s {
+			peak[name] = max(peak[name], v)
+		}
+	}
+}
+
+func reportMetrics(w *os.File, before, peak, after sample) {
+	fmt.Fprintf(w, "%-30s %14s %14s %14s\n", "server", "before", "peak", "after")
+	for _, name := range watched {
+		format := func(v float64) string {
+			if strings.HasSuffix(name, "_bytes") {
+				return fmt.Sprintf("%.1f MiB", v/(1<<20))
+			}
+			return strconv.FormatFloat(v, 'f', 0, 64)
+		}
+		fmt.Fprintf(w, "%-30s %14s %14s %14s\n", name, format(before[name]), format(peak[name]), format(after[name]))
+	}
+	if active := peak["captcha_active_challenges"] - before["captcha_active_challenges"]; active > 0 {
+		fmt.Fprintf(w, "heap per active challenge       %.1f KiB\n", (peak["go_memstats_heap_inuse_bytes"]-before["go_memstats_heap_inuse_bytes"])/active/1024)
+	}
+}
@@ -0,0 +1,375 @@
+// Red team капчи: набор ботов проходит задания через /captcha и WebSocket,
+// как это делала бы страница в браузере, и печатает, какую уверенность они получают.
+// Код выхода 1 означает, что какой-то бот проходит задание чаще, чем разрешено
+// -max-pass, поэтому команда годится для регрессионной проверки генераторов.
+//
+//	go run ./cmd/redteam -targets http://localhost:8080 -runs 20
+//
+// Боты:
+//
+//	scraper — разбирает разметку и палитру картинок, решает PoW, подделывает
+//	          проверки окружения и выдерживает человеческие паузы
+//	linear  — знает то же, что scraper, но двигается по прямой с равными паузами
+//	          и не отвечает на проверки окружения
+//	random  — кликает наугад со случайными паузами
+//	replay  — повторяет в новом задании самую успешную сессию предыдущих ботов
+//	reuse   — повторяет ту же сессию по старому билету в уже завершённом задании
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/harness"
+	"github.com/theborzet/captcha_service/internal/risk"
+	"github.com/theborzet/captcha_service/internal/solver"
+)
+
+type config struct {
+	targets  []string
+	opts     harness.Options
+	bots     []string
+	runs     int
+	parallel int
+	timeout  time.Duration
+	maxPass  float64
+	exempt   map[string]bool
+	fresh    bool
+}
+
+// outcome — итог одной попытки бота
+type outcome struct {
+	kind       string
+	confidence int
+	rejected   bool // сервис отказал в самом ответе: задание не найдено или чужое
+	skipped    bool
+	err        error
+}
+
+type botFunc func(ctx context.Context, cfg *config, target string, h *history) outcome
+
+var bots = map[string]botFunc{
+	"scraper": solverBot(solver.Scraper),
+	"linear":  solverBot(solver.Linear),
+	"random":  solverBot(solver.Random),
+	"replay":  replayBot,
+	"reuse":   reuseBot,
+}
+
+var botOrder = []string{"scraper", "linear", "random", "replay", "reuse"}
+
+func main() {
+	cfg, err := parseFlags()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var report []row
+	for _, target := range cfg.targets {
+		h := newHistory()
+		for _, name := range cfg.bots {
+			results := runBot(cfg, target, bots[name], h)
+			report = append(report, summarize(target, name, results)...)
+		}
+	}
+
+	violations := printReport(os.Stdout, cfg, report)
+	if violations > 0 {
+		fmt.Printf("\n%d bot/type pairs pass more often than %.0f%%\n", violations, cfg.maxPass*100)
+		os.Exit(1)
+	}
+}
+
+func parseFlags() (*config, error) {
+	cfg := &config{exempt: make(map[string]bool)}
+	targets := flag.String("targets", "http://localhost:8080", "captcha HTTP servers, comma-separated")
+	botList := flag.String("bots", strings.Join(botOrder, ","), "bots to run, in order")
+	exempt := flag.String("exempt", "pow-v1,pow-memory-v1", "challenge types excluded from -max-pass: proof of work only costs compute")
+	flag.StringVar(&cfg.opts.Origin, "origin", "http://localhost:8000", "origin of the embedding page, must be allowed by the server")
+	flag.IntVar(&cfg.opts.Complexity, "complexity", 1, "requested complexity, 0 for the server default")
+	flag.BoolVar(&cfg.opts.Accessible, "accessible", false, "request the accessible challenge")
+	flag.StringVar(&cfg.opts.SiteKey, "sitekey", "", "site key to request challenges for, empty for the default site")
+	flag.IntVar(&cfg.runs, "runs", 10, "attempts per bot and target")
+	flag.IntVar(&cfg.parallel, "parallel", 4, "concurrent attempts")
+	flag.DurationVar(&cfg.timeout, "timeout", time.Minute, "timeout of a single attempt")
+	flag.Float64Var(&cfg.maxPass, "max-pass", 0.05, "maximum allowed pass rate of any bot, 0..1")
+	flag.BoolVar(&cfg.fresh, "fresh-clients", true, "send a new User-Agent on every attempt so risk escalation does not mix challenge types")
+	flag.Parse()
+
+	for _, t := range strings.Split(*targets, ",") {
+		if t = strings.TrimRight(strings.TrimSpace(t), "/"); t != "" {
+			cfg.targets = append(cfg.targets, t)
+		}
+	}
+	for _, b := range strings.Split(*botList, ",") {
+		b = strings.TrimSpace(b)
+		if bots[b] == nil {
+			return nil, fmt.Errorf("unknown bot %q, available: %s", b, strings.Join(botOrder, ", "))
+		}
+		cfg.bots = append(cfg.bots, b)
+	}
+	for _, t := range strings.Split(*exempt, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			cfg.exempt[t] = true
+		}
+	}
+	if len(cfg.targets) == 0 || cfg.runs <= 0 || cfg.parallel <= 0 {
+		return nil, errors.New("-targets, -runs and -parallel must be non-empty and positive")
+	}
+	return cfg, nil
+}
+
+// options — параметры запроса задания для одной попытки. Ключ профиля риска —
+// адрес и User-Agent, и без смены User-Agent после первых провалов сервис
+// начинает выдавать ботам другие задания.
+func (cfg *config) options() harness.Options {
+	opts := cfg.opts
+	if cfg.fresh {
+		opts.UserAgent = fmt.Sprintf("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.0 Safari/537.36",
+			110+rand.Intn(20), rand.Intn(10000))
+	}
+	return opts
+}
+
+// runBot делает cfg.runs попыток бота, не больше cfg.parallel одновременно
+func runBot(cfg *config, target string, bot botFunc, h *history) []outcome {
+	results := make([]outcome, cfg.runs)
+	sem := make(chan struct{}, cfg.parallel)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+			defer cancel()
+			results[i] = bot(ctx, cfg, target, h)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// solverBot — бот, который сам отвечает на задание в заданной манере
+func solverBot(st solver.Style) botFunc {
+	return func(ctx context.Context, cfg *config, target string, h *history) outcome {
+		s, err := harness.Open(ctx, target, cfg.options())
+		if err != nil {
+			return outcome{err: err}
+		}
+		defer s.Close()
+		s.ForgeProbes = st.Forge
+		first := s.Page
+
+		res, err := solver.Solve(ctx, s, st)
+		if err != nil {
+			return outcome{kind: first.Type, err: err}
+		}
+		out := resultOutcome(first.Type, res)
+		h.record(first, s.Ticket, s.Transcript(), out.confidence)
+		return out
+	}
+}
+
+// replayBot повторяет лучшую записанную сессию в новом задании того же типа
+// с исходными паузами: данные ответа относятся к чужому заданию
+func replayBot(ctx context.Context, cfg *config, target string, h *history) outcome {
+	s, err := harness.Open(ctx, target, cfg.options())
+	if err != nil {
+		return outcome{err: err}
+	}
+	defer s.Close()
+	rec, ok := h.best(s.Page.Type)
+	if !ok {
+		return outcome{kind: s.Page.Type, skipped: true}
+	}
+	return replay(ctx, s, rec)
+}
+
+// reuseBot открывает WebSocket по билету уже завершённой сессии и повторяет её
+func reuseBot(ctx context.Context, cfg *config, target string, h *history) outcome {
+	rec, ok := h.any()
+	if !ok {
+		return outcome{skipped: true}
+	}
+	s, err := harness.Dial(ctx, target, rec.ticket, cfg.opts.Origin, cfg.opts.SiteKey)
+	if err != nil {
+		// Отказ на рукопожатии — тоже отказ сервиса в ответе
+		return outcome{kind: rec.page.Type, rejected: true}
+	}
+	defer s.Close()
+	s.Page = rec.page
+	return replay(ctx, s, rec)
+}
+
+// replayGrace — сколько ждать итога после последнего события записи
+const replayGrace = 5 * time.Second
+
+func replay(ctx context.Context, s *harness.Session, rec recording) outcome {
+	kind := s.Page.Type
+	start := time.Now()
+	for _, sent := range rec.transcript {
+		if sent.Event.Name == "ready" {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return outcome{kind: kind, err: ctx.Err()}
+		case <-time.After(time.Until(start.Add(sent.At))):
+		}
+		if err := s.Send(sent.Event); err != nil {
+			return outcome{kind: kind, err: err}
+		}
+	}
+	// Записанный ответ может не подойти к новому заданию так, что сервис ждёт
+	// продолжения: молчание после записи — провал, а не ошибка
+	wctx, cancel := context.WithTimeout(ctx, replayGrace)
+	defer cancel()
+	ev, err := s.Await(wctx)
+	switch {
+	case err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded):
+		return outcome{kind: kind}
+	case err != nil:
+		return outcome{kind: kind, err: err}
+	case ev.Stage != nil:
+		// Этап пройден, то есть уверенность не ниже порога; на следующий этап запись не подходит
+		return outcome{kind: kind, confidence: risk.PassThreshold}
+	}
+	return resultOutcome(kind, ev.Result)
+}
+
+func resultOutcome(kind string, res *harness.Result) outcome {
+	if strings.HasPrefix(res.ChallengeID, "error:") {
+		return outcome{kind: kind, rejected: true}
+	}
+	return outcome{kind: kind, confidence: res.Confidence}
+}
+
+// recording — сессия, которую можно повторить
+type recording struct {
+	page       *harness.Page
+	ticket     string
+	transcript []harness.Sent
+	confidence int
+}
+
+// history хранит по каждому типу заданий самую успешную сессию
+type history struct {
+	mu       sync.Mutex
+	sessions map[string]recording
+}
+
+func newHistory() *history {
+	return &history{sessions: make(map[string]recording)}
+}
+
+func (h *history) record(page *harness.Page, ticket string, transcript []harness.Sent, confidence int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if prev, ok := h.sessions[page.Type]; !ok || confidence >= prev.confidence {
+		h.sessions[page.Type] = recording{page: page, ticket: ticket, transcript: transcript, confidence: confidence}
+	}
+}
+
+func (h *history) best(kind string) (recording, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rec, ok := h.sessions[kind]
+	return rec, ok
+}
+
+func (h *history) any() (recording, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, rec := range h.sessions {
+		return rec, true
+	}
+	return recording{}, false
+}
+
+// row — сводка попыток одного бота по одному типу заданий
+type row struct {
+	target, bot, kind               string
+	runs, errors, rejected, skipped int
+	passed, sum, max                int
+	lastError                       string
+}
+
+func summarize(target, bot string, results []outcome) []row {
+	byKind := make(map[string]*row)
+	var kinds []string
+	for _, o := range results {
+		kind := o.kind
+		if kind == "" {
+			kind = "-"
+		}
+		r := byKind[kind]
+		if r == nil {
+			r = &row{target: target, bot: bot, kind: kind}
+			byKind[kind] = r
+			kinds = append(kinds, kind)
+		}
+		r.runs++
+		switch {
+		case o.err != nil:
+			r.errors++
+			r.lastError = o.err.Error()
+		case o.skipped:
+			r.skipped++
+		case o.rejected:
+			r.rejected++
+		default:
+			r.sum += o.confidence
+			r.max = max(r.max, o.confidence)
+			if o.confidence >= risk.PassThreshold {
+				r.passed++
+			}
+		}
+	}
+	slices.Sort(kinds)
+	rows := make([]row, 0, len(kinds))
+	for _, k := range kinds {
+		rows = append(rows, *byKind[k])
+	}
+	return rows
+}
+
+// printReport печатает сводку и возвращает число пар бот/тип, превысивших -max-pass
+func printReport(w *os.File, cfg *config, rows []row) int {
+	violations := 0
+	fmt.Fprintf(w, "%-8s %-18s %5s %5s %5s %5s %6s %5s %6s\n", "bot", "type", "runs", "err", "rej", "skip", "mean", "max", "pass")
+	target := ""
+	for _, r := range rows {
+		if r.target != target {
+			target = r.target
+			fmt.Fprintf(w, "# %s\n", target)
+		}
+		scored := r.runs - r.errors - r.skipped
+		mean, rate := 0.0, 0.0
+		if scored > 0 {
+			mean = float64(r.sum) / float64(scored)
+			rate = float64(r.passed) / float64(scored)
+		}
+		mark := ""
+		if rate > cfg.maxPass && !cfg.exempt[r.kind] {
+			violations++
+			mark = "  !"
+		}
+		fmt.Fprintf(w, "%-8s %-18s %5d %5d %5d %5d %6.1f %5d %5.0f%%%s\n",
+			r.bot, r.kind, r.runs, r.errors, r.rejected, r.skipped, mean, r.max, rate*100, mark)
+		if r.lastError != "" {
+			fmt.Fprintf(w, "         last error: %s\n", r.lastError)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,152 @@
+// Повтор записанных сессий: каждая сессия из файла записи (record.path в конфиге)
+// заново проходит через GRPCCaptchaService текущей версии (internal/replay),
+// а итог сравнивается с записанным.
+//
+//	go run ./cmd/replay -v sessions.rec
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/replay"
+	"github.com/theborzet/captcha_service/internal/risk"
+)
+
+type options struct {
+	kind      string
+	challenge string
+	changed   bool
+	verbose   bool
+	model     string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.kind, "type", "", "replay only sessions of this challenge type")
+	flag.StringVar(&opts.challenge, "id", "", "replay only the session with this challenge ID")
+	flag.BoolVar(&opts.changed, "changed", false, "list only sessions whose result changed")
+	flag.BoolVar(&opts.verbose, "v", false, "list every replayed session")
+	flag.StringVar(&opts.model, "model", "", "score stages with this model file instead of the built-in formula")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: replay [flags] recording...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	replayer, err := replay.New(opts.model)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	stats := make(map[string]*typeStats)
+	list := opts.verbose || opts.changed
+	if list {
+		fmt.Printf("%-24s %-18s %4s %8s %8s %6s\n", "challenge", "type", "cx", "recorded", "replayed", "delta")
+	}
+	for _, path := range flag.Args() {
+		err := recording.ReadFile(path, func(s *recording.Session) {
+			if (opts.kind != "" && s.Type != opts.kind) || (opts.challenge != "" && s.ChallengeID != opts.challenge) {
+				return
+			}
+			st := stats[s.Type]
+			if st == nil {
+				st = &typeStats{}
+				stats[s.Type] = st
+			}
+			res, err := replayer.Run(s)
+			got := res.Confidence
+			st.add(s.Confidence, got, err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", s.ChallengeID, err)
+				return
+			}
+			if opts.verbose || (opts.changed && got != s.Confidence) {
+				delta := "-"
+				if got != recording.NoResult && s.Confidence != recording.NoResult {
+					delta = fmt.Sprintf("%+d", got-s.Confidence)
+				}
+				fmt.Printf("%-24s %-18s %4d %8s %8s %6s\n",
+					s.ChallengeID, s.Type, s.Complexity, score(s.Confidence), score(got), delta)
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+	if list {
+		fmt.Println()
+	}
+	printSummary(stats)
+}
+
+type typeStats struct {
+	sessions, errors, changed int
+	passToFail, failToPass    int
+	recorded, replayed        []int
+}
+
+func (t *typeStats) add(recorded, replayed int, err error) {
+	t.sessions++
+	if err != nil {
+		t.errors++
+		return
+	}
+	if replayed != recorded {
+		t.changed++
+	}
+	wasPassed, passed := recorded >= risk.PassThreshold, replayed >= risk.PassThreshold
+	switch {
+	case wasPassed && !passed:
+		t.passToFail++
+	case !wasPassed && passed:
+		t.failToPass++
+	}
+	if recorded != recording.NoResult {
+		t.recorded = append(t.recorded, recorded)
+	}
+	if replayed != recording.NoResult {
+		t.replayed = append(t.replayed, replayed)
+	}
+}
+
+func printSummary(stats map[string]*typeStats) {
+	kinds := make([]string, 0, len(stats))
+	for kind := range stats {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	fmt.Printf("%-18s %8s %5s %8s %9s %9s %8s %8s\n",
+		"type", "sessions", "err", "changed", "pass-fail", "fail-pass", "recorded", "replayed")
+	for _, kind := range kinds {
+		t := stats[kind]
+		fmt.Printf("%-18s %8d %5d %8d %9d %9d %8.1f %8.1f\n",
+			kind, t.sessions, t.errors, t.changed, t.passToFail, t.failToPass, mean(t.recorded), mean(t.replayed))
+	}
+}
+
+func mean(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+func score(confidence int) string {
+	if confidence == recording.NoResult {
+		return "-"
+	}
+	return fmt.Sprint(confidence)
+}
@@ -4,36 +4,113 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/theborzet/captcha_service/internal/challenge"
 	"github.com/theborzet/captcha_service/internal/config"
+	"github.com/theborzet/captcha_service/internal/metrics"
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/risk"
+	"github.com/theborzet/captcha_service/internal/scoring"
+	"github.com/theborzet/captcha_service/internal/security"
 	"github.com/theborzet/captcha_service/internal/services"
+	"github.com/theborzet/captcha_service/internal/tenant"
 	"github.com/theborzet/captcha_service/internal/websocket"
 	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
 	"github.com/theborzet/captcha_service/pkg/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+// validAction — допустимое имя действия: как у reCAPTCHA, буквы, цифры, / и _
+var validAction = regexp.MustCompile(`^[A-Za-z0-9/_]{0,100}$`)
+
 type App struct {
 	Server      *services.Server
+	store       *challenge.ChallengeStore
+	pool        *challenge.Pool     // nil, если пул выключен
+	recorder    *recording.Recorder // nil, если запись сессий выключена
+	scorer      *scoring.Engine
+	tenants     *tenant.Registry
+	redis       *redis.Client // nil, если погашенные пропуски помнит только процесс
 	log         *slog.Logger
 	cfg         *config.Config
 	captchaPort int
 }
 
 func New(log *slog.Logger, cfg *config.Config) *App {
+	// HTML всех генераторов должен проходить строгую CSP, иначе в iframe балансера он не заработает.
+	// Это проверяют тесты; при старте нарушение только попадает в лог вместе с весом заданий.
+	sizes, err := challenge.VerifyGenerators()
+	if err != nil {
+		log.Warn("HTML задания нарушает CSP", slog.Any("error", err))
+	}
+	for kind, size := range sizes {
+		log.Info("Размер HTML задания", slog.String("type", kind), slog.Int("bytes", size))
+	}
+
 	challengeStore := challenge.NewInMemoryStore()
+	var pool *challenge.Pool
+	if cfg.Pool.Enabled {
+		pool = challenge.NewPool(challengeStore, cfg.Pool.Workers, cfg.Pool.Size, time.Duration(cfg.Pool.MaxAge)*time.Second, log)
+	}
+	var recorder *recording.Recorder
+	if cfg.Record.Path != "" {
+		if recorder, err = recording.New(challengeStore, cfg.Record.Path, cfg.Record.SampleRate, log); err != nil {
+			panic("failed to start session recorder: " + err.Error())
+		}
+		log.Info("Запись сессий включена", slog.String("path", cfg.Record.Path), slog.Float64("sample_rate", cfg.Record.SampleRate))
+	}
+	scorer, err := scoring.New(cfg.Scoring.ModelPath, log)
+	if err != nil {
+		panic("failed to start scoring: " + err.Error())
+	}
+	log.Info("Модель оценки загружена", slog.String("model", scorer.Model()))
+	riskTracker := risk.NewTracker(cfg.Risk)
+	// Погашенные пропуски общие для инстансов, иначе пропуск гасился бы на каждом по разу
+	var (
+		redisClient *redis.Client
+		redeemed    security.Redeemed = security.NewMemoryRedeemed()
+	)
+	if cfg.Pass.RedisAddr != "" {
+		redisClient = redis.NewClient(&redis.Options{Addr: cfg.Pass.RedisAddr})
+		pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := redisClient.Ping(pingCtx).Err()
+		cancel()
+		if err != nil {
+			panic("failed to connect to pass redis: " + err.Error())
+		}
+		redeemed = security.NewRedisRedeemed(redisClient)
+		log.Info("Погашенные пропуски хранятся в Redis", slog.String("addr", cfg.Pass.RedisAddr))
+	} else {
+		log.Warn("pass.redis_addr не задан: погашенные пропуски помнит только этот инстанс, проверять пропуски должен один инстанс")
+	}
+	tenants, err := tenant.NewRegistry(cfg.DefaultTenant(), cfg.Tenants, redeemed)
+	if err != nil {
+		panic("failed to load tenants: " + err.Error())
+	}
+	log.Info("Сайты загружены", slog.Int("tenants", len(cfg.Tenants)))
 	captchaPort := utils.FindAvailablePort(cfg.Server.MinPort, cfg.Server.MaxPort)
 	serverApp := services.NewCaptchaServer(
 		log,
 		challengeStore,
+		pool,
+		riskTracker,
+		recorder,
+		scorer,
+		tenants,
+		cfg.Instance.Chain,
 		cfg.Instance.ID,
 		cfg.Instance.ChallengeType,
 		cfg.Host,
@@ -41,7 +118,7 @@ func New(log *slog.Logger, cfg *config.Config) *App {
 		captchaPort,
 		cfg.Balancer.Port,
 	)
-	return &App{Server: serverApp, log: log, cfg: cfg, captchaPort: captchaPort}
+	return &App{Server: serverApp, store: challengeStore, pool: pool, recorder: recorder, scorer: scorer, tenants: tenants, redis: redisClient, log: log, cfg: cfg, captchaPort: captchaPort}
 }
 
 func (a *App) Run(ctx context.Context) error {
@@ -55,6 +132,21 @@ func (a *App) Run(ctx context.Context) error {
 		}
 	}()
 
+	// Пул заданий наполняется в фоне, пока приложение работает
+	poolCtx, stopPool := context.WithCancel(ctx)
+	defer stopPool()
+	go a.recorder.Run(ctx)
+	go a.scorer.Run(ctx, time.Duration(a.cfg.Scoring.ReloadInterval)*time.Second)
+	collectors := []metrics.Collector{metrics.Runtime, a.store}
+	if a.pool != nil {
+		go a.pool.Run(poolCtx)
+		collectors = append(collectors, a.pool)
+		a.log.Info("Пул заданий запущен",
+			slog.Int("workers", a.cfg.Pool.Workers),
+			slog.Int("size", a.cfg.Pool.Size),
+			slog.Int("max_age", a.cfg.Pool.MaxAge))
+	}
+
 	// Подключаемся к gRPC-серверу с ретраями
 	var conn *grpc.ClientConn
 	for i := 0; i < 10; i++ {
@@ -82,28 +174,89 @@ func (a *App) Run(ctx context.Context) error {
 
 	// Настраиваем HTTP-сервер
 	srv := &http.Server{Addr: ":8080"}
-	http.Handle("/ws", websocket.NewProxy(client, a.log, ctx))
+	tickets := security.NewTicketIssuer(a.cfg.Security.TicketSecret, time.Duration(a.cfg.Security.TicketTTL)*time.Second)
+	http.Handle("/ws", websocket.NewProxy(client, a.tenants, tickets, a.log, ctx))
 	http.HandleFunc("/captcha", func(w http.ResponseWriter, r *http.Request) {
+		// sitekey — ключ сайта, встроившего капчу; без него действуют общие настройки
+		siteKey := r.URL.Query().Get("sitekey")
+		site, err := a.tenants.Lookup(siteKey)
+		if err != nil {
+			a.log.Warn("CAPTCHA request with unknown site key", slog.String("site_key", siteKey))
+			http.Error(w, "Unknown site key", http.StatusForbidden)
+			return
+		}
+		if !site.Origins.Allowed(r) {
+			a.log.Warn("CAPTCHA request from foreign origin", slog.String("origin", r.Header.Get("Origin")), slog.String("site", site.Name))
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		// complexity — по общей для всех типов шкале 0-100, генератор переводит её в свои параметры
 		complexity := 1
 		if compStr := r.URL.Query().Get("complexity"); compStr != "" {
-			if comp, err := strconv.Atoi(compStr); err == nil && comp > 0 && comp <= 3 {
+			if comp, err := strconv.Atoi(compStr); err == nil && comp >= 0 && comp <= 100 {
 				complexity = comp
 			}
 		}
-		resp, err := client.NewChallenge(ctx, &pb.ChallengeRequest{Complexity: int32(complexity)})
-		if err != nil {
+		// accessible=1 — клиент просит задание, которое проходится без мыши
+		accessible, _ := strconv.ParseBool(r.URL.Query().Get("accessible"))
+		// action — действие на сайте, ради которого проходится капча; попадает в пропуск
+		action := r.URL.Query().Get("action")
+		if !validAction.MatchString(action) {
+			http.Error(w, "Invalid action", http.StatusBadRequest)
+			return
+		}
+		var hostname string
+		if u, err := url.Parse(security.RequestOrigin(r)); err == nil {
+			hostname = u.Hostname()
+		}
+		// Клиент — только его адрес: заголовки вроде User-Agent он подменяет сам
+		// и так обнулял бы себе ограничение частоты и профиль риска
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		resp, err := client.NewChallenge(ctx, &pb.ChallengeRequest{
+			Complexity: int32(complexity),
+			ClientKey:  risk.ClientKey(host),
+			Accessible: accessible,
+			Hostname:   hostname,
+			Action:     action,
+			SiteKey:    siteKey,
+		})
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.ResourceExhausted:
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		case codes.PermissionDenied:
+			http.Error(w, "Unknown site key", http.StatusForbidden)
+			return
+		default:
 			a.log.Error("Failed to generate CAPTCHA", slog.Any("error", err))
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		a.log.Info("Returning CAPTCHA HTML", slog.String("html", resp.Html)) // Отладка
+		// Сам HTML не логируется: в нём data: URI картинок и звука на десятки килобайт
+		a.log.Debug("Returning CAPTCHA HTML",
+			slog.String("challenge_id", resp.ChallengeId),
+			slog.String("type", resp.ChallengeType),
+			slog.Int("bytes", len(resp.Html)))
 		w.Header().Set("Content-Type", "text/html")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Security-Policy", resp.ContentSecurityPolicy)
+		w.Header().Set("X-Captcha-Complexity", strconv.Itoa(int(resp.Complexity)))
+		w.Header().Set("X-Captcha-Type", resp.ChallengeType)
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "X-Captcha-Ticket, X-Captcha-Complexity, X-Captcha-Type, Content-Security-Policy")
+			w.Header().Set("Vary", "Origin")
+		}
+		// Билет сессии нужен, чтобы открыть WebSocket для этого задания
+		w.Header().Set("X-Captcha-Ticket", tickets.Issue(resp.ChallengeId, security.RequestOrigin(r), siteKey))
 		_, err = w.Write([]byte(resp.Html))
 		if err != nil {
 			a.log.Error("Failed to write CAPTCHA HTML", slog.Any("error", err))
 		}
 	})
+	http.HandleFunc("/siteverify", a.siteVerify(ctx, client))
+	http.Handle("/metrics", metrics.Handler(collectors...))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "frontend/public/drag-drop/index.html")
 	})
@@ -129,6 +282,13 @@ func (a *App) Run(ctx context.Context) error {
 	if err := a.Server.Stop(shutdownCtx); err != nil {
 		a.log.Error("Ошибка остановки сервера", slog.Any("error", err))
 	}
+	// Незавершённые сессии дописываются после остановки сервера, когда событий больше не будет
+	if err := a.recorder.Close(); err != nil {
+		a.log.Error("Ошибка записи сессий", slog.Any("error", err))
+	}
+	if a.redis != nil {
+		a.redis.Close()
+	}
 
 	a.log.Info("Server stopped")
 	return nil
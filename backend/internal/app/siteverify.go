@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+)
+
+// siteVerifyResponse — ответ /siteverify в формате, близком к reCAPTCHA
+type siteVerifyResponse struct {
+	Success     bool     `json:"success"`
+	Score       int      `json:"score,omitempty"` // уверенность 0-100
+	ChallengeID string   `json:"challenge_id,omitempty"`
+	ChallengeTS string   `json:"challenge_ts,omitempty"` // время выдачи пропуска, RFC 3339
+	Hostname    string   `json:"hostname,omitempty"`
+	Action      string   `json:"action,omitempty"`
+	ErrorCodes  []string `json:"error-codes,omitempty"`
+}
+
+// siteVerify — проверка пропуска целевым сервисом: POST с полями secret,
+// response (пропуск) и необязательными hostname и action, которые должны
+// совпасть с теми, для которых капча проходилась. По секрету VerifyToken
+// определяет сайт: принимаются только пропуски, выданные для его ключа.
+func (a *App) siteVerify(ctx context.Context, client pb.CaptchaServiceClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := client.VerifyToken(ctx, &pb.VerifyTokenRequest{
+			Token:    r.PostForm.Get("response"),
+			Hostname: r.PostForm.Get("hostname"),
+			Action:   r.PostForm.Get("action"),
+			Secret:   r.PostForm.Get("secret"),
+		})
+		if err != nil {
+			a.log.Error("Failed to verify pass token", slog.Any("error", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		out := siteVerifyResponse{
+			Success:     resp.Success,
+			Score:       int(resp.Score),
+			ChallengeID: resp.ChallengeId,
+			Hostname:    resp.Hostname,
+			Action:      resp.Action,
+		}
+		if resp.IssuedAt > 0 {
+			out.ChallengeTS = time.Unix(resp.IssuedAt, 0).UTC().Format(time.RFC3339)
+		}
+		if resp.ErrorCode != "" {
+			out.ErrorCodes = []string{resp.ErrorCode}
+		}
+		writeJSON(w, out, a.log)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any, log *slog.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Failed to write response", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,119 @@
+// Package audio собирает звук аудиозаданий на сервере: произносит цифры встроенными
+// образцами, смешивает их с шумом и кодирует в WAV для встраивания в HTML.
+// Звук хранится как отсчёты float64 в диапазоне -1..1 с частотой Rate.
+package audio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Rate — частота дискретизации. Для разборчивой речи хватает полосы до 4 кГц,
+// а меньше отсчётов — меньше HTML задания.
+const Rate = 8000
+
+// Track — звуковая дорожка
+type Track []float64
+
+// Samples переводит длительность в число отсчётов
+func Samples(d time.Duration) int {
+	return int(d * Rate / time.Second)
+}
+
+// Duration — длительность n отсчётов
+func Duration(n int) time.Duration {
+	return time.Duration(n) * time.Second / Rate
+}
+
+// Silence возвращает тишину длительностью d
+func Silence(d time.Duration) Track {
+	return make(Track, Samples(d))
+}
+
+// Mix добавляет src с усилением gain начиная с отсчёта at, при необходимости удлиняя дорожку
+func (t Track) Mix(src Track, at int, gain float64) Track {
+	if end := at + len(src); end > len(t) {
+		t = append(t, make(Track, end-len(t))...)
+	}
+	for i, v := range src {
+		t[at+i] += v * gain
+	}
+	return t
+}
+
+// Reversed возвращает дорожку задом наперёд: спектр речи остаётся, а слов уже не разобрать
+func (t Track) Reversed() Track {
+	out := make(Track, len(t))
+	for i, v := range t {
+		out[len(t)-1-i] = v
+	}
+	return out
+}
+
+// Noise подмешивает к дорожке шум уровня 0-100: белый шум и медленно плывущий гул,
+// который сложнее отфильтровать по частоте
+func (t Track) Noise(level int) {
+	level = max(0, min(100, level))
+	white := 0.02 + float64(level)*0.0012
+	hum := float64(level) * 0.001
+	freq, phase := 80+rand.Float64()*120, 0.0
+	for i := range t {
+		// частота гула медленно гуляет, чтобы он не ложился в один узкий фильтр
+		phase += 2 * math.Pi * (freq + 30*math.Sin(float64(i)/Rate)) / Rate
+		t[i] += white*(rand.Float64()*2-1) + hum*math.Sin(phase)
+	}
+}
+
+// Normalize приводит пиковую громкость дорожки к peak
+func (t Track) Normalize(peak float64) {
+	var m float64
+	for _, v := range t {
+		m = max(m, math.Abs(v))
+	}
+	if m == 0 {
+		return
+	}
+	for i := range t {
+		t[i] *= peak / m
+	}
+}
+
+// WAV кодирует дорожку в WAV: моно, 8 бит, Rate Гц. Восьми бит для речи
+// в шуме достаточно, а файл вдвое меньше 16-битного.
+func (t Track) WAV() []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(t))
+	header := struct {
+		Riff      [4]byte
+		Size      uint32
+		Wave, Fmt [4]byte
+		FmtSize   uint32
+		Format    uint16
+		Channels  uint16
+		Rate      uint32
+		ByteRate  uint32
+		Align     uint16
+		Bits      uint16
+		Data      [4]byte
+		DataSize  uint32
+	}{
+		Riff: [4]byte{'R', 'I', 'F', 'F'}, Size: uint32(36 + len(t)),
+		Wave: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '},
+		FmtSize: 16, Format: 1, Channels: 1, Rate: Rate, ByteRate: Rate, Align: 1, Bits: 8,
+		Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: uint32(len(t)),
+	}
+	binary.Write(&buf, binary.LittleEndian, header)
+	for _, v := range t {
+		buf.WriteByte(byte(math.Round(max(-1, min(1, v))*127) + 128))
+	}
+	return buf.Bytes()
+}
+
+// DataURI кодирует дорожку в WAV и упаковывает в data: URI для встраивания в HTML
+func (t Track) DataURI() string {
+	return "data:audio/wav;base64," + base64.StdEncoding.EncodeToString(t.WAV())
+}
@@ -0,0 +1,222 @@
+package audio
+
+import (
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Цифры озвучиваются записанными образцами из samples: <цифра>-<диктор>.wav,
+// PCM 8 или 16 бит с любой частотой дискретизации. У каждого диктора должны быть
+// все десять цифр. При старте образцы приводятся к Rate и моно, тишина по краям
+// обрезается, а пиковая громкость нормируется к 1. Образцы synth* — временные,
+// собранные синтезом (cmd/audiosamples), до появления записей.
+//
+//go:embed samples
+var sampleFiles embed.FS
+
+var sampleName = regexp.MustCompile(`^([0-9])-(\w+)\.wav$`)
+
+// speakers — цифры 0-9 каждого диктора, дикторы по алфавиту
+var speakers = mustSpeakers()
+
+const (
+	silenceLevel = 0.03 // доля пика, ниже которой край образца считается тишиной
+	wobbleRate   = 3    // частота колебания скорости воспроизведения, Гц
+)
+
+// Voice — каким диктором и как произносятся цифры задания. Сдвиг и плавающая
+// скорость меняют высоту и темп записи, чтобы задание нельзя было распознать
+// прямым сравнением со встроенными образцами.
+type Voice struct {
+	Speaker int
+	Shift   float64 // во сколько раз запись звучит быстрее и выше
+	Wobble  float64 // размах медленного колебания скорости, доля от Shift
+}
+
+// RandomVoice выбирает диктора и сдвиг для задания
+func RandomVoice() Voice {
+	return Voice{
+		Speaker: rand.Intn(len(speakers)),
+		Shift:   0.88 + rand.Float64()*0.24,
+		Wobble:  0.02 + rand.Float64()*0.03,
+	}
+}
+
+// Digit произносит цифру d голосом v. Колебание скорости начинается со случайной
+// фазы, так что одна и та же цифра в задании не повторяется отсчёт в отсчёт.
+func Digit(d int, v Voice) Track {
+	src := speakers[v.Speaker%len(speakers)][d%10]
+	out := make(Track, 0, int(float64(len(src))/v.Shift)+1)
+	phase := rand.Float64() * 2 * math.Pi
+	for pos := 0.0; pos < float64(len(src)-1); {
+		i := int(pos)
+		frac := pos - float64(i)
+		out = append(out, src[i]*(1-frac)+src[i+1]*frac)
+		t := float64(len(out)) / Rate
+		pos += v.Shift * (1 + v.Wobble*math.Sin(2*math.Pi*wobbleRate*t+phase))
+	}
+	return out
+}
+
+// mustSpeakers загружает встроенные образцы; ошибка в них — ошибка в сборке, поэтому паника
+func mustSpeakers() [][10]Track {
+	entries, err := sampleFiles.ReadDir("samples")
+	if err != nil {
+		panic(fmt.Sprintf("audio samples: %v", err))
+	}
+	byName := make(map[string]*[10]Track)
+	for _, e := range entries {
+		m := sampleName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		data, err := sampleFiles.ReadFile(path.Join("samples", e.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("audio sample %s: %v", e.Name(), err))
+		}
+		t, err := decodeWAV(data)
+		if err != nil {
+			panic(fmt.Sprintf("audio sample %s: %v", e.Name(), err))
+		}
+		t = t.trimmed()
+		t.Normalize(1)
+		if byName[m[2]] == nil {
+			byName[m[2]] = new([10]Track)
+		}
+		byName[m[2]][m[1][0]-'0'] = t
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		panic("audio samples: no speakers")
+	}
+	out := make([][10]Track, 0, len(names))
+	for _, name := range names {
+		for d, t := range byName[name] {
+			if len(t) < 2 {
+				panic(fmt.Sprintf("audio samples: speaker %s has no digit %d", name, d))
+			}
+		}
+		out = append(out, *byName[name])
+	}
+	return out
+}
+
+// decodeWAV разбирает несжатый PCM WAV 8 или 16 бит и приводит его к Rate и моно
+func decodeWAV(data []byte) (Track, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	var (
+		channels, bits int
+		rate           int
+		pcm            []byte
+	)
+	for rest := data[12:]; len(rest) >= 8; {
+		id, size := string(rest[:4]), int(binary.LittleEndian.Uint32(rest[4:8]))
+		rest = rest[8:]
+		if size > len(rest) {
+			size = len(rest)
+		}
+		body := rest[:size]
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, errors.New("short fmt chunk")
+			}
+			if format := binary.LittleEndian.Uint16(body); format != 1 {
+				return nil, fmt.Errorf("unsupported format %d, want PCM", format)
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			rate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			pcm = body
+		}
+		// Чанки выровнены по двум байтам
+		rest = rest[min(len(rest), size+size%2):]
+	}
+	if channels == 0 || rate == 0 || pcm == nil {
+		return nil, errors.New("missing fmt or data chunk")
+	}
+	if bits != 8 && bits != 16 {
+		return nil, fmt.Errorf("unsupported %d-bit samples", bits)
+	}
+
+	frame := channels * bits / 8
+	t := make(Track, len(pcm)/frame)
+	for i := range t {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			at := i*frame + c*bits/8
+			if bits == 8 {
+				sum += (float64(pcm[at]) - 128) / 128
+			} else {
+				sum += float64(int16(binary.LittleEndian.Uint16(pcm[at:]))) / 32768
+			}
+		}
+		t[i] = sum / float64(channels)
+	}
+	return t.resampled(rate), nil
+}
+
+// resampled переводит дорожку с частотой from в Rate. При понижении частоты
+// отсчёты усредняются по окну, чтобы верхние частоты не заворачивались в полосу речи.
+func (t Track) resampled(from int) Track {
+	if from == Rate || len(t) == 0 {
+		return t
+	}
+	step := float64(from) / Rate
+	out := make(Track, int(float64(len(t))/step))
+	for i := range out {
+		pos := float64(i) * step
+		if step <= 1 {
+			j := int(pos)
+			frac := pos - float64(j)
+			next := min(j+1, len(t)-1)
+			out[i] = t[j]*(1-frac) + t[next]*frac
+			continue
+		}
+		lo, hi := int(pos), min(len(t), int(pos+step))
+		var sum float64
+		for _, v := range t[lo:hi] {
+			sum += v
+		}
+		out[i] = sum / float64(max(1, hi-lo))
+	}
+	return out
+}
+
+// trimmed обрезает тишину по краям, оставляя по 20 мс запаса
+func (t Track) trimmed() Track {
+	var peak float64
+	for _, v := range t {
+		peak = max(peak, math.Abs(v))
+	}
+	first, last := -1, -1
+	for i, v := range t {
+		if math.Abs(v) > peak*silenceLevel {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return t
+	}
+	margin := Samples(20 * time.Millisecond)
+	return t[max(0, first-margin):min(len(t), last+margin+1)]
+}
@@ -0,0 +1,12 @@
+package synth
+
+import "github.com/theborzet/captcha_service/internal/audio"
+
+// digitWords — транскрипции цифр 0-9 символами таблицы phones, заглавная — ударный гласный:
+// ноль, один, два, три, четыре, пять, шесть, семь, восемь, девять
+var digitWords = [10]string{"nOl", "adIn", "dvA", "trI", "citYre", "pAt", "wEst", "sEm", "vOsem", "dEvit"}
+
+// Digit произносит цифру d голосом v
+func Digit(d int, v Voice) audio.Track {
+	return Say(digitWords[d%10], v)
+}
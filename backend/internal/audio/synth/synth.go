@@ -0,0 +1,219 @@
+// Package synth — формантный синтез цифр для временных образцов аудиозаданий,
+// пока для диктора нет записей (см. cmd/audiosamples). Голосовой источник
+// (импульсы гортани) и шум пропускаются через резонаторы, настроенные на форманты
+// звука. Таблица звуков ниже и транскрипции цифр в digits.go — всё, из чего
+// собирается каждое слово.
+package synth
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"unicode"
+
+	"github.com/theborzet/captcha_service/internal/audio"
+)
+
+// phone — звук речи: форманты, длительность и из чего он состоит
+type phone struct {
+	formants [3]float64 // Гц
+	dur      float64    // мс
+	voice    float64    // громкость голосового источника
+	noise    float64    // громкость шумового источника
+	noiseF   float64    // центр полосы шума, Гц
+	noiseBW  float64    // ширина полосы шума, Гц
+	closure  float64    // доля смычки у взрывного: сначала тишина или голосовая полоса, затем шум
+	hiss     bool       // после взрыва шум не затухает, а тянется, как у ч
+	trill    bool       // дрожащий р
+}
+
+var phones = map[rune]phone{
+	// гласные
+	'a': {formants: [3]float64{700, 1250, 2550}, dur: 100, voice: 1},
+	'o': {formants: [3]float64{520, 900, 2450}, dur: 100, voice: 1},
+	'u': {formants: [3]float64{320, 750, 2300}, dur: 100, voice: 1},
+	'e': {formants: [3]float64{480, 1850, 2550}, dur: 95, voice: 1},
+	'i': {formants: [3]float64{290, 2250, 2950}, dur: 90, voice: 0.9},
+	'y': {formants: [3]float64{320, 1550, 2450}, dur: 90, voice: 0.9}, // ы
+	// сонорные
+	'n': {formants: [3]float64{260, 1300, 2500}, dur: 70, voice: 0.45},
+	'm': {formants: [3]float64{260, 1000, 2250}, dur: 70, voice: 0.45},
+	'l': {formants: [3]float64{360, 1100, 2500}, dur: 65, voice: 0.6},
+	'r': {formants: [3]float64{420, 1300, 1700}, dur: 60, voice: 0.6, trill: true},
+	// шумные
+	'v': {formants: [3]float64{300, 1100, 2300}, dur: 60, voice: 0.35, noise: 0.2, noiseF: 2000, noiseBW: 2000},
+	's': {formants: [3]float64{400, 1600, 2600}, dur: 110, noise: 0.9, noiseF: 3600, noiseBW: 700},
+	'w': {formants: [3]float64{400, 1600, 2600}, dur: 115, noise: 1, noiseF: 2400, noiseBW: 900}, // ш
+	'p': {formants: [3]float64{400, 900, 2300}, dur: 75, noise: 1.2, noiseF: 900, noiseBW: 1200, closure: 0.65},
+	't': {formants: [3]float64{400, 1700, 2600}, dur: 75, noise: 1.2, noiseF: 3300, noiseBW: 1200, closure: 0.65},
+	'd': {formants: [3]float64{300, 1600, 2600}, dur: 65, voice: 0.12, noise: 0.9, noiseF: 3000, noiseBW: 1500, closure: 0.65},
+	'c': {formants: [3]float64{350, 2000, 2700}, dur: 110, noise: 1, noiseF: 2700, noiseBW: 1000, closure: 0.35, hiss: true}, // ч
+}
+
+// formantBW — ширины полос формант, Гц
+var formantBW = [3]float64{80, 100, 140}
+
+const (
+	stressLength   = 1.7 // во сколько раз ударный гласный длиннее безударного
+	stressPitch    = 1.2 // и во сколько раз выше тон
+	transitionMs   = 35  // за сколько мс форманты переходят от звука к звуку
+	trillRate      = 28  // частота дрожания р, Гц
+	coefficientsMs = 2   // как часто пересчитываются коэффициенты резонаторов
+)
+
+// Voice — голос, которым произносятся слова
+type Voice struct {
+	Pitch float64 // основной тон, Гц
+	Tract float64 // во сколько раз форманты выше, чем в таблице: короче голосовой тракт — выше
+	Tempo float64 // во сколько раз звуки длиннее, чем в таблице
+}
+
+// resonator — резонатор второго порядка
+type resonator struct {
+	a, b, c float64
+	y1, y2  float64
+}
+
+// tune настраивает резонатор на частоту f с полосой bw. При peak усиление
+// на частоте f равно 1, иначе — на нулевой частоте, как в каскаде формант.
+func (r *resonator) tune(f, bw float64, peak bool) {
+	f = min(f, audio.Rate/2-bw/2)
+	r.c = -math.Exp(-2 * math.Pi * bw / audio.Rate)
+	r.b = 2 * math.Exp(-math.Pi*bw/audio.Rate) * math.Cos(2*math.Pi*f/audio.Rate)
+	if !peak {
+		r.a = 1 - r.b - r.c
+		return
+	}
+	z := cmplx.Exp(complex(0, -2*math.Pi*f/audio.Rate))
+	r.a = cmplx.Abs(1 - complex(r.b, 0)*z - complex(r.c, 0)*z*z)
+}
+
+func (r *resonator) step(x float64) float64 {
+	y := r.a*x + r.b*r.y1 + r.c*r.y2
+	r.y2, r.y1 = r.y1, y
+	return y
+}
+
+// glottal — импульс гортани Розенберга на фазе 0-1
+func glottal(phase float64) float64 {
+	switch {
+	case phase < 0.4:
+		return 0.5 * (1 - math.Cos(math.Pi*phase/0.4))
+	case phase < 0.56:
+		return math.Cos(math.Pi * (phase - 0.4) / 0.32)
+	}
+	return 0
+}
+
+// Say синтезирует слово по транскрипции из символов таблицы phones.
+// Заглавная буква — ударный гласный. Громкость слова нормирована к пику 1.
+func Say(word string, v Voice) audio.Track {
+	type segment struct {
+		phone
+		stressed bool
+		n        int
+	}
+	var segs []segment
+	total := 0
+	for _, r := range word {
+		p, ok := phones[unicode.ToLower(r)]
+		if !ok {
+			continue
+		}
+		s := segment{phone: p, stressed: unicode.IsUpper(r)}
+		dur := p.dur * v.Tempo
+		if s.stressed {
+			dur *= stressLength
+		}
+		s.n = int(dur * audio.Rate / 1000)
+		segs = append(segs, s)
+		total += s.n
+	}
+	out := make(audio.Track, 0, total)
+	if len(segs) == 0 {
+		return out
+	}
+
+	var (
+		tract     [3]resonator
+		fric      resonator
+		phase     float64
+		prevPulse float64
+		voiceAmp  float64
+		noiseAmp  float64
+	)
+	formants := segs[0].formants
+	step := int(coefficientsMs * audio.Rate / 1000)
+	trans := float64(transitionMs * audio.Rate / 1000)
+	ramp := 1 - math.Exp(-1/(0.003*audio.Rate)) // сглаживание громкости, чтобы не было щелчков
+
+	for si, s := range segs {
+		from := formants
+		stop := s.closure > 0
+		burst := int(float64(s.n) * s.closure)
+		if s.noise > 0 {
+			// у звуков без шума полосы нет, резонатор шума доигрывает прежнюю настройку
+			fric.tune(s.noiseF*v.Tract, s.noiseBW, true)
+		}
+		for i := 0; i < s.n; i++ {
+			pos := float64(len(out)) / float64(total)
+			if i%step == 0 {
+				k := min(1, float64(i)/trans)
+				if stop || (si > 0 && segs[si-1].closure > 0) {
+					k = 1 // на смычке форманты перескакивают
+				}
+				for j := range formants {
+					formants[j] = from[j] + (s.formants[j]-from[j])*k
+					tract[j].tune(formants[j]*v.Tract, formantBW[j], false)
+				}
+			}
+
+			// Тон плавно падает к концу слова и поднимается на ударном гласном
+			f0 := v.Pitch * (1.1 - 0.25*pos)
+			if s.stressed {
+				f0 *= 1 + (stressPitch-1)*math.Sin(math.Pi*float64(i)/float64(s.n))
+			}
+			phase += f0 / audio.Rate
+			if phase >= 1 {
+				phase--
+			}
+			pulse := glottal(phase)
+			source := pulse - prevPulse // излучение губами — производная потока
+			prevPulse = pulse
+
+			targetVoice, targetNoise := s.voice, s.noise
+			if stop {
+				if i < burst {
+					targetNoise = 0
+				} else {
+					targetVoice = 0
+					if !s.hiss {
+						targetNoise *= math.Exp(-float64(i-burst) / (0.012 * audio.Rate))
+					}
+				}
+			}
+			if s.trill {
+				targetVoice *= 0.6 + 0.4*math.Cos(2*math.Pi*trillRate*float64(i)/audio.Rate)
+			}
+			voiceAmp += (targetVoice - voiceAmp) * ramp
+			noiseAmp += (targetNoise - noiseAmp) * ramp
+
+			y := voiceAmp * 8 * source
+			for j := range tract {
+				y = tract[j].step(y)
+			}
+			y += noiseAmp * fric.step(rand.Float64()*2-1)
+			out = append(out, y)
+		}
+	}
+
+	// Короткие нарастание и затухание по краям слова
+	fade := min(len(out)/4, int(0.01*audio.Rate))
+	for i := 0; i < fade; i++ {
+		g := float64(i) / float64(fade)
+		out[i] *= g
+		out[len(out)-1-i] *= g
+	}
+	out.Normalize(1)
+	return out
+}
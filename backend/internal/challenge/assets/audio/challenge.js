@@ -0,0 +1,29 @@
+// Сервер узнаёт о начале воспроизведения и получает введённые цифры целиком.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __root__ = document.getElementById('__idRoot__');
+	var __audio__ = document.getElementById('__idAudio__');
+	var __form__ = document.getElementById('__idForm__');
+	var __input__ = document.getElementById('__idInput__');
+	var __challengeId__ = __root__.dataset.__attrId__;
+	var __sent__ = false;
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	__audio__.addEventListener('play', function () {
+		__send__({__event__: 'audio_play'});
+	});
+
+	__form__.addEventListener('submit', function (e) {
+		e.preventDefault();
+		if (__sent__ || !__input__.value) return;
+		__sent__ = true;
+		__input__.disabled = true;
+		__send__({__event__: 'audio_answer', __data__: __input__.value});
+	});
+
+	__send__({__event__: 'ready'});
+})();
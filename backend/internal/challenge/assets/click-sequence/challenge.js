@@ -0,0 +1,33 @@
+// Каждый клик сразу уходит на сервер точкой в x и y: паузы между кликами
+// сервер меряет сам по времени прихода.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __root__ = document.getElementById('__idRoot__');
+	var __field__ = document.getElementById('__idField__');
+	var __challengeId__ = __root__.dataset.__attrId__;
+	var __count__ = Number(__root__.dataset.__attrCount__);
+	var __clicks__ = 0;
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	__field__.addEventListener('pointerdown', function (e) {
+		if (__clicks__ >= __count__) return;
+		var r = __field__.getBoundingClientRect();
+		var x = Math.round(e.clientX - r.left), y = Math.round(e.clientY - r.top);
+		__clicks__++;
+
+		var m = document.createElement('div');
+		m.className = '__clsMark__';
+		m.textContent = __clicks__;
+		m.style.left = x + 'px';
+		m.style.top = y + 'px';
+		__field__.appendChild(m);
+
+		__send__({__event__: 'click', __x__: x, __y__: y});
+	});
+
+	__send__({__event__: 'ready'});
+})();
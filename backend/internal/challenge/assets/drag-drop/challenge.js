@@ -0,0 +1,71 @@
+// Перетаскивание работает на pointer-событиях, поэтому одинаково для мыши и тача.
+// Все события уходят балансеру через postMessage, ответ приходит ему же.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __container__ = document.getElementById('__idContainer__');
+	var __draggable__ = document.getElementById('__idDraggable__');
+	var __challengeId__ = __container__.dataset.__attrId__;
+	var __dragging__ = false, __dropped__ = false, __offsetX__ = 0, __offsetY__ = 0;
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	function __clamp__(v, hi) {
+		return Math.max(0, Math.min(hi, v));
+	}
+
+	__draggable__.addEventListener('pointerdown', function (e) {
+		if (__dropped__) return;
+		var r = __draggable__.getBoundingClientRect();
+		__offsetX__ = e.clientX - r.left;
+		__offsetY__ = e.clientY - r.top;
+		__dragging__ = true;
+		__draggable__.setPointerCapture(e.pointerId);
+		__draggable__.className = '__clsDragging__';
+		__send__({__event__: 'drag_start'});
+	});
+
+	__draggable__.addEventListener('pointermove', function (e) {
+		if (!__dragging__) return;
+		var r = __container__.getBoundingClientRect();
+		__draggable__.style.left = __clamp__(e.clientX - r.left - __offsetX__, __container__.clientWidth - __draggable__.offsetWidth) + 'px';
+		__draggable__.style.top = __clamp__(e.clientY - r.top - __offsetY__, __container__.clientHeight - __draggable__.offsetHeight) + 'px';
+	});
+
+	// Позиция считается от поля: обёртки вокруг элементов не позиционированы.
+	// Где цель, знает только сервер — она нарисована на картинке поля.
+	__draggable__.addEventListener('pointerup', function () {
+		if (!__dragging__) return;
+		__dragging__ = false;
+		__dropped__ = true;
+		__draggable__.className = '__clsDropped__';
+		var a = __draggable__.getBoundingClientRect(), b = __container__.getBoundingClientRect();
+		__send__({
+			__event__: 'drag_drop',
+			__x__: Math.round(a.left - b.left),
+			__y__: Math.round(a.top - b.top),
+			__success__: true
+		});
+	});
+
+	// Обновления задания от сервера: [op][длина uint32][данные], op 1 — новая картинка поля
+	window.addEventListener('message', function (e) {
+		if (e.source !== window.parent || !e.data || e.data.type !== 'captcha:serverData') return;
+		var b = e.data.data;
+		b = typeof b === 'string' ? Uint8Array.from(atob(b), function (ch) { return ch.charCodeAt(0); }) : new Uint8Array(b);
+		for (var i = 0; i + 5 <= b.length;) {
+			var n = ((b[i + 1] << 24) | (b[i + 2] << 16) | (b[i + 3] << 8) | b[i + 4]) >>> 0;
+			var __blob__ = b.subarray(i + 5, i + 5 + n);
+			if (b[i] === 1) {
+				var s = '';
+				for (var j = 0; j < __blob__.length; j++) s += String.fromCharCode(__blob__[j]);
+				__container__.style.backgroundImage = 'url(data:image/png;base64,' + btoa(s) + ')';
+			}
+			i += 5 + n;
+		}
+	});
+
+	__send__({__event__: 'ready'});
+})();
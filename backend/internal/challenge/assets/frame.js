@@ -0,0 +1,14 @@
+// Общий для всех заданий код iframe. Проверки окружения, выданные сервером через
+// RunClientJS, исполняются здесь, а не на странице сайта: страница только
+// пересылает их код. Код вставляется скриптом с nonce задания, иначе его не
+// пропустит CSP, и принимается только от родительской страницы.
+(function () {
+	var __nonce__ = document.currentScript.nonce;
+	window.addEventListener('message', function (e) {
+		if (e.source !== window.parent || !e.data || e.data.type !== 'captcha:runJS') return;
+		var __probe__ = document.createElement('script');
+		__probe__.setAttribute('nonce', __nonce__);
+		__probe__.textContent = e.data.code;
+		document.body.appendChild(__probe__);
+	});
+})();
@@ -0,0 +1,66 @@
+// Путь уходит на сервер кусками раз в 200 мс: первый кусок начинается с точки старта
+// x<<13|y (uint32), дальше смещения dx, dy по int8. Длинные смещения дробятся.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __root__ = document.getElementById('__idRoot__');
+	var __field__ = document.getElementById('__idField__');
+	var __ctx__ = __field__.getContext('2d');
+	var __challengeId__ = __root__.dataset.__attrId__;
+	var __drawing__ = false, __finished__ = false, __bytes__ = [], __last__ = null, __timer__ = 0;
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	function __flush__(ev) {
+		var s = '';
+		for (var i = 0; i < __bytes__.length; i++) s += String.fromCharCode(__bytes__[i] & 255);
+		__bytes__ = [];
+		__send__({__event__: ev, __data__: btoa(s)});
+	}
+
+	function __point__(e) {
+		var r = __field__.getBoundingClientRect();
+		return [Math.round((e.clientX - r.left) * 320 / r.width), Math.round((e.clientY - r.top) * 200 / r.height)];
+	}
+
+	__field__.addEventListener('pointerdown', function (e) {
+		if (__drawing__ || __finished__) return;
+		__drawing__ = true;
+		__field__.setPointerCapture(e.pointerId);
+		__last__ = __point__(e);
+		var p = ((__last__[0] & 8191) << 13 | (__last__[1] & 8191)) >>> 0;
+		__bytes__.push(p >>> 24, p >>> 16, p >>> 8, p);
+		__ctx__.strokeStyle = '#1976d2';
+		__ctx__.lineWidth = 3;
+		__ctx__.lineCap = 'round';
+		__ctx__.beginPath();
+		__ctx__.moveTo(__last__[0], __last__[1]);
+		__timer__ = setInterval(function () { if (__bytes__.length) __flush__('trace'); }, 200);
+	});
+
+	__field__.addEventListener('pointermove', function (e) {
+		if (!__drawing__) return;
+		var p = __point__(e), dx = p[0] - __last__[0], dy = p[1] - __last__[1];
+		while (dx || dy) {
+			var sx = Math.max(-127, Math.min(127, dx)), sy = Math.max(-127, Math.min(127, dy));
+			__bytes__.push(sx, sy);
+			dx -= sx;
+			dy -= sy;
+		}
+		__last__ = p;
+		__ctx__.lineTo(p[0], p[1]);
+		__ctx__.stroke();
+	});
+
+	__field__.addEventListener('pointerup', function () {
+		if (!__drawing__) return;
+		__drawing__ = false;
+		__finished__ = true;
+		clearInterval(__timer__);
+		__flush__('trace_end');
+	});
+
+	__send__({__event__: 'ready'});
+})();
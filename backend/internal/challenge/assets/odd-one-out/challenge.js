@@ -0,0 +1,55 @@
+// Нажатия уходят на сервер координатами, какая плитка верная, знает только он.
+// В ответ приходят операции [op][длина uint32][данные]: 1 — новая сетка (после
+// ошибки, отметки сбрасываются), 2 — отметить плитку с номером из данных.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __root__ = document.getElementById('__idRoot__');
+	var __grid__ = document.getElementById('__idGrid__');
+	var __challengeId__ = __root__.dataset.__attrId__;
+	var __size__ = Number(__root__.dataset.__attrSize__);
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	function __mark__(i) {
+		var m = document.createElement('div'), cell = 100 / __size__;
+		m.className = '__clsMark__';
+		m.style.left = (i % __size__) * cell + '%';
+		m.style.top = Math.floor(i / __size__) * cell + '%';
+		m.style.width = cell + '%';
+		m.style.height = cell + '%';
+		__grid__.appendChild(m);
+	}
+
+	__grid__.addEventListener('pointerdown', function (e) {
+		var r = __grid__.getBoundingClientRect();
+		__send__({
+			__event__: 'tap',
+			__x__: Math.round((e.clientX - r.left) * 240 / r.width),
+			__y__: Math.round((e.clientY - r.top) * 240 / r.height)
+		});
+	});
+
+	window.addEventListener('message', function (e) {
+		if (e.source !== window.parent || !e.data || e.data.type !== 'captcha:serverData') return;
+		var b = e.data.data;
+		b = typeof b === 'string' ? Uint8Array.from(atob(b), function (ch) { return ch.charCodeAt(0); }) : new Uint8Array(b);
+		for (var i = 0; i + 5 <= b.length;) {
+			var n = ((b[i + 1] << 24) | (b[i + 2] << 16) | (b[i + 3] << 8) | b[i + 4]) >>> 0;
+			var __blob__ = b.subarray(i + 5, i + 5 + n);
+			if (b[i] === 1) {
+				var s = '';
+				for (var j = 0; j < __blob__.length; j++) s += String.fromCharCode(__blob__[j]);
+				__grid__.style.backgroundImage = 'url(data:image/png;base64,' + btoa(s) + ')';
+				while (__grid__.firstChild) __grid__.removeChild(__grid__.firstChild);
+			} else if (b[i] === 2) {
+				__mark__(__blob__[0]);
+			}
+			i += 5 + n;
+		}
+	});
+
+	__send__({__event__: 'ready'});
+})();
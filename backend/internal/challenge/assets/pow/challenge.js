@@ -0,0 +1,85 @@
+// Код воркера: функция передаётся в Blob через toString, отдельного файла не нужно.
+// Имена вида __name__ заменяются случайными для каждого задания.
+// Воркер ищет nonce, при котором хеш от seed:nonce начинается с нужного числа нулевых бит.
+function __powWorker__() {
+	var K = [
+		0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+		0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+		0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+		0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+		0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+		0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+		0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+		0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2
+	];
+	var W = new Int32Array(64);
+	function __sha256__(m) {
+		var l = m.length, n = (l + 72) & ~63, b = new Uint8Array(n), h = [0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19];
+		b.set(m); b[l] = 0x80;
+		var bl = l * 8; b[n - 4] = bl >>> 24; b[n - 3] = bl >>> 16; b[n - 2] = bl >>> 8; b[n - 1] = bl;
+		for (var o = 0; o < n; o += 64) {
+			for (var i = 0; i < 16; i++) W[i] = b[o + 4 * i] << 24 | b[o + 4 * i + 1] << 16 | b[o + 4 * i + 2] << 8 | b[o + 4 * i + 3];
+			for (i = 16; i < 64; i++) {
+				var x = W[i - 15], y = W[i - 2];
+				W[i] = W[i - 16] + ((x >>> 7 | x << 25) ^ (x >>> 18 | x << 14) ^ (x >>> 3)) + W[i - 7] + ((y >>> 17 | y << 15) ^ (y >>> 19 | y << 13) ^ (y >>> 10)) | 0;
+			}
+			var a = h[0], c = h[1], d = h[2], e = h[3], f = h[4], g = h[5], k = h[6], q = h[7];
+			for (i = 0; i < 64; i++) {
+				var t1 = q + ((f >>> 6 | f << 26) ^ (f >>> 11 | f << 21) ^ (f >>> 25 | f << 7)) + ((f & g) ^ (~f & k)) + K[i] + W[i] | 0;
+				var t2 = ((a >>> 2 | a << 30) ^ (a >>> 13 | a << 19) ^ (a >>> 22 | a << 10)) + ((a & c) ^ (a & d) ^ (c & d)) | 0;
+				q = k; k = g; g = f; f = e + t1 | 0; e = d; d = c; c = a; a = t1 + t2 | 0;
+			}
+			h[0] = h[0] + a | 0; h[1] = h[1] + c | 0; h[2] = h[2] + d | 0; h[3] = h[3] + e | 0;
+			h[4] = h[4] + f | 0; h[5] = h[5] + g | 0; h[6] = h[6] + k | 0; h[7] = h[7] + q | 0;
+		}
+		var r = new Uint8Array(32);
+		for (i = 0; i < 8; i++) { r[4 * i] = h[i] >>> 24; r[4 * i + 1] = h[i] >>> 16; r[4 * i + 2] = h[i] >>> 8; r[4 * i + 3] = h[i]; }
+		return r;
+	}
+	function __zeros__(h) {
+		for (var n = 0, i = 0; i < h.length; i++) {
+			if (h[i] !== 0) return n + Math.clz32(h[i]) - 24;
+			n += 8;
+		}
+		return n;
+	}
+	function __work__(seed, nonce, mem, v) {
+		var x = __sha256__(new TextEncoder().encode(seed + ':' + nonce)), t = new Uint8Array(32);
+		for (var i = 0; i < mem; i++) { v.set(x, i * 32); x = __sha256__(x); }
+		for (i = 0; i < mem; i++) {
+			var j = ((x[0] << 24 | x[1] << 16 | x[2] << 8 | x[3]) >>> 0) % mem;
+			for (var z = 0; z < 32; z++) t[z] = x[z] ^ v[j * 32 + z];
+			x = __sha256__(t);
+		}
+		return x;
+	}
+	self.onmessage = function (e) {
+		var p = e.data, v = new Uint8Array(p.mem * 32);
+		for (var nonce = 0; ; nonce++) {
+			if (__zeros__(__work__(p.seed, nonce, p.mem, v)) >= p.bits) { postMessage(nonce); return; }
+		}
+	};
+}
+
+(function () {
+	var __container__ = document.getElementById('__idContainer__');
+	var __challengeId__ = __container__.dataset.__attrId__;
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	__send__({__event__: 'ready'});
+
+	var src = '(' + __powWorker__.toString() + ')()';
+	var __worker__ = new Worker(URL.createObjectURL(new Blob([src], {type: 'text/javascript'})));
+	__worker__.onmessage = function (e) {
+		document.getElementById('__idStatus__').textContent = 'Готово';
+		__send__({__event__: 'pow', __data__: String(e.data)});
+	};
+	__worker__.postMessage({
+		seed: __container__.dataset.__attrSeed__,
+		bits: Number(__container__.dataset.__attrBits__),
+		mem: Number(__container__.dataset.__attrMemory__)
+	});
+})();
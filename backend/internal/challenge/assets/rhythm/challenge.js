@@ -0,0 +1,60 @@
+// Подсказки приходят операцией 3 с PNG цифры в тот момент, когда сервер решит.
+// На каждое нажатие уходит 4 байта: номер подсказки, цифра и время реакции в мс
+// с момента получения подсказки (uint16). Если на какие-то подсказки не нажали,
+// через паузу после последней уходит rhythm_end.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __root__ = document.getElementById('__idRoot__');
+	var __prompt__ = document.getElementById('__idPrompt__');
+	var __start__ = document.getElementById('__idStart__');
+	var __challengeId__ = __root__.dataset.__attrId__;
+	var __count__ = Number(__root__.dataset.__attrCount__);
+	var __shown__ = [], __answered__ = {}, __answers__ = 0, __timer__ = 0;
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	__start__.addEventListener('click', function () {
+		__start__.disabled = true;
+		__start__.hidden = true;
+		__prompt__.focus();
+		__send__({__event__: 'rhythm_start'});
+	});
+
+	__prompt__.addEventListener('keydown', function (e) {
+		if (e.repeat || e.key.length !== 1 || e.key < '1' || e.key > '9') return;
+		var __seq__ = __shown__.length - 1;
+		if (__seq__ < 0 || __answered__[__seq__]) return;
+		__answered__[__seq__] = true;
+		__answers__++;
+		var __rt__ = Math.min(65535, Math.round(performance.now() - __shown__[__seq__]));
+		__send__({
+			__event__: 'key',
+			__data__: btoa(String.fromCharCode(__seq__, e.key.charCodeAt(0) - 48, __rt__ >>> 8, __rt__ & 255))
+		});
+		if (__answers__ === __count__) clearTimeout(__timer__);
+	});
+
+	window.addEventListener('message', function (e) {
+		if (e.source !== window.parent || !e.data || e.data.type !== 'captcha:serverData') return;
+		var b = e.data.data;
+		b = typeof b === 'string' ? Uint8Array.from(atob(b), function (ch) { return ch.charCodeAt(0); }) : new Uint8Array(b);
+		for (var i = 0; i + 5 <= b.length;) {
+			var n = ((b[i + 1] << 24) | (b[i + 2] << 16) | (b[i + 3] << 8) | b[i + 4]) >>> 0;
+			if (b[i] === 3) {
+				var __blob__ = b.subarray(i + 5, i + 5 + n), s = '';
+				for (var j = 0; j < __blob__.length; j++) s += String.fromCharCode(__blob__[j]);
+				__prompt__.style.backgroundImage = 'url(data:image/png;base64,' + btoa(s) + ')';
+				__shown__.push(performance.now());
+				if (__shown__.length === __count__ && __answers__ < __count__) {
+					__timer__ = setTimeout(function () { __send__({__event__: 'rhythm_end'}); }, 2500);
+				}
+			}
+			i += 5 + n;
+		}
+	});
+
+	__send__({__event__: 'ready'});
+})();
@@ -0,0 +1,61 @@
+// Угол диска задаётся положением ручки на шкале, от 0 до 359 градусов по часовой.
+// Отсчёты траектории сразу уходят на сервер, время каждого он берёт по своим часам.
+// Имена вида __name__ заменяются случайными для каждого задания.
+(function () {
+	var __root__ = document.getElementById('__idRoot__');
+	var __disc__ = document.getElementById('__idDisc__');
+	var __track__ = document.getElementById('__idTrack__');
+	var __knob__ = document.getElementById('__idKnob__');
+	var __done__ = document.getElementById('__idDone__');
+	var __challengeId__ = __root__.dataset.__attrId__;
+	var __angle__ = 0, __samples__ = 0, __last__ = 0, __dragging__ = false, __started__ = false;
+
+	function __send__(d) {
+		d.challenge_id = __challengeId__;
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify(d)}, '*');
+	}
+
+	function __sample__() {
+		var now = performance.now();
+		if (now - __last__ < 30 || __samples__ >= 120) return;
+		__samples__++;
+		__last__ = now;
+		__send__({__event__: 'turn', __x__: __angle__, __y__: 0});
+	}
+
+	function __move__(e) {
+		var r = __track__.getBoundingClientRect();
+		var f = Math.max(0, Math.min(1, (e.clientX - r.left) / r.width));
+		__angle__ = Math.round(f * 359);
+		__knob__.style.left = (f * 100) + '%';
+		__disc__.style.transform = 'rotate(' + __angle__ + 'deg)';
+		__sample__();
+	}
+
+	__knob__.addEventListener('pointerdown', function (e) {
+		if (__done__.disabled) return;
+		__dragging__ = true;
+		__knob__.setPointerCapture(e.pointerId);
+		if (!__started__) {
+			__started__ = true;
+			__last__ = performance.now();
+			__send__({__event__: 'rotate_start', __x__: __angle__, __y__: 0});
+		}
+	});
+
+	__knob__.addEventListener('pointermove', function (e) {
+		if (__dragging__) __move__(e);
+	});
+
+	__knob__.addEventListener('pointerup', function () {
+		__dragging__ = false;
+	});
+
+	__done__.addEventListener('click', function () {
+		if (!__started__) return;
+		__done__.disabled = true;
+		__send__({__event__: 'rotate', __x__: __angle__, __y__: 0});
+	});
+
+	__send__({__event__: 'ready'});
+})();
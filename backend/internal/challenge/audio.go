@@ -0,0 +1,133 @@
+package challenge
+
+import (
+	"html/template"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/audio"
+)
+
+// События audio: начало воспроизведения и введённые с клавиатуры цифры в Data
+const (
+	EventAudioPlay   = "audio_play"
+	EventAudioAnswer = "audio_answer"
+)
+
+var audioBundle = mustBundle("audio")
+
+// audioState — ответ на задание и когда пользователь начал слушать запись
+type audioState struct {
+	digits   string
+	lastWord time.Duration // с какого момента записи звучит последняя цифра
+	played   time.Time
+}
+
+type audioGenerator struct{}
+
+// params подбирает по complexity 0-100 число цифр (4-6) и число неразборчивых
+// перевёрнутых слов, подмешанных под цифры
+func (audioGenerator) params(complexity int) (int, int) {
+	complexity = clampComplexity(complexity)
+	return 4 + complexity/34, complexity / 20
+}
+
+func (g audioGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	count, babble := g.params(p.Complexity)
+	voice := audio.RandomVoice()
+
+	var digits strings.Builder
+	var starts []int
+	track := audio.Silence(time.Duration(300+rand.Intn(200)) * time.Millisecond)
+	for i := 0; i < count; i++ {
+		d := rand.Intn(10)
+		digits.WriteByte(byte('0' + d))
+		starts = append(starts, len(track))
+		track = track.Mix(audio.Digit(d, voice), len(track), 0.7+rand.Float64()*0.3)
+		track = append(track, audio.Silence(time.Duration(350+rand.Intn(350))*time.Millisecond)...)
+	}
+
+	// Под цифрами звучат перевёрнутые слова другим голосом: человек отличает их
+	// от речи, а распознавание по спектру сбивается
+	other := audio.RandomVoice()
+	for i := 0; i < babble; i++ {
+		word := audio.Digit(rand.Intn(10), other).Reversed()
+		at := starts[rand.Intn(len(starts))] + rand.Intn(audio.Samples(200*time.Millisecond))
+		track = track.Mix(word, at, 0.15+rand.Float64()*0.15)
+	}
+	track.Noise(clampComplexity(p.Complexity))
+	track.Normalize(0.9)
+
+	store.Set(p.ChallengeID, TypeAudio, p.Complexity, &audioState{
+		digits:   digits.String(),
+		lastWord: audio.Duration(starts[len(starts)-1]),
+	}, defaultTTL)
+
+	return audioBundle.render(p, map[string]interface{}{
+		"Audio": template.URL(track.DataURI()),
+		"Count": count,
+	})
+}
+
+// HandleEvent сверяет введённые цифры с записью. Ответ, пришедший раньше, чем
+// с начала воспроизведения прозвучала последняя цифра, дан не на слух.
+func (g audioGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	switch event.Name {
+	case EventAudioPlay:
+		if !store.update(event.ChallengeID, func(d *challengeData) {
+			if st := d.state.(*audioState); st.played.IsZero() {
+				st.played = store.Now()
+			}
+		}) {
+			return Reaction{}, ErrNotFound
+		}
+		return Reaction{}, nil
+	case EventAudioAnswer:
+	default:
+		return Reaction{}, nil
+	}
+
+	var st audioState
+	var complexity int
+	if !store.view(event.ChallengeID, func(d *challengeData) {
+		st, complexity = *d.state.(*audioState), d.complexity
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+	if st.played.IsZero() || store.Now().Sub(st.played) < st.lastWord {
+		return Reaction{Done: true}, nil
+	}
+
+	answer := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, event.Data)
+	if len(answer) != len(st.digits) {
+		return Reaction{Done: true}, nil
+	}
+	wrong := 0
+	for i := range answer {
+		if answer[i] != st.digits[i] {
+			wrong++
+		}
+	}
+
+	// На слух в шуме легко спутать одну цифру; на простых заданиях это прощается
+	switch {
+	case wrong == 0:
+		return Reaction{Done: true, Confidence: 100}, nil
+	case wrong == 1 && clampComplexity(complexity) < 50:
+		return Reaction{Done: true, Confidence: 60}, nil
+	}
+	return Reaction{Done: true}, nil
+}
+
+// SolveWindow: запись нужно дослушать, а переслушивать и вводить с экранной
+// клавиатуры или через скринридер можно долго
+func (g audioGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	count, _ := g.params(complexity)
+	return time.Duration(count) * 600 * time.Millisecond, 3 * time.Minute
+}
@@ -0,0 +1,71 @@
+package challenge
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Ответ сверяется с цифрами записи и принимается только после того, как с начала
+// воспроизведения прозвучала последняя цифра
+func TestAudioHandleEvent(t *testing.T) {
+	type step struct {
+		name string
+		wait time.Duration // пауза перед событием
+		data string
+	}
+	listened := func(answer string) []step {
+		return []step{{EventAudioPlay, 0, ""}, {EventAudioAnswer, 3 * time.Second, answer}}
+	}
+	tests := []struct {
+		name       string
+		complexity int
+		steps      []step
+		confidence int
+	}{
+		{name: "correct answer", steps: listened("4821"), confidence: 100},
+		{name: "separators ignored", steps: listened("48 2-1"), confidence: 100},
+		{name: "one wrong digit on easy level", steps: listened("4871"), confidence: 60},
+		{name: "one wrong digit on hard level", complexity: 80, steps: listened("4871")},
+		{name: "two wrong digits", steps: listened("4877")},
+		{name: "too short", steps: listened("482")},
+		{name: "too long", steps: listened("48210")},
+		{name: "answer without playback", steps: []step{{EventAudioAnswer, 3 * time.Second, "4821"}}},
+		{
+			name:  "answer before last digit",
+			steps: []step{{EventAudioPlay, 0, ""}, {EventAudioAnswer, time.Second, "4821"}},
+		},
+		{
+			// Повторное воспроизведение не сдвигает момент первого
+			name:       "replay keeps first playback",
+			steps:      []step{{EventAudioPlay, 0, ""}, {EventAudioPlay, 1500 * time.Millisecond, ""}, {EventAudioAnswer, time.Second, "4821"}},
+			confidence: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			store.Set("c1", TypeAudio, tt.complexity, &audioState{digits: "4821", lastWord: 2 * time.Second}, defaultTTL)
+
+			var r Reaction
+			for _, s := range tt.steps {
+				clock.advance(s.wait)
+				var err error
+				r, err = audioGenerator{}.HandleEvent(store, Event{Name: s.name, ChallengeID: "c1", Data: s.data})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if !r.Done || r.Confidence != tt.confidence {
+				t.Fatalf("got done=%v confidence=%d, want done confidence=%d", r.Done, r.Confidence, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestAudioPlayUnknownChallenge(t *testing.T) {
+	store, _ := newTestStore()
+	if _, err := (audioGenerator{}).HandleEvent(store, Event{Name: EventAudioPlay, ChallengeID: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,148 @@
+package challenge
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Ассеты генераторов лежат в assets/<тип>/: challenge.html — шаблон разметки,
+// challenge.css и challenge.js — стили и код задания. Перед кодом задания идёт
+// общий для всех assets/frame.js. При старте всё минифицируется и встраивается
+// в шаблон, так что клиент получает один самодостаточный HTML.
+//
+//go:embed assets
+var assets embed.FS
+
+// bundle — шаблон задания с уже минифицированными стилями и скриптом
+type bundle struct {
+	tmpl   *template.Template
+	style  template.CSS
+	script template.JS
+}
+
+// mustBundle собирает ассеты из assets/<dir>; ошибка сборки — ошибка в коде, поэтому паника
+func mustBundle(dir string) *bundle {
+	read := func(name string) string {
+		data, err := assets.ReadFile(path.Join("assets", name))
+		if err != nil {
+			panic(fmt.Sprintf("challenge assets %s: %v", dir, err))
+		}
+		return string(data)
+	}
+
+	return &bundle{
+		tmpl:   template.Must(template.New(dir).Parse(minifyHTML(read(path.Join(dir, "challenge.html"))))),
+		style:  template.CSS(minifyCSS(read(path.Join(dir, "challenge.css")))),
+		script: template.JS(minifyJS(read("frame.js") + "\n" + read(path.Join(dir, "challenge.js")))),
+	}
+}
+
+// render выполняет шаблон для задания p. ChallengeID, Nonce, Style и Script
+// подставляются сами, Open и Close дают случайные обёртки элементов,
+// а плейсхолдеры в результате заменяются именами задания.
+func (b *bundle) render(p Params, data map[string]interface{}) (string, error) {
+	obf := p.obf
+	if obf == nil {
+		obf = newObfuscation()
+	}
+	data["ChallengeID"] = p.ChallengeID
+	data["Nonce"] = p.Nonce
+	data["Style"] = b.style
+	data["Script"] = b.script
+	data["Open"], data["Close"] = obf.nest()
+
+	var html strings.Builder
+	if err := b.tmpl.Execute(&html, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %v", err)
+	}
+	return obf.apply(html.String()), nil
+}
+
+var (
+	betweenTags = regexp.MustCompile(`>\s+<`)
+	lineIndent  = regexp.MustCompile(`\n\s*`)
+)
+
+// minifyHTML убирает переводы строк, отступы и пробелы между тегами
+func minifyHTML(src string) string {
+	src = betweenTags.ReplaceAllString(strings.TrimSpace(src), "><")
+	return lineIndent.ReplaceAllString(src, "")
+}
+
+// minifyCSS убирает комментарии и лишние пробелы
+func minifyCSS(src string) string {
+	return minify(src, "{}:;,>", false)
+}
+
+// minifyJS убирает комментарии и лишние пробелы, не трогая строки.
+// Минификатор простой, поэтому у ассетов есть ограничения: точка с запятой
+// ставится явно (переводы строк удаляются) и нет литералов регулярных выражений.
+func minifyJS(src string) string {
+	return minify(src, "{}()[];,:=+-*/%<>!&|^?~.", true)
+}
+
+// minify — общий проход для CSS и JS: punct — символы, вокруг которых пробел не нужен
+func minify(src, punct string, quotes bool) string {
+	var out strings.Builder
+	out.Grow(len(src))
+
+	var last byte // последний выведенный символ
+	space := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return out.String()
+			}
+			i += end + 3
+			space = true
+			continue
+		case quotes && c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		}
+
+		if space && last != 0 && !strings.ContainsRune(punct, rune(last)) && !strings.ContainsRune(punct, rune(c)) {
+			out.WriteByte(' ')
+		} else if space && (c == '+' || c == '-') && last == c {
+			// a + +b и a - -b нельзя склеивать в инкремент
+			out.WriteByte(' ')
+		}
+		space = false
+
+		if c == ';' && !quotes {
+			// последняя точка с запятой в блоке CSS не нужна
+			if j := strings.IndexFunc(src[i+1:], func(r rune) bool { return !strings.ContainsRune(" \t\r\n", r) }); j >= 0 && src[i+1+j] == '}' {
+				continue
+			}
+		}
+
+		if quotes && (c == '\'' || c == '"' || c == '`') {
+			start := i
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			out.WriteString(src[start:min(i+1, len(src))])
+			last = c
+			continue
+		}
+
+		out.WriteByte(c)
+		last = c
+	}
+	return out.String()
+}
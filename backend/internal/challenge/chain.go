@@ -0,0 +1,81 @@
+package challenge
+
+import (
+	"slices"
+	"time"
+
+	"github.com/theborzet/captcha_service/pkg/utils"
+)
+
+// chain — цепочка заданий под одним ID сессии. Этапы выдаются по одному:
+// следующий генерируется под тем же ID, когда пройден предыдущий,
+// а клиент получает его через открытый стрим.
+type chain struct {
+	stages     []string // типы всех этапов по порядку
+	results    []int    // итоговая уверенность пройденных этапов
+	complexity int      // сложность цепочки 0-100, каждый этап переводит её в свои параметры сам
+}
+
+// NewChain создаёт первый этап цепочки заданий указанных типов. Из одного
+// типа получается обычное задание. Первый этап берётся из пула, если он
+// включён и в нём есть готовое; следующие этапы генерируются под ID сессии
+// со сложностью первого.
+func NewChain(store *ChallengeStore, pool *Pool, kinds []string, complexity int) (*Task, error) {
+	task, ok := pool.Take(kinds[0], complexity)
+	if !ok {
+		var err error
+		if task, err = newTask(store, utils.GenerateChallengeID(), kinds[0], complexity); err != nil {
+			return nil, err
+		}
+	}
+	if len(kinds) == 1 {
+		return task, nil
+	}
+	store.update(task.ID, func(d *challengeData) {
+		d.chain = &chain{stages: kinds, complexity: task.Complexity}
+	})
+	task.Stages = len(kinds)
+	return task, nil
+}
+
+// CompleteStage записывает итог этапа. Если этап пройден (passed) и в цепочке
+// есть следующий, он генерируется под тем же ID и возвращается. Иначе цепочка
+// закончена и возвращается её итог — наименьшая уверенность по этапам: цепочка
+// пройдена, только если пройден каждый этап.
+func CompleteStage(store *ChallengeStore, challengeID string, confidence int, passed bool) (*Task, int, error) {
+	var (
+		c         *chain
+		clientKey string
+		site      Site
+		ttl       time.Duration
+	)
+	if !store.view(challengeID, func(d *challengeData) {
+		c, clientKey, site, ttl = d.chain, d.clientKey, d.site, d.ttl
+	}) {
+		return nil, 0, ErrNotFound
+	}
+	if c == nil {
+		return nil, confidence, nil
+	}
+
+	results := append(c.results[:len(c.results):len(c.results)], confidence)
+	if !passed || len(results) == len(c.stages) {
+		return nil, slices.Min(results), nil
+	}
+
+	// Новый этап заменяет данные прежнего в хранилище, цепочку, клиента и сайт переносим.
+	// Сложность берётся у цепочки, а не у прошлого этапа: параметры этапа — дело его генератора.
+	task, err := newTask(store, challengeID, c.stages[len(results)], c.complexity)
+	if err != nil {
+		return nil, 0, err
+	}
+	store.update(challengeID, func(d *challengeData) {
+		d.chain = &chain{stages: c.stages, results: results, complexity: c.complexity}
+		d.clientKey, d.site = clientKey, site
+	})
+	if ttl > 0 {
+		store.SetTTL(challengeID, ttl)
+	}
+	task.Stage, task.Stages = len(results)+1, len(c.stages)
+	return task, 0, nil
+}
@@ -0,0 +1,87 @@
+package challenge
+
+import (
+	"errors"
+	"testing"
+)
+
+// Этапы цепочки выдаются под одним ID, пока пройдены предыдущие; итог цепочки —
+// наименьшая уверенность по этапам
+func TestCompleteStage(t *testing.T) {
+	type stage struct {
+		confidence int
+		passed     bool
+	}
+	tests := []struct {
+		name   string
+		kinds  []string
+		stages []stage
+		result int
+	}{
+		{name: "single task", kinds: []string{TypeDragDrop}, stages: []stage{{85, true}}, result: 85},
+		{
+			name:   "all stages passed",
+			kinds:  []string{TypeOddOneOut, TypeDragDrop, TypeRotate},
+			stages: []stage{{90, true}, {70, true}, {80, true}},
+			result: 70,
+		},
+		{
+			name:   "failed stage ends chain",
+			kinds:  []string{TypeOddOneOut, TypeDragDrop, TypeRotate},
+			stages: []stage{{90, true}, {20, false}},
+			result: 20,
+		},
+		{
+			name:   "first stage failed",
+			kinds:  []string{TypeOddOneOut, TypeDragDrop},
+			stages: []stage{{0, false}},
+			result: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			task, err := NewChain(store, nil, tt.kinds, 50)
+			if err != nil {
+				t.Fatalf("NewChain: %v", err)
+			}
+			if task.Stages != len(tt.kinds) {
+				t.Fatalf("got %d stages, want %d", task.Stages, len(tt.kinds))
+			}
+			id := task.ID
+			store.BindClient(id, "client")
+
+			for i, s := range tt.stages {
+				next, result, err := CompleteStage(store, id, s.confidence, s.passed)
+				if err != nil {
+					t.Fatalf("stage %d: %v", i+1, err)
+				}
+				if i < len(tt.stages)-1 {
+					if next == nil {
+						t.Fatalf("stage %d: chain ended early with %d", i+1, result)
+					}
+					if next.ID != id || next.Type != tt.kinds[i+1] || next.Stage != i+2 || next.Complexity != 50 {
+						t.Fatalf("stage %d: got next %s %s stage %d complexity %d", i+1, next.ID, next.Type, next.Stage, next.Complexity)
+					}
+					if store.ClientKey(id) != "client" {
+						t.Fatalf("stage %d: client binding lost", i+1)
+					}
+					continue
+				}
+				if next != nil {
+					t.Fatalf("stage %d: got next stage %s after the chain ended", i+1, next.Type)
+				}
+				if result != tt.result {
+					t.Fatalf("got result %d, want %d", result, tt.result)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteStageUnknownChallenge(t *testing.T) {
+	store, _ := newTestStore()
+	if _, _, err := CompleteStage(store, "missing", 100, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
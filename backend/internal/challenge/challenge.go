@@ -0,0 +1,154 @@
+package challenge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theborzet/captcha_service/pkg/utils"
+)
+
+// Типы заданий, которые умеет выдавать сервис
+const (
+	TypeDragDrop      = "drag-drop-v1"
+	TypePoW           = "pow-v1"
+	TypePoWMemoryHard = "pow-memory-v1"
+	TypeClickSequence = "click-sequence-v1"
+	TypeRotate        = "rotate-v1"
+	TypeOddOneOut     = "odd-one-out-v1"
+	TypeMaze          = "maze-v1"
+	TypeAudio         = "audio-v1"
+	TypeRhythm        = "rhythm-v1"
+)
+
+// defaultTTL — сколько живёт ответ на задание в хранилище
+const defaultTTL = 5 * time.Minute
+
+// ErrNotFound — задания нет в хранилище или оно истекло
+var ErrNotFound = errors.New("CAPTCHA not found or expired")
+
+// Event — событие фронтенда, пришедшее через MakeEventStream
+type Event struct {
+	Name        string `json:"event"`
+	ChallengeID string `json:"challenge_id"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Success     bool   `json:"success"`
+	Data        string `json:"data"` // компактные данные, формат зависит от типа задания
+}
+
+// Reaction — реакция генератора на событие фронтенда
+type Reaction struct {
+	ClientData []byte // частичное обновление задания для клиента (SendClientData)
+	Pushes     []Push // обновления, которые сервер отправит клиенту сам в назначенное время
+	Done       bool   // задание завершено, Confidence содержит итог
+	Confidence int
+}
+
+// Push — отложенное обновление задания для клиента
+type Push struct {
+	At   time.Time
+	Data []byte
+}
+
+// Params — параметры генерации одного задания
+type Params struct {
+	ChallengeID string
+	Complexity  int    // 0-100, см. Generator
+	Nonce       string // nonce из CSP задания, им помечаются все script и style
+
+	obf *obfuscation // случайные имена в разметке и событиях задания
+}
+
+// Task — выданное клиенту задание
+type Task struct {
+	ID         string
+	Type       string
+	Complexity int
+	HTML       string
+	CSP        string // Content-Security-Policy, под которой должен исполняться HTML
+	Stage      int    // номер этапа в цепочке, с 1
+	Stages     int    // сколько всего этапов в цепочке
+
+	nonce string
+}
+
+// Generator — тип задания: генерирует HTML с ответом в хранилище и обрабатывает события решения.
+// Complexity у всех типов одна — по шкале 0-100 из требований, от запроса клиента до
+// профиля риска; каждый генератор сам переводит её в свои параметры.
+type Generator interface {
+	Generate(store *ChallengeStore, p Params) (string, error)
+	HandleEvent(store *ChallengeStore, event Event) (Reaction, error)
+	// SolveWindow — за какое время человек решает задание этой сложности; 0 — без ограничения
+	SolveWindow(complexity int) (time.Duration, time.Duration)
+}
+
+var generators = map[string]Generator{
+	TypeDragDrop:      dragDropGenerator{},
+	TypePoW:           powGenerator{},
+	TypePoWMemoryHard: powGenerator{memoryHard: true},
+	TypeClickSequence: clickSeqGenerator{},
+	TypeRotate:        rotateGenerator{},
+	TypeOddOneOut:     oddOneGenerator{},
+	TypeMaze:          mazeGenerator{},
+	TypeAudio:         audioGenerator{},
+	TypeRhythm:        rhythmGenerator{},
+}
+
+// Known проверяет, что сервис умеет выдавать задания такого типа
+func Known(kind string) bool {
+	_, ok := generators[kind]
+	return ok
+}
+
+// New создаёт задание указанного типа
+func New(store *ChallengeStore, kind string, complexity int) (*Task, error) {
+	return newTask(store, utils.GenerateChallengeID(), kind, complexity)
+}
+
+// newTask генерирует задание под заданным ID; прежнее задание с этим ID заменяется
+func newTask(store *ChallengeStore, challengeID, kind string, complexity int) (*Task, error) {
+	gen, ok := generators[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown challenge type %q", kind)
+	}
+
+	p := Params{
+		ChallengeID: challengeID,
+		Complexity:  complexity,
+		Nonce:       newNonce(),
+		obf:         newObfuscation(),
+	}
+	html, err := gen.Generate(store, p)
+	if err != nil {
+		return nil, err
+	}
+	store.setFields(p.ChallengeID, p.obf.fields())
+	return &Task{
+		ID:         p.ChallengeID,
+		Type:       kind,
+		Complexity: complexity,
+		HTML:       html,
+		CSP:        ContentSecurityPolicy(p.Nonce),
+		Stage:      1,
+		Stages:     1,
+		nonce:      p.Nonce,
+	}, nil
+}
+
+// clampComplexity приводит complexity к шкале 0-100 из требований
+func clampComplexity(complexity int) int {
+	return max(0, min(100, complexity))
+}
+
+// HandleEvent передаёт событие генератору, создавшему задание
+func HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	kind, ok := store.Kind(event.ChallengeID)
+	if !ok {
+		return Reaction{}, ErrNotFound
+	}
+	if event.Name != EventReady && event.Name != EventProbe {
+		store.markInteraction(event.ChallengeID, event, store.Now())
+	}
+	return generators[kind].HandleEvent(store, event)
+}
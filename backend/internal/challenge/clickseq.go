@@ -0,0 +1,198 @@
+package challenge
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// EventClick — клик click-sequence, точка в X и Y. Клики приходят по одному,
+// паузы между ними сервер считает по своим часам: клиент их не сообщает.
+const EventClick = "click"
+
+const (
+	glyphSize        = 30
+	hitPadding       = 4                      // запас вокруг знака, в который ещё засчитывается клик
+	minClickInterval = 120 * time.Millisecond // быстрее человек не переводит взгляд и курсор на следующий знак
+)
+
+var clickSeqBundle = mustBundle("click-sequence")
+
+// clickSeqState — ответ на задание (зоны попадания в порядке, в котором по ним
+// нужно кликнуть) и уже пришедшие клики
+type clickSeqState struct {
+	boxes  []image.Rectangle
+	clicks []click
+}
+
+type clickSeqGenerator struct{}
+
+// params подбирает по complexity 0-100 число знаков в последовательности,
+// число лишних знаков и наибольший угол поворота в градусах
+func (clickSeqGenerator) params(complexity int) (int, int, int) {
+	complexity = clampComplexity(complexity)
+	return 3 + complexity/34, complexity / 25, 15 + complexity*3/5
+}
+
+func (g clickSeqGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	count, extra, maxAngle := g.params(p.Complexity)
+
+	c := render.NewCanvas(canvasWidth, canvasHeight)
+	c.Texture(fieldTextures.Pick())
+
+	st := &clickSeqState{}
+	var placed []image.Rectangle
+	order := make([]string, 0, count)
+	// Цифры 1..count — последовательность, следующие за ними — лишние знаки
+	for d := 1; d <= count+extra; d++ {
+		cx, cy, ok := placeGlyph(placed)
+		if !ok {
+			return "", fmt.Errorf("no room for glyph %d", d)
+		}
+		angle := float64(rand.Intn(2*maxAngle+1)-maxAngle) * math.Pi / 180
+		c.Shape(render.Circle, cx-glyphSize/2-3, cy-glyphSize/2-3, glyphSize+6, render.Color(rand.Intn(render.Hues), 6), 0)
+		rect := c.Digit(d, cx, cy, glyphSize*4/5, angle, render.Color(rand.Intn(render.Hues), 0))
+		placed = append(placed, rect)
+
+		if d <= count {
+			st.boxes = append(st.boxes, rect.Inset(-hitPadding))
+			order = append(order, strconv.Itoa(d))
+		}
+	}
+	c.Noise(p.Complexity / 2)
+
+	store.Set(p.ChallengeID, TypeClickSequence, p.Complexity, st, defaultTTL)
+
+	field, err := c.DataURI()
+	if err != nil {
+		return "", fmt.Errorf("failed to render field: %v", err)
+	}
+	return clickSeqBundle.render(p, map[string]interface{}{
+		"Field": template.URL(field),
+		"Order": strings.Join(order, " → "),
+		"Count": count,
+	})
+}
+
+// placeGlyph ищет на поле место для знака, не пересекающееся с уже нарисованными
+func placeGlyph(placed []image.Rectangle) (int, int, bool) {
+	margin := glyphSize * 2 / 3
+	for try := 0; try < 500; try++ {
+		cx := margin + rand.Intn(canvasWidth-2*margin)
+		cy := margin + rand.Intn(canvasHeight-2*margin)
+		area := image.Rect(cx-margin, cy-margin, cx+margin, cy+margin)
+		free := true
+		for _, r := range placed {
+			if r.Overlaps(area) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return cx, cy, true
+		}
+	}
+	return 0, 0, false
+}
+
+// click — клик пользователя и когда он пришёл на сервер
+type click struct {
+	p  image.Point
+	at time.Time
+}
+
+// HandleEvent копит клики, а когда их столько же, сколько знаков, проверяет порядок
+// и попадание, а затем ритм по времени прихода: слишком быстрые переходы
+// и одинаковые паузы выдают скрипт, как и клики точно в центр
+func (clickSeqGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	if event.Name != EventClick {
+		return Reaction{}, nil
+	}
+
+	now := store.Now()
+	var st clickSeqState
+	if !store.update(event.ChallengeID, func(d *challengeData) {
+		s := d.state.(*clickSeqState)
+		if len(s.clicks) < len(s.boxes) {
+			s.clicks = append(s.clicks, click{p: image.Pt(event.X, event.Y), at: now})
+		}
+		st = *s
+		st.clicks = append([]click(nil), s.clicks...)
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+	if len(st.clicks) < len(st.boxes) {
+		return Reaction{}, nil
+	}
+
+	centered := 0
+	for i, cl := range st.clicks {
+		box := st.boxes[i]
+		if !cl.p.In(box) {
+			return Reaction{Done: true}, nil
+		}
+		center := box.Min.Add(box.Max).Div(2)
+		if abs(cl.p.X-center.X) <= 1 && abs(cl.p.Y-center.Y) <= 1 {
+			centered++
+		}
+	}
+
+	confidence := 100.0
+	if centered == len(st.clicks) {
+		confidence /= 2
+	}
+
+	pauses := make([]float64, 0, len(st.clicks)-1)
+	fast := 0
+	for i := 1; i < len(st.clicks); i++ {
+		pause := st.clicks[i].at.Sub(st.clicks[i-1].at)
+		if pause < minClickInterval {
+			fast++
+		}
+		pauses = append(pauses, float64(pause))
+	}
+	if len(pauses) > 0 {
+		confidence *= float64(len(pauses)-fast) / float64(len(pauses))
+	}
+	if len(pauses) > 1 && variation(pauses) < 0.05 {
+		confidence /= 2
+	}
+
+	return Reaction{Done: true, Confidence: int(confidence)}, nil
+}
+
+// SolveWindow: на каждый знак нужно время, чтобы найти его глазами и кликнуть
+func (g clickSeqGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	count, _, _ := g.params(complexity)
+	return time.Duration(count) * 400 * time.Millisecond, 30*time.Second + time.Duration(count)*2*time.Second
+}
+
+// variation — коэффициент вариации: стандартное отклонение, делённое на среднее
+func variation(values []float64) float64 {
+	var sum, sq float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq/float64(len(values))) / mean
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
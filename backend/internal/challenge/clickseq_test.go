@@ -0,0 +1,107 @@
+package challenge
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// Клики засчитываются по порядку и с попаданием в знаки; скрипт выдают клики
+// точно в центр, слишком быстрые переходы и одинаковые паузы
+func TestClickSeqHandleEvent(t *testing.T) {
+	type tap struct {
+		x, y  int
+		pause time.Duration // пауза перед кликом
+	}
+	tests := []struct {
+		name       string
+		taps       []tap
+		done       bool
+		confidence int
+	}{
+		{
+			name: "human clicks",
+			taps: []tap{{27, 33, 0}, {124, 28, 420 * time.Millisecond}, {215, 35, 690 * time.Millisecond}},
+			done: true, confidence: 100,
+		},
+		{
+			name: "wrong order",
+			taps: []tap{{120, 30, 0}, {30, 30, 420 * time.Millisecond}, {220, 30, 690 * time.Millisecond}},
+			done: true,
+		},
+		{
+			name: "miss",
+			taps: []tap{{27, 33, 0}, {124, 28, 420 * time.Millisecond}, {215, 90, 690 * time.Millisecond}},
+			done: true,
+		},
+		{
+			name: "all clicks in centers",
+			taps: []tap{{30, 30, 0}, {121, 30, 420 * time.Millisecond}, {220, 29, 690 * time.Millisecond}},
+			done: true, confidence: 50,
+		},
+		{
+			name: "too fast transition",
+			taps: []tap{{27, 33, 0}, {124, 28, 420 * time.Millisecond}, {215, 35, 60 * time.Millisecond}},
+			done: true, confidence: 50,
+		},
+		{
+			name: "even pauses",
+			taps: []tap{{27, 33, 0}, {124, 28, 500 * time.Millisecond}, {215, 35, 500 * time.Millisecond}},
+			done: true, confidence: 50,
+		},
+		{
+			name: "incomplete sequence",
+			taps: []tap{{27, 33, 0}, {124, 28, 420 * time.Millisecond}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			store.Set("c1", TypeClickSequence, 50, &clickSeqState{boxes: []image.Rectangle{
+				image.Rect(10, 10, 50, 50),
+				image.Rect(100, 10, 140, 50),
+				image.Rect(200, 10, 240, 50),
+			}}, defaultTTL)
+
+			var r Reaction
+			for _, tp := range tt.taps {
+				clock.advance(tp.pause)
+				var err error
+				r, err = clickSeqGenerator{}.HandleEvent(store, Event{Name: EventClick, ChallengeID: "c1", X: tp.x, Y: tp.y})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if r.Done != tt.done || r.Confidence != tt.confidence {
+				t.Fatalf("got done=%v confidence=%d, want done=%v confidence=%d", r.Done, r.Confidence, tt.done, tt.confidence)
+			}
+		})
+	}
+}
+
+// На самой высокой сложности на поле должно помещаться до 10 знаков: задание
+// не может падать из-за того, что место кончилось
+func TestPlaceGlyphFitsAtMaxComplexity(t *testing.T) {
+	count, extra, _ := clickSeqGenerator{}.params(100)
+	glyphs := max(count+extra, 10)
+	for trial := 0; trial < 5000; trial++ {
+		var placed []image.Rectangle
+		for i := 0; i < glyphs; i++ {
+			cx, cy, ok := placeGlyph(placed)
+			if !ok {
+				t.Fatalf("trial %d: no room for glyph %d of %d", trial, i+1, glyphs)
+			}
+			// Повёрнутый знак с подложкой занимает не больше этого квадрата
+			placed = append(placed, image.Rect(cx-glyphSize/2-5, cy-glyphSize/2-5, cx+glyphSize/2+5, cy+glyphSize/2+5))
+		}
+	}
+}
+
+func TestClickSeqGenerateMaxComplexity(t *testing.T) {
+	store, _ := newTestStore()
+	for i := 0; i < 50; i++ {
+		if _, err := (clickSeqGenerator{}).Generate(store, Params{ChallengeID: "c1", Complexity: 100, Nonce: "n"}); err != nil {
+			t.Fatalf("generation %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package challenge
+
+import "encoding/binary"
+
+// Координаты упаковываются в 13 бит каждая (максимум 8192x8192),
+// так что точка целиком помещается в один uint32.
+const (
+	coordBits = 13
+	coordMask = 1<<coordBits - 1
+)
+
+func packPoint(x, y int) uint32 {
+	return uint32(x&coordMask)<<coordBits | uint32(y&coordMask)
+}
+
+// appendBlob дописывает в буфер операцию вида [op][длина big-endian][данные]
+func appendBlob(buf []byte, op byte, data []byte) []byte {
+	buf = append(buf, op)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	return append(buf, data...)
+}
@@ -0,0 +1,115 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// newNonce генерирует nonce для CSP одного задания
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// ContentSecurityPolicy — строгая политика для HTML задания: исполняются только
+// скрипты и стили с nonce задания, картинки и звук — только из data: URI, воркеры —
+// только из blob:, сетевые запросы и внешние ресурсы запрещены
+func ContentSecurityPolicy(nonce string) string {
+	return fmt.Sprintf("default-src 'none'; script-src 'nonce-%[1]s'; style-src 'nonce-%[1]s'; "+
+		"img-src data:; media-src data:; worker-src blob:; base-uri 'none'; form-action 'none'", nonce)
+}
+
+// ValidateHTML проверяет, что HTML задания работает под ContentSecurityPolicy(nonce):
+// у каждого script и style есть nonce, нет inline-стилей и обработчиков в атрибутах
+// и нет ссылок на внешние ресурсы
+func ValidateHTML(doc, nonce string) error {
+	z := html.NewTokenizer(strings.NewReader(doc))
+	inStyle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return nil
+			}
+			return z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "script" || tok.Data == "style" {
+				if attr(tok, "nonce") != nonce {
+					return fmt.Errorf("<%s> without challenge nonce", tok.Data)
+				}
+				inStyle = tok.Data == "style"
+			}
+			for _, a := range tok.Attr {
+				switch {
+				case a.Key == "style":
+					return fmt.Errorf("inline style attribute on <%s>", tok.Data)
+				case strings.HasPrefix(a.Key, "on"):
+					return fmt.Errorf("inline handler %s on <%s>", a.Key, tok.Data)
+				case (a.Key == "src" || a.Key == "href" || a.Key == "action") && !strings.HasPrefix(a.Val, "data:"):
+					return fmt.Errorf("external resource %s=%q on <%s>", a.Key, a.Val, tok.Data)
+				}
+			}
+		case html.EndTagToken:
+			inStyle = false
+		case html.TextToken:
+			if !inStyle {
+				continue
+			}
+			css := strings.ToLower(string(z.Text()))
+			if strings.Contains(css, "@import") {
+				return errors.New("@import in challenge style")
+			}
+			for rest := css; ; {
+				_, after, found := strings.Cut(rest, "url(")
+				if !found {
+					break
+				}
+				if ref := strings.TrimLeft(after, ` "'`); !strings.HasPrefix(ref, "data:") {
+					return fmt.Errorf("external url() in challenge style")
+				}
+				rest = after
+			}
+		}
+	}
+}
+
+// VerifyGenerators генерирует по заданию каждого типа и проверяет его HTML по ValidateHTML.
+// Проверяется тестами, чтобы генератор с внешними ресурсами или без nonce не попал в работу.
+// Возвращает наибольший размер HTML в байтах по каждому типу, чтобы следить за весом заданий.
+func VerifyGenerators() (map[string]int, error) {
+	store := NewStoreWithClock(time.Now)
+	sizes := make(map[string]int, len(generators))
+	for kind := range generators {
+		for _, complexity := range []int{0, 1, 3, 50, 100} {
+			task, err := New(store, kind, complexity)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", kind, err)
+			}
+			if err := ValidateHTML(task.HTML, task.nonce); err != nil {
+				return nil, fmt.Errorf("%s (complexity %d): %w", kind, complexity, err)
+			}
+			sizes[kind] = max(sizes[kind], len(task.HTML))
+		}
+	}
+	return sizes, nil
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
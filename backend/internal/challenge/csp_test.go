@@ -0,0 +1,57 @@
+package challenge
+
+import (
+	"strings"
+	"testing"
+)
+
+// Все генераторы должны выдавать HTML, который работает под строгой CSP задания
+func TestGeneratorsPassCSP(t *testing.T) {
+	sizes, err := VerifyGenerators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for kind := range generators {
+		if sizes[kind] == 0 {
+			t.Errorf("%s: no HTML generated", kind)
+		}
+	}
+}
+
+func TestValidateHTML(t *testing.T) {
+	const nonce = "n0nce"
+	tests := []struct {
+		name string
+		doc  string
+		err  string // подстрока ошибки, пусто — HTML допустим
+	}{
+		{
+			name: "nonced script and style",
+			doc:  `<style nonce="n0nce">.a{background:url("data:image/png;base64,AA")}</style><div class="a"><img src="data:image/png;base64,AA"></div><script nonce="n0nce">go()</script>`,
+		},
+		{name: "script without nonce", doc: `<script>go()</script>`, err: "<script> without challenge nonce"},
+		{name: "script with foreign nonce", doc: `<script nonce="other">go()</script>`, err: "<script> without challenge nonce"},
+		{name: "style without nonce", doc: `<style>.a{}</style>`, err: "<style> without challenge nonce"},
+		{name: "inline handler", doc: `<button onclick="go()">ok</button>`, err: "inline handler onclick"},
+		{name: "inline style attribute", doc: `<div style="color:red"></div>`, err: "inline style attribute"},
+		{name: "external image", doc: `<img src="https://evil.example/x.png">`, err: "external resource src"},
+		{name: "external script", doc: `<script nonce="n0nce" src="/x.js"></script>`, err: "external resource src"},
+		{name: "external stylesheet", doc: `<link rel="stylesheet" href="https://evil.example/x.css">`, err: "external resource href"},
+		{name: "form action", doc: `<form action="/collect"></form>`, err: "external resource action"},
+		{name: "css import", doc: `<style nonce="n0nce">@import "x.css";</style>`, err: "@import"},
+		{name: "css external url", doc: `<style nonce="n0nce">.a{background:url(https://evil.example/x.png)}</style>`, err: "external url()"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHTML(tt.doc, nonce)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("expected error containing %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("error %q does not contain %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -3,71 +3,184 @@ package challenge
 import (
 	"fmt"
 	"html/template"
+	"image"
+	"math"
 	"math/rand"
-	"strconv"
-	"strings"
 	"time"
 
-	"github.com/theborzet/captcha_service/pkg/utils"
+	"github.com/theborzet/captcha_service/internal/render"
 )
 
 const (
-	dragDropTemplate = `
-		<div id="captcha-container" data-challenge-id="{{.ChallengeID}}" data-target-x="{{.TargetX}}" data-target-y="{{.TargetY}}" data-complexity="{{.Complexity}}">
-		<div id="target" style="position: absolute; left: {{.TargetX}}px; top: {{.TargetY}}px; width: {{printf "%.0f" (div 60 (int .Complexity))}}px; height: {{printf "%.0f" (div 60 (int .Complexity))}}px;"></div>
-		<div id="draggable" style="position: absolute; left: 0px; top: 0px; width: 60px; height: 60px;"></div>
-		</div>
-	`
-	answer = "success"
+	// Поле задания и область, в которой может оказаться цель (поле минус размер элемента)
+	canvasWidth  = 320
+	canvasHeight = 200
+	fieldWidth   = 260
+	fieldHeight  = 140
+
+	minTargetSize = 12
+	maxLevel      = 3 // уровней сложности у drag-drop задания
 )
 
-func GenerateDragDropChallenge(store *ChallengeStore, complexity int) (string, string, error) {
-	challengeID := utils.GenerateChallengeID()
-	rand.Seed(time.Now().UnixNano())
-	targetX := rand.Intn(260)
-	targetY := rand.Intn(140)
+// dropTolerance — на каком расстоянии от цели по уровням сброс ещё засчитывается;
+// пройден он на половине этого расстояния. Допуск меньше размера фигуры, чтобы
+// сброс наугад проходил не чаще чем в 2% случаев.
+var dropTolerance = [maxLevel]float64{30, 20, 14}
 
-	store.Set(challengeID, answer, targetX, targetY, complexity, 5*time.Minute)
+var (
+	dragDropBundle = mustBundle("drag-drop")
+	fieldTextures  = render.NewTextureCache(canvasWidth, canvasHeight, 16)
+)
 
-	// Создаём новый шаблон и регистрируем функции
-	tmpl := template.New("captcha").Funcs(template.FuncMap{
-		"div": func(a, b int) float64 {
-			if b == 0 {
-				return 0
-			}
-			return float64(a) / float64(b)
-		},
-		"printf": func(format string, a ...interface{}) string {
-			return fmt.Sprintf(format, a...)
-		},
-		"int": func(v interface{}) int {
-			switch v := v.(type) {
-			case int:
-				return v
-			case string:
-				i, _ := strconv.Atoi(v)
-				return i
-			default:
-				return 0
-			}
-		},
-	})
-	tmpl, err := tmpl.Parse(dragDropTemplate)
+// dragDropState — ответ на drag-drop задание: текущая позиция цели и то, как нарисовано поле
+type dragDropState struct {
+	x, y   int
+	shape  render.Shape
+	hue    int // оттенок перетаскиваемой фигуры
+	ring   int // оттенок контуров цели и ложных целей, всегда другой, чем у фигуры
+	decoys []decoy
+}
+
+// decoy — ложная цель другой формы, нарисованная на поле
+type decoy struct {
+	x, y  int
+	shape render.Shape
+}
+
+type dragDropGenerator struct{}
+
+func (dragDropGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	st := &dragDropState{
+		x:     rand.Intn(fieldWidth),
+		y:     rand.Intn(fieldHeight),
+		shape: render.Shapes[rand.Intn(len(render.Shapes))],
+		hue:   rand.Intn(render.Hues),
+	}
+	level := dragDropLevel(p.Complexity)
+	st.ring = otherHue(st.hue)
+	st.decoys = placeDecoys(st, level)
+	store.Set(p.ChallengeID, TypeDragDrop, p.Complexity, st, defaultTTL)
+
+	// Цель нарисована прямо на картинке поля, в разметке её позиции нет
+	field, err := renderField(st, level)
+	if err != nil {
+		return "", fmt.Errorf("failed to render field: %v", err)
+	}
+	piece, err := renderPiece(st, level)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse template: %v", err)
+		return "", fmt.Errorf("failed to render piece: %v", err)
 	}
 
-	var htmlBuilder strings.Builder
-	err = tmpl.Execute(&htmlBuilder, map[string]interface{}{
-		"ChallengeID": challengeID,
-		"TargetX":     strconv.Itoa(targetX),
-		"TargetY":     strconv.Itoa(targetY),
-		"Complexity":  strconv.Itoa(complexity),
+	return dragDropBundle.render(p, map[string]interface{}{
+		"Field":     template.URL(render.DataURI(field)),
+		"Piece":     template.URL(piece),
+		"PieceSize": targetSize(level),
 	})
-	if err != nil {
-		return "", "", fmt.Errorf("failed to execute template: %v", err)
+}
+
+// dragDropLevel переводит complexity 0-100 в уровень drag-drop задания 1-3. Уровень
+// задаёт размер цели, допуск при сбросе, искажения и число подмен задания; выше
+// третьего цель становится меньше, чем человек успевает точно навести.
+func dragDropLevel(complexity int) int {
+	return 1 + clampComplexity(complexity)*maxLevel/101
+}
+
+// placeDecoys расставляет ложные цели других форм, не перекрывая цель и друг друга.
+// Их контуры того же цвета и размера, что и контур цели, поэтому отличить цель
+// можно только по форме; с уровнем задания ложных целей больше.
+func placeDecoys(st *dragDropState, level int) []decoy {
+	size := targetSize(level)
+	taken := []image.Rectangle{image.Rect(st.x, st.y, st.x+size, st.y+size)}
+	decoys := make([]decoy, 0, level+1)
+	for len(decoys) < level+1 {
+		d := decoy{shape: otherShape(st.shape)}
+		// Свободное место ищется несколько раз, на тесном поле ложная цель может лечь внахлёст
+		for attempt := 0; attempt < 20; attempt++ {
+			d.x, d.y = rand.Intn(fieldWidth), rand.Intn(fieldHeight)
+			if !overlaps(image.Rect(d.x, d.y, d.x+size, d.y+size), taken) {
+				break
+			}
+		}
+		taken = append(taken, image.Rect(d.x, d.y, d.x+size, d.y+size))
+		decoys = append(decoys, d)
+	}
+	return decoys
+}
+
+func overlaps(r image.Rectangle, taken []image.Rectangle) bool {
+	for _, t := range taken {
+		if r.Overlaps(t) {
+			return true
+		}
 	}
+	return false
+}
+
+// otherHue выбирает оттенок, отличный от hue
+func otherHue(hue int) int {
+	return (hue + 1 + rand.Intn(render.Hues-1)) % render.Hues
+}
+
+// targetSize — размер цели и перетаскиваемой фигуры, с ростом уровня они мельче,
+// но не меньше minTargetSize, чтобы фигуру ещё можно было различить
+func targetSize(level int) int {
+	return max(minTargetSize, 60/level)
+}
+
+// renderField рисует поле: свой для каждой картинки фон, контур цели и ложные цели,
+// искажённые и зашумлённые тем сильнее, чем выше уровень. Фон не берётся из кэша
+// текстур: общий фон можно было бы вычесть и найти на поле всё нарисованное поверх.
+func renderField(st *dragDropState, level int) ([]byte, error) {
+	size := targetSize(level)
+	amp := float64(level) + 1
+	outline := render.Color(st.ring, 1)
+
+	c := render.NewCanvas(canvasWidth, canvasHeight)
+	c.Texture(render.NewTexture(canvasWidth, canvasHeight))
+	for _, d := range st.decoys {
+		c.Distort(c.Shape(d.shape, d.x, d.y, size, outline, 3).Inset(-int(amp)), amp)
+	}
+	c.Distort(c.Shape(st.shape, st.x, st.y, size, outline, 3).Inset(-int(amp)), amp)
+	c.Noise(min(100, level*30))
+	return c.PNG()
+}
+
+// renderPiece рисует фигуру, которую пользователь перетаскивает на цель
+func renderPiece(st *dragDropState, level int) (string, error) {
+	size := targetSize(level)
+	c := render.NewCanvas(size, size)
+	c.Shape(st.shape, 0, 0, size, render.Color(st.hue, 3), 0)
+	c.Distort(c.Image().Bounds(), float64(level)/2)
+	return c.DataURI()
+}
+
+func (g dragDropGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	// Промежуточные события не завершают задание, но могут его изменить
+	if event.Name != "" && event.Name != EventDragDrop {
+		data, _ := mutateDragDrop(store, event.ChallengeID, event.Name)
+		return Reaction{ClientData: data}, nil
+	}
+
+	var expectedX, expectedY, complexity int
+	if !store.view(event.ChallengeID, func(d *challengeData) {
+		st := d.state.(*dragDropState)
+		expectedX, expectedY, complexity = st.x, st.y, d.complexity
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+
+	maxDistance := dropTolerance[dragDropLevel(complexity)-1]
+	distance := math.Sqrt(math.Pow(float64(event.X-expectedX), 2) + math.Pow(float64(event.Y-expectedY), 2))
+	confidence := 0
+	if distance <= maxDistance && event.Success {
+		confidence = 100 - int(distance*100/maxDistance)
+	}
+
+	return Reaction{Done: true, Confidence: confidence}, nil
+}
 
-	html := htmlBuilder.String()
-	return challengeID, html, nil
+// SolveWindow: перетащить элемент быстрее чем за полсекунды человек не успевает,
+// а с ростом сложности цель мельче и прицеливание занимает больше времени
+func (dragDropGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	return 400*time.Millisecond + time.Duration(dragDropLevel(complexity))*150*time.Millisecond, time.Minute
 }
@@ -0,0 +1,81 @@
+package challenge
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClock — часы хранилища, которые тест переводит сам
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*ChallengeStore, *testClock) {
+	clock := &testClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return NewStoreWithClock(clock.now), clock
+}
+
+// Сброс засчитывается только рядом с целью и тем увереннее, чем ближе;
+// допуск сужается с уровнем задания
+func TestDragDropHandleEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		complexity int
+		event      Event
+		done       bool
+		confidence int
+	}{
+		{name: "exact drop", event: Event{Name: EventDragDrop, X: 100, Y: 80, Success: true}, done: true, confidence: 100},
+		{name: "unnamed event is a drop", event: Event{X: 100, Y: 80, Success: true}, done: true, confidence: 100},
+		{name: "within tolerance", event: Event{Name: EventDragDrop, X: 110, Y: 80, Success: true}, done: true, confidence: 67},
+		{name: "beyond tolerance", event: Event{Name: EventDragDrop, X: 131, Y: 80, Success: true}, done: true},
+		{name: "drop outside the field", event: Event{Name: EventDragDrop, X: 100, Y: 80}, done: true},
+		{name: "tolerance shrinks with level", complexity: 100, event: Event{Name: EventDragDrop, X: 115, Y: 80, Success: true}, done: true},
+		{name: "hard level near target", complexity: 100, event: Event{Name: EventDragDrop, X: 107, Y: 80, Success: true}, done: true, confidence: 50},
+		{name: "intermediate event", event: Event{Name: "drag_move", X: 100, Y: 80, Success: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			store.Set("c1", TypeDragDrop, tt.complexity, &dragDropState{x: 100, y: 80}, defaultTTL)
+			tt.event.ChallengeID = "c1"
+
+			r, err := dragDropGenerator{}.HandleEvent(store, tt.event)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.Done != tt.done || r.Confidence != tt.confidence {
+				t.Fatalf("got done=%v confidence=%d, want done=%v confidence=%d", r.Done, r.Confidence, tt.done, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestDragDropUnknownChallenge(t *testing.T) {
+	store, _ := newTestStore()
+	_, err := dragDropGenerator{}.HandleEvent(store, Event{Name: EventDragDrop, ChallengeID: "missing", Success: true})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+// Сгенерированное задание проходится сбросом в сохранённую цель
+func TestDragDropGenerateSolvable(t *testing.T) {
+	for _, complexity := range []int{0, 50, 100} {
+		store, _ := newTestStore()
+		if _, err := (dragDropGenerator{}).Generate(store, Params{ChallengeID: "c1", Complexity: complexity, Nonce: "n"}); err != nil {
+			t.Fatalf("complexity %d: %v", complexity, err)
+		}
+		var x, y int
+		store.view("c1", func(d *challengeData) {
+			st := d.state.(*dragDropState)
+			x, y = st.x, st.y
+		})
+		r, err := dragDropGenerator{}.HandleEvent(store, Event{Name: EventDragDrop, ChallengeID: "c1", X: x, Y: y, Success: true})
+		if err != nil || !r.Done || r.Confidence != 100 {
+			t.Fatalf("complexity %d: got %+v, %v", complexity, r, err)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package challenge
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"image"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// События maze: путь приходит кусками по мере рисования. Первый кусок начинается
+// с точки старта x<<13|y (uint32 big-endian), дальше в каждом куске идут смещения
+// dx, dy по int8. trace_end завершает путь. Данные в base64.
+const (
+	EventTrace    = "trace"
+	EventTraceEnd = "trace_end"
+)
+
+const (
+	// Стены клетки лабиринта
+	wallN uint8 = 1 << iota
+	wallE
+	wallS
+	wallW
+)
+
+const (
+	mazeWallWidth = 3
+	mazeSlack     = 3 // на сколько пикселей нужно зайти в соседнюю клетку, чтобы переход засчитался
+	// Доля одинаковых смещений подряд, выше которой путь считается нарисованным скриптом
+	maxRepeatRatio = 0.5
+	maxStepLength  = 40 // длиннее отдельного смещения рука за один отсчёт не двигается
+)
+
+var mazeBundle = mustBundle("maze")
+
+// mazeState — лабиринт и состояние проверки пути. Путь проверяется по мере прихода
+// кусков, поэтому хранится только текущая точка и статистика смещений.
+type mazeState struct {
+	cols, rows, cell int
+	origin           image.Point // левый верхний угол лабиринта на картинке
+	walls            []uint8
+
+	started bool
+	broken  bool // путь прошёл сквозь стену или вышел за лабиринт
+	pos     image.Point
+	cur     image.Point // клетка, в которой сейчас путь
+
+	steps, repeats int
+	prev           image.Point
+	sumLen, sumSq  float64
+}
+
+type mazeGenerator struct{}
+
+// params подбирает по complexity 0-100 число столбцов и строк лабиринта
+func (mazeGenerator) params(complexity int) (int, int) {
+	complexity = clampComplexity(complexity)
+	return 6 + complexity/10, 4 + complexity/15
+}
+
+func (g mazeGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	cols, rows := g.params(p.Complexity)
+	cell := min((canvasWidth-20)/cols, (canvasHeight-20)/rows)
+	st := &mazeState{
+		cols:   cols,
+		rows:   rows,
+		cell:   cell,
+		origin: image.Pt((canvasWidth-cols*cell)/2, (canvasHeight-rows*cell)/2),
+		walls:  carveMaze(cols, rows),
+	}
+
+	c := render.NewCanvas(canvasWidth, canvasHeight)
+	c.Texture(fieldTextures.Pick())
+	hue := rand.Intn(render.Hues)
+	wall := render.Color(hue, 0)
+	for r := 0; r < rows; r++ {
+		for col := 0; col < cols; col++ {
+			box := st.cellRect(col, r)
+			w := st.walls[r*cols+col]
+			if w&wallN != 0 {
+				c.Rect(image.Rect(box.Min.X-1, box.Min.Y-1, box.Max.X+2, box.Min.Y+mazeWallWidth-1), wall)
+			}
+			if w&wallW != 0 {
+				c.Rect(image.Rect(box.Min.X-1, box.Min.Y-1, box.Min.X+mazeWallWidth-1, box.Max.Y+2), wall)
+			}
+			if w&wallS != 0 && r == rows-1 {
+				c.Rect(image.Rect(box.Min.X-1, box.Max.Y-1, box.Max.X+2, box.Max.Y+mazeWallWidth-1), wall)
+			}
+			if w&wallE != 0 && col == cols-1 {
+				c.Rect(image.Rect(box.Max.X-1, box.Min.Y-1, box.Max.X+mazeWallWidth-1, box.Max.Y+2), wall)
+			}
+		}
+	}
+	marker := cell / 2
+	start, exit := st.cellRect(0, 0), st.cellRect(cols-1, rows-1)
+	c.Shape(render.Circle, start.Min.X+cell/4, start.Min.Y+cell/4, marker, render.Color(hue+3, 2), 0)
+	c.Shape(render.Star, exit.Min.X+cell/4, exit.Min.Y+cell/4, marker, render.Color(hue+5, 2), 0)
+	c.Noise(clampComplexity(p.Complexity) / 4)
+
+	store.Set(p.ChallengeID, TypeMaze, p.Complexity, st, defaultTTL)
+
+	field, err := c.DataURI()
+	if err != nil {
+		return "", fmt.Errorf("failed to render maze: %v", err)
+	}
+	return mazeBundle.render(p, map[string]interface{}{
+		"Maze": template.URL(field),
+	})
+}
+
+// carveMaze строит идеальный лабиринт обходом в глубину со случайным выбором соседа
+func carveMaze(cols, rows int) []uint8 {
+	walls := make([]uint8, cols*rows)
+	for i := range walls {
+		walls[i] = wallN | wallE | wallS | wallW
+	}
+	visited := make([]bool, cols*rows)
+	stack := []int{0}
+	visited[0] = true
+
+	type move struct {
+		dc, dr     int
+		from, into uint8
+	}
+	moves := []move{{0, -1, wallN, wallS}, {1, 0, wallE, wallW}, {0, 1, wallS, wallN}, {-1, 0, wallW, wallE}}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		col, row := i%cols, i/cols
+		var next []move
+		for _, m := range moves {
+			c, r := col+m.dc, row+m.dr
+			if c >= 0 && r >= 0 && c < cols && r < rows && !visited[r*cols+c] {
+				next = append(next, m)
+			}
+		}
+		if len(next) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		m := next[rand.Intn(len(next))]
+		j := (row+m.dr)*cols + col + m.dc
+		walls[i] &^= m.from
+		walls[j] &^= m.into
+		visited[j] = true
+		stack = append(stack, j)
+	}
+	return walls
+}
+
+func (st *mazeState) cellRect(col, row int) image.Rectangle {
+	corner := st.origin.Add(image.Pt(col*st.cell, row*st.cell))
+	return image.Rectangle{Min: corner, Max: corner.Add(image.Pt(st.cell, st.cell))}
+}
+
+// cellAt возвращает клетку, в которую попадает точка, и false за пределами лабиринта
+func (st *mazeState) cellAt(p image.Point) (image.Point, bool) {
+	d := p.Sub(st.origin)
+	if d.X < 0 || d.Y < 0 {
+		return image.Point{}, false
+	}
+	c := image.Pt(d.X/st.cell, d.Y/st.cell)
+	return c, c.X < st.cols && c.Y < st.rows
+}
+
+// open проверяет, что между соседними клетками нет стены
+func (st *mazeState) open(a, b image.Point) bool {
+	w := st.walls[a.Y*st.cols+a.X]
+	switch b.Sub(a) {
+	case image.Pt(0, -1):
+		return w&wallN == 0
+	case image.Pt(1, 0):
+		return w&wallE == 0
+	case image.Pt(0, 1):
+		return w&wallS == 0
+	case image.Pt(-1, 0):
+		return w&wallW == 0
+	}
+	return false
+}
+
+// moveTo проводит путь в точку p попиксельно, переходя в соседнюю клетку только когда
+// точка зашла в неё глубже mazeSlack: так дрожание руки у стены не считается проходом сквозь неё
+func (st *mazeState) moveTo(p image.Point) {
+	d := p.Sub(st.pos)
+	n := max(abs(d.X), abs(d.Y))
+	for i := 1; i <= n && !st.broken; i++ {
+		q := st.pos.Add(image.Pt(d.X*i/n, d.Y*i/n))
+		c, ok := st.cellAt(q)
+		if !ok {
+			st.broken = true
+			break
+		}
+		if c == st.cur || !q.In(st.cellRect(c.X, c.Y).Inset(mazeSlack)) {
+			continue
+		}
+		// Угол клетки по диагонали проходим через одну из двух общих соседних клеток
+		step := c.Sub(st.cur)
+		switch {
+		case abs(step.X)+abs(step.Y) == 1:
+			st.broken = !st.open(st.cur, c)
+		case abs(step.X) == 1 && abs(step.Y) == 1:
+			viaX, viaY := image.Pt(c.X, st.cur.Y), image.Pt(st.cur.X, c.Y)
+			st.broken = !(st.open(st.cur, viaX) && st.open(viaX, c)) && !(st.open(st.cur, viaY) && st.open(viaY, c))
+		default:
+			st.broken = true
+		}
+		st.cur = c
+	}
+	st.pos = p
+}
+
+// trace применяет кусок пути и копит статистику смещений
+func (st *mazeState) trace(raw []byte) {
+	if !st.started {
+		if len(raw) < 4 {
+			st.broken = true
+			return
+		}
+		packed := binary.BigEndian.Uint32(raw)
+		st.pos = image.Pt(int(packed>>coordBits&coordMask), int(packed&coordMask))
+		st.started = true
+		if c, ok := st.cellAt(st.pos); !ok || c != (image.Point{}) {
+			st.broken = true
+		}
+		raw = raw[4:]
+	}
+	for i := 0; i+1 < len(raw) && !st.broken; i += 2 {
+		d := image.Pt(int(int8(raw[i])), int(int8(raw[i+1])))
+		if d == st.prev {
+			st.repeats++
+		}
+		st.prev = d
+		st.steps++
+		length := math.Hypot(float64(d.X), float64(d.Y))
+		st.sumLen += length
+		st.sumSq += length * length
+		st.moveTo(st.pos.Add(d))
+	}
+}
+
+// HandleEvent проигрывает путь по стенам лабиринта по мере его прихода, а в конце
+// проверяет, что путь дошёл до выхода и не выглядит нарисованным по линейке:
+// одинаковые смещения подряд и ровная скорость выдают скрипт
+func (g mazeGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	if event.Name != EventTrace && event.Name != EventTraceEnd {
+		return Reaction{}, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(event.Data)
+	if err != nil {
+		return Reaction{Done: true}, nil
+	}
+
+	var st mazeState
+	if !store.update(event.ChallengeID, func(d *challengeData) {
+		s := d.state.(*mazeState)
+		s.trace(raw)
+		st = *s
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+
+	if event.Name == EventTrace && !st.broken {
+		return Reaction{}, nil
+	}
+	if st.broken || st.cur != image.Pt(st.cols-1, st.rows-1) || st.steps < 2 {
+		return Reaction{Done: true}, nil
+	}
+
+	confidence := 100.0
+	mean := st.sumLen / float64(st.steps)
+	if mean > maxStepLength {
+		return Reaction{Done: true}, nil
+	}
+	if ratio := float64(st.repeats) / float64(st.steps-1); ratio > maxRepeatRatio {
+		confidence *= math.Pow((1-ratio)/(1-maxRepeatRatio), 2)
+	}
+	if mean > 0 && math.Sqrt(max(0, st.sumSq/float64(st.steps)-mean*mean))/mean < 0.1 {
+		confidence /= 2
+	}
+	return Reaction{Done: true, Confidence: int(confidence)}, nil
+}
+
+// SolveWindow: путь через лабиринт прокладывается по клеткам, на каждую уходит время
+func (g mazeGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	cols, rows := g.params(complexity)
+	return time.Duration(cols+rows) * 150 * time.Millisecond, time.Minute + time.Duration(cols*rows)*time.Second
+}
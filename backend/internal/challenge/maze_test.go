@@ -0,0 +1,117 @@
+package challenge
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+// Путь проигрывается по стенам лабиринта: сквозь стену и мимо выхода он не
+// засчитывается, а одинаковые смещения и ровная скорость выдают скрипт
+func TestMazeHandleEvent(t *testing.T) {
+	// Лабиринт 2x2 с клетками 60x60 от (10, 10): из старта (0,0) проход направо
+	// в (1,0) и оттуда вниз к выходу (1,1), клетка (0,1) замурована
+	walls := []uint8{
+		wallN | wallS | wallW, wallN | wallE,
+		wallN | wallE | wallS | wallW, wallE | wallS | wallW,
+	}
+	steps := func(dx, dy []int) []image.Point {
+		var s []image.Point
+		for i := range dx {
+			s = append(s, image.Pt(dx[i], dy[i]))
+		}
+		return s
+	}
+	repeat := func(d image.Point, n int) []image.Point {
+		s := make([]image.Point, n)
+		for i := range s {
+			s[i] = d
+		}
+		return s
+	}
+	zeros := make([]int, 10)
+	right := steps([]int{3, 5, 7, 4, 6, 8, 7, 9, 5, 6}, zeros)
+	down := steps(zeros[:9], []int{4, 6, 9, 5, 7, 9, 8, 3, 9})
+	var evenRight, evenDown []image.Point
+	for i := 0; i < 6; i++ {
+		evenRight = append(evenRight, image.Pt(5, 1), image.Pt(5, -1))
+		evenDown = append(evenDown, image.Pt(1, 5), image.Pt(-1, 5))
+	}
+
+	tests := []struct {
+		name       string
+		start      image.Point
+		trace      []image.Point // смещения в первом куске, после точки старта
+		end        []image.Point // смещения в trace_end
+		early      bool          // задание завершается уже на первом куске
+		confidence int
+	}{
+		{name: "human path", start: image.Pt(40, 40), trace: right, end: down, confidence: 100},
+		{name: "through wall", start: image.Pt(40, 40), trace: down, end: right, early: true},
+		{name: "stops before exit", start: image.Pt(40, 40), trace: right[:5], end: right[5:]},
+		{name: "leaves the maze", start: image.Pt(40, 40), trace: steps([]int{-20, -20}, []int{0, 0}), early: true},
+		{name: "starts outside start cell", start: image.Pt(100, 40), trace: down, early: true},
+		{name: "too long steps", start: image.Pt(40, 40), trace: steps([]int{75, -15}, []int{0, 0}), end: steps([]int{0}, []int{60})},
+		{name: "ruler path", start: image.Pt(40, 40), trace: repeat(image.Pt(5, 0), 12), end: repeat(image.Pt(0, 5), 12)},
+		{name: "even speed", start: image.Pt(40, 40), trace: evenRight, end: evenDown, confidence: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			store.Set("c1", TypeMaze, 0, &mazeState{
+				cols: 2, rows: 2, cell: 60, origin: image.Pt(10, 10),
+				walls: append([]uint8(nil), walls...),
+			}, defaultTTL)
+
+			first := binary.BigEndian.AppendUint32(nil, packPoint(tt.start.X, tt.start.Y))
+			r, err := mazeGenerator{}.HandleEvent(store, Event{Name: EventTrace, ChallengeID: "c1", Data: encodeSteps(first, tt.trace)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.Done != tt.early {
+				t.Fatalf("first chunk: got done=%v, want %v", r.Done, tt.early)
+			}
+			if !r.Done {
+				r, err = mazeGenerator{}.HandleEvent(store, Event{Name: EventTraceEnd, ChallengeID: "c1", Data: encodeSteps(nil, tt.end)})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if !r.Done || r.Confidence != tt.confidence {
+				t.Fatalf("got done=%v confidence=%d, want done confidence=%d", r.Done, r.Confidence, tt.confidence)
+			}
+		})
+	}
+}
+
+// Сгенерированный лабиринт всегда проходим: из старта есть путь до выхода
+func TestCarveMazeConnected(t *testing.T) {
+	for _, complexity := range []int{0, 50, 100} {
+		cols, rows := mazeGenerator{}.params(complexity)
+		st := &mazeState{cols: cols, rows: rows, walls: carveMaze(cols, rows)}
+		seen := map[image.Point]bool{{}: true}
+		queue := []image.Point{{}}
+		for len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+			for _, d := range []image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+				n := c.Add(d)
+				if n.X >= 0 && n.Y >= 0 && n.X < cols && n.Y < rows && !seen[n] && st.open(c, n) {
+					seen[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+		if len(seen) != cols*rows {
+			t.Fatalf("complexity %d: %d of %d cells reachable", complexity, len(seen), cols*rows)
+		}
+	}
+}
+
+func encodeSteps(buf []byte, steps []image.Point) string {
+	for _, d := range steps {
+		buf = append(buf, byte(int8(d.X)), byte(int8(d.Y)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
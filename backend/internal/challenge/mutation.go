@@ -0,0 +1,71 @@
+package challenge
+
+import (
+	"math/rand"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// Операции частичного обновления задания, которые уходят клиенту через SendClientData.
+// Каждая операция записана как [op][длина uint32][данные].
+const (
+	OpField    byte = 1 // новая картинка поля, данные — PNG
+	OpMarkTile byte = 2 // плитка отмечена верно, данные — её номер (1 байт)
+	OpPrompt   byte = 3 // подсказка, какую клавишу нажать, данные — PNG
+)
+
+// Промежуточные события фронтенда, на которые может реагировать drag-drop
+const (
+	EventReady     = "ready" // HTML задания загружен на клиенте
+	EventDragStart = "drag_start"
+	EventDragDrop  = "drag_drop"
+)
+
+// mutateDragDrop решает, нужно ли поменять задание в ответ на промежуточное событие,
+// и возвращает закодированные операции для клиента. Чем выше уровень задания, тем
+// чаще и сильнее меняется задание: цель и ложные цели переезжают, а клиент получает
+// перерисованное поле. Ответ в хранилище обновляется вместе с ним.
+func mutateDragDrop(store *ChallengeStore, challengeID, event string) ([]byte, bool) {
+	if event != EventDragStart {
+		return nil, false
+	}
+
+	var (
+		mutated dragDropState
+		level   int
+	)
+	ok := store.update(challengeID, func(d *challengeData) {
+		// Не больше level изменений на одно задание
+		l := dragDropLevel(d.complexity)
+		if d.mutations >= l || rand.Intn(100) >= l*30 {
+			return
+		}
+		d.mutations++
+		level = l
+
+		st := d.state.(*dragDropState)
+		st.x, st.y = rand.Intn(fieldWidth), rand.Intn(fieldHeight)
+		st.decoys = placeDecoys(st, level)
+		mutated = *st
+		mutated.decoys = append([]decoy(nil), st.decoys...)
+	})
+	if !ok || level == 0 {
+		return nil, false
+	}
+
+	// Рисуем вне блокировки хранилища
+	field, err := renderField(&mutated, level)
+	if err != nil {
+		return nil, false
+	}
+	return appendBlob(nil, OpField, field), true
+}
+
+// otherShape выбирает фигуру, отличную от формы цели
+func otherShape(s render.Shape) render.Shape {
+	for {
+		if other := render.Shapes[rand.Intn(len(render.Shapes))]; other != s {
+			return other
+		}
+	}
+}
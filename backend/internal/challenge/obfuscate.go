@@ -0,0 +1,140 @@
+package challenge
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"regexp"
+	"strings"
+)
+
+// В ассетах заданий всё, что бот мог бы найти по имени, записано плейсхолдерами
+// вида __name__: id и классы элементов, идентификаторы JS и имена полей событий.
+// Для каждого задания плейсхолдеры заменяются случайными именами, а вложенность
+// элементов меняется обёртками, так что разметку нельзя выучить один раз.
+var placeholder = regexp.MustCompile(`__([a-zA-Z][a-zA-Z0-9]*)__`)
+
+// eventFields — поля Event, имена которых в сообщениях клиента случайны.
+// challenge_id остаётся постоянным: по нему находится само задание и его словарь.
+var eventFields = []string{"event", "x", "y", "success", "data"}
+
+const (
+	nameLetters = "abcdefghijklmnopqrstuvwxyz"
+	nameDigits  = "0123456789"
+	maxNesting  = 3
+)
+
+// obfuscation — случайные имена одного задания
+type obfuscation struct {
+	names map[string]string // плейсхолдер -> случайное имя
+	used  map[string]bool
+}
+
+func newObfuscation() *obfuscation {
+	return &obfuscation{
+		names: make(map[string]string),
+		used:  make(map[string]bool),
+	}
+}
+
+// name возвращает случайное имя для плейсхолдера, одинаковое в пределах задания.
+// Имя начинается с буквы и содержит цифру, поэтому не совпадает с ключевыми словами JS.
+func (o *obfuscation) name(key string) string {
+	if n, ok := o.names[key]; ok {
+		return n
+	}
+	for {
+		b := make([]byte, 5+rand.Intn(4))
+		b[0] = nameLetters[rand.Intn(len(nameLetters))]
+		for i := 1; i < len(b); i++ {
+			b[i] = (nameLetters + nameDigits)[rand.Intn(len(nameLetters)+len(nameDigits))]
+		}
+		b[1+rand.Intn(len(b)-1)] = nameDigits[rand.Intn(len(nameDigits))]
+		if n := string(b); !o.used[n] {
+			o.used[n] = true
+			o.names[key] = n
+			return n
+		}
+	}
+}
+
+// fields возвращает словарь для декодирования событий: случайное имя -> поле Event
+func (o *obfuscation) fields() map[string]string {
+	fields := make(map[string]string, len(eventFields))
+	for _, f := range eventFields {
+		fields[o.name(f)] = f
+	}
+	return fields
+}
+
+// fieldName находит имя, под которым поле Event приходит в событиях задания
+func fieldName(fields map[string]string, field string) string {
+	for name, f := range fields {
+		if f == field {
+			return name
+		}
+	}
+	return field
+}
+
+// nest возвращает пару функций для шаблона: {{call .Open "key"}} и {{call .Close "key"}}
+// оборачивают элемент в случайное число div со случайными классами
+func (o *obfuscation) nest() (func(string) template.HTML, func(string) template.HTML) {
+	depth := make(map[string]int)
+	open := func(key string) template.HTML {
+		depth[key] = rand.Intn(maxNesting + 1)
+		var html strings.Builder
+		for i := 0; i < depth[key]; i++ {
+			fmt.Fprintf(&html, `<div class="%s">`, o.name(fmt.Sprintf("nest%s%d", key, i)))
+		}
+		return template.HTML(html.String())
+	}
+	closing := func(key string) template.HTML {
+		return template.HTML(strings.Repeat("</div>", depth[key]))
+	}
+	return open, closing
+}
+
+// apply заменяет плейсхолдеры в готовом HTML задания
+func (o *obfuscation) apply(html string) string {
+	return placeholder.ReplaceAllStringFunc(html, func(m string) string {
+		return o.name(m[2 : len(m)-2])
+	})
+}
+
+// DecodeEvent разбирает сообщение клиента, переводя случайные имена полей задания
+// обратно в поля Event. Поля с чужими именами отбрасываются: бот, написанный под
+// исходную разметку, присылает пустое событие.
+func DecodeEvent(store *ChallengeStore, data []byte) (Event, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Event{}, err
+	}
+
+	var event Event
+	if id, ok := raw["challenge_id"]; ok {
+		if err := json.Unmarshal(id, &event.ChallengeID); err != nil {
+			return Event{}, err
+		}
+	}
+	fields := store.fields(event.ChallengeID)
+	if fields == nil {
+		return event, nil
+	}
+
+	decoded := make(map[string]json.RawMessage, len(raw))
+	for key, value := range raw {
+		if field, ok := fields[key]; ok {
+			decoded[field] = value
+		}
+	}
+	decoded["challenge_id"] = raw["challenge_id"]
+
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		return Event{}, err
+	}
+	err = json.Unmarshal(canonical, &event)
+	return event, err
+}
@@ -0,0 +1,207 @@
+package challenge
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"math"
+	"math/bits"
+	"math/rand"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// EventTap — нажатие на сетку, координаты в X и Y относительно картинки
+const EventTap = "tap"
+
+const (
+	gridSize        = 240
+	maxWrongTaps    = 2 // после стольких ошибок задание считается проваленным
+	wrongTapPenalty = 25
+)
+
+var (
+	oddOneBundle = mustBundle("odd-one-out")
+	gridTextures = render.NewTextureCache(gridSize, gridSize, 16)
+)
+
+// Свойства, которыми отличающиеся плитки отличаются от остальных
+const (
+	oddShape = iota
+	oddOrientation
+	oddColor
+
+	oddProperties
+)
+
+// oddState — ответ на задание: маска отличающихся плиток и уже отмеченные из них
+type oddState struct {
+	n      int // сетка n x n
+	mask   uint32
+	tapped uint32
+	wrong  int
+}
+
+// tileStyle — как нарисована плитка
+type tileStyle struct {
+	shape render.Shape
+	angle float64 // градусы
+	hue   int
+}
+
+type oddOneGenerator struct{}
+
+// params подбирает по complexity 0-100 размер сетки (3-5) и число отличающихся плиток (1-3)
+func (oddOneGenerator) params(complexity int) (int, int) {
+	complexity = clampComplexity(complexity)
+	return 3 + complexity/40, 1 + complexity/50
+}
+
+func (g oddOneGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	n, odd := g.params(p.Complexity)
+	grid, mask, err := renderGrid(n, odd, p.Complexity)
+	if err != nil {
+		return "", fmt.Errorf("failed to render grid: %v", err)
+	}
+	store.Set(p.ChallengeID, TypeOddOneOut, p.Complexity, &oddState{n: n, mask: mask}, defaultTTL)
+
+	return oddOneBundle.render(p, map[string]interface{}{
+		"Grid":  template.URL(render.DataURI(grid)),
+		"Size":  n,
+		"Count": odd,
+	})
+}
+
+// renderGrid рисует сетку n x n, в которой odd плиток отличаются одним свойством,
+// и возвращает картинку и маску отличающихся плиток. Остальные плитки слегка
+// различаются размером, положением и поворотом, чтобы их нельзя было сравнить попиксельно.
+func renderGrid(n, odd, complexity int) ([]byte, uint32, error) {
+	complexity = clampComplexity(complexity)
+	base := tileStyle{shape: render.Shapes[rand.Intn(len(render.Shapes))], hue: rand.Intn(render.Hues)}
+	other := base
+	switch rand.Intn(oddProperties) {
+	case oddShape:
+		other.shape = otherShape(base.shape)
+	case oddOrientation:
+		// Поворот заметен только у несимметричных фигур
+		base.shape = []render.Shape{render.Triangle, render.Star}[rand.Intn(2)]
+		other.shape = base.shape
+		other.angle = 180
+	case oddColor:
+		// Чем выше complexity, тем ближе оттенок отличающихся плиток к остальным
+		shift := 1 + (100-complexity)*3/100
+		other.hue = base.hue + shift*(1-2*rand.Intn(2))
+	}
+
+	var mask uint32
+	for _, i := range rand.Perm(n * n)[:odd] {
+		mask |= 1 << i
+	}
+
+	tile := gridSize / n
+	jitter := 5 + complexity/10
+	c := render.NewCanvas(gridSize, gridSize)
+	c.Texture(gridTextures.Pick())
+	for i := 0; i < n*n; i++ {
+		style := base
+		if mask&(1<<i) != 0 {
+			style = other
+		}
+		size := tile * (55 + rand.Intn(15)) / 100
+		t := render.NewCanvas(tile, tile)
+		t.Shape(style.shape, (tile-size)/2, (tile-size)/2, size, render.Color(style.hue, 1+rand.Intn(2)), 0)
+		angle := style.angle + float64(rand.Intn(2*jitter+1)-jitter)
+		t = t.Rotated(angle * math.Pi / 180)
+
+		shift := tile / 12
+		at := image.Pt(i%n*tile+rand.Intn(2*shift+1)-shift, i/n*tile+rand.Intn(2*shift+1)-shift)
+		c.Draw(t, at)
+	}
+	line := render.Color(base.hue, 4)
+	for k := 1; k < n; k++ {
+		c.Line(k*tile, 0, k*tile, gridSize-1, line)
+		c.Line(0, k*tile, gridSize-1, k*tile, line)
+	}
+	c.Noise(complexity / 3)
+
+	data, err := c.PNG()
+	return data, mask, err
+}
+
+// HandleEvent обрабатывает нажатия: верное отмечает плитку, неверное перерисовывает
+// сетку с новым ответом. Задание завершается, когда отмечены все отличающиеся плитки
+// или ошибок стало больше maxWrongTaps.
+func (g oddOneGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	if event.Name != EventTap {
+		return Reaction{}, nil
+	}
+
+	var (
+		hit, done, reshuffle bool
+		index, wrong, n, odd int
+		complexity           int
+	)
+	if !store.update(event.ChallengeID, func(d *challengeData) {
+		st := d.state.(*oddState)
+		complexity, n = d.complexity, st.n
+		odd = bits.OnesCount32(st.mask)
+
+		tile := gridSize / st.n
+		col, row := event.X/tile, event.Y/tile
+		if event.X < 0 || event.Y < 0 || col >= st.n || row >= st.n {
+			return
+		}
+		index = row*st.n + col
+		switch bit := uint32(1) << index; {
+		case st.tapped&bit != 0:
+			// повторное нажатие на уже отмеченную плитку ничего не меняет
+		case st.mask&bit != 0:
+			hit = true
+			st.tapped |= bit
+			done = st.tapped == st.mask
+		default:
+			st.wrong++
+			done = st.wrong > maxWrongTaps
+			reshuffle = !done
+		}
+		wrong = st.wrong
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+
+	switch {
+	case done && hit:
+		return Reaction{
+			ClientData: appendBlob(nil, OpMarkTile, []byte{byte(index)}),
+			Done:       true,
+			Confidence: max(0, 100-wrong*wrongTapPenalty),
+		}, nil
+	case done:
+		return Reaction{Done: true}, nil
+	case hit:
+		return Reaction{ClientData: appendBlob(nil, OpMarkTile, []byte{byte(index)})}, nil
+	case !reshuffle:
+		return Reaction{}, nil
+	}
+
+	// Неверное нажатие: новая сетка с новым ответом, отметки сбрасываются.
+	// Рисуем вне блокировки хранилища.
+	grid, mask, err := renderGrid(n, odd, complexity)
+	if err != nil {
+		return Reaction{}, err
+	}
+	if !store.update(event.ChallengeID, func(d *challengeData) {
+		st := d.state.(*oddState)
+		st.mask, st.tapped = mask, 0
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+	return Reaction{ClientData: appendBlob(nil, OpField, grid)}, nil
+}
+
+// SolveWindow: каждую плитку нужно сравнить с соседями, на это уходит не меньше полсекунды
+func (g oddOneGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	n, odd := g.params(complexity)
+	return time.Duration(odd)*500*time.Millisecond + time.Duration(n)*100*time.Millisecond, time.Minute
+}
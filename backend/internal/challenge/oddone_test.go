@@ -0,0 +1,88 @@
+package challenge
+
+import (
+	"math/bits"
+	"testing"
+)
+
+// Нажатия на сетку: верные отмечают плитки, неверное перерисовывает сетку
+// с новым ответом и стоит штрафа, после maxWrongTaps ошибок задание провалено
+func TestOddOneHandleEvent(t *testing.T) {
+	// Нажатия задаются относительно текущего ответа, который меняется после ошибки
+	const (
+		odd     = "odd"     // ещё не отмеченная отличающаяся плитка
+		wrong   = "wrong"   // обычная плитка
+		repeat  = "repeat"  // уже отмеченная плитка
+		outside = "outside" // мимо сетки
+	)
+	tests := []struct {
+		name       string
+		taps       []string
+		op         byte // операция в ответе клиенту на последнее нажатие, 0 — ответа нет
+		done       bool
+		confidence int
+	}{
+		{name: "all odd tiles", taps: []string{odd, odd}, op: OpMarkTile, done: true, confidence: 100},
+		{name: "first odd tile", taps: []string{odd}, op: OpMarkTile},
+		{name: "repeated tap ignored", taps: []string{odd, repeat}},
+		{name: "tap outside ignored", taps: []string{outside}},
+		{name: "wrong tap reshuffles", taps: []string{odd, wrong}, op: OpField},
+		{name: "solved after reshuffle", taps: []string{odd, wrong, odd, odd}, op: OpMarkTile, done: true, confidence: 100 - wrongTapPenalty},
+		{name: "solved after two reshuffles", taps: []string{wrong, wrong, odd, odd}, op: OpMarkTile, done: true, confidence: 100 - 2*wrongTapPenalty},
+		{name: "too many wrong taps", taps: []string{wrong, wrong, wrong}, done: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			// Сетка 3x3, плитка 80x80, отличаются плитки 0 и 4
+			store.Set("c1", TypeOddOneOut, 0, &oddState{n: 3, mask: 1<<0 | 1<<4}, defaultTTL)
+
+			var r Reaction
+			for _, tap := range tt.taps {
+				var st oddState
+				store.view("c1", func(d *challengeData) { st = *d.state.(*oddState) })
+				x, y := -10, 40
+				for i := 0; i < st.n*st.n; i++ {
+					bit := uint32(1) << i
+					pick := false
+					switch tap {
+					case odd:
+						pick = st.mask&bit != 0 && st.tapped&bit == 0
+					case wrong:
+						pick = st.mask&bit == 0
+					case repeat:
+						pick = st.tapped&bit != 0
+					}
+					if pick {
+						x, y = i%st.n*80+40, i/st.n*80+40
+						break
+					}
+				}
+
+				var err error
+				r, err = oddOneGenerator{}.HandleEvent(store, Event{Name: EventTap, ChallengeID: "c1", X: x, Y: y})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			var op byte
+			if len(r.ClientData) > 0 {
+				op = r.ClientData[0]
+			}
+			if op != tt.op || r.Done != tt.done || r.Confidence != tt.confidence {
+				t.Fatalf("got op=%d done=%v confidence=%d, want op=%d done=%v confidence=%d",
+					op, r.Done, r.Confidence, tt.op, tt.done, tt.confidence)
+			}
+
+			var st oddState
+			store.view("c1", func(d *challengeData) { st = *d.state.(*oddState) })
+			if got := bits.OnesCount32(st.mask); got != 2 {
+				t.Fatalf("answer has %d odd tiles after taps, want 2", got)
+			}
+			if tt.op == OpField && st.tapped != 0 {
+				t.Fatalf("marks survived reshuffle: tapped=%b", st.tapped)
+			}
+		})
+	}
+}
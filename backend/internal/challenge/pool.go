@@ -0,0 +1,228 @@
+package challenge
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/metrics"
+	"github.com/theborzet/captcha_service/pkg/utils"
+)
+
+// Pool держит заранее сгенерированные задания, чтобы тяжёлые генераторы
+// (картинки, лабиринты) не задерживали ответ на запрос. Очередь заводится на
+// каждую пару тип + ступень complexity при первом запросе и пополняется
+// воркерами, пока такие задания спрашивают; задание старше maxAge выбрасывается.
+// Все очереди вместе держат не больше size заданий.
+// Ответы заданий из пула уже лежат в хранилище, при выдаче им обновляется
+// время выдачи и срок жизни.
+type Pool struct {
+	store   *ChallengeStore
+	workers int
+	size    int
+	maxAge  time.Duration
+	log     *slog.Logger
+
+	mu     sync.Mutex
+	queues map[poolKey]*poolQueue
+	total  int // заданий во всех очередях вместе с генерируемыми
+	wake   chan struct{}
+
+	hits, misses, expired, failed atomic.Int64
+}
+
+// poolStep — шаг ступеней complexity в пуле. Complexity риска растёт плавно,
+// и без ступеней запросы расходились бы по сотне очередей на тип, почти
+// не попадая в готовые задания.
+const poolStep = 10
+
+// poolComplexity — ступень, из которой пул выдаёт задание запрошенной complexity.
+// Округление вверх: задание из пула не легче запрошенного.
+func poolComplexity(complexity int) int {
+	return (clampComplexity(complexity) + poolStep - 1) / poolStep * poolStep
+}
+
+type poolKey struct {
+	kind       string
+	complexity int // ступень, см. poolComplexity
+}
+
+type poolQueue struct {
+	tasks      []pooledTask // от старых к новым
+	pending    int          // сколько заданий сейчас генерируется
+	lastDemand time.Time
+}
+
+type pooledTask struct {
+	task *Task
+	at   time.Time
+}
+
+// NewPool описывает пул на size заданий всех типов и ступеней вместе.
+// Задание должно успеть решиться после выдачи, поэтому maxAge не больше
+// половины срока жизни ответа в хранилище.
+func NewPool(store *ChallengeStore, workers, size int, maxAge time.Duration, log *slog.Logger) *Pool {
+	return &Pool{
+		store:   store,
+		workers: max(1, workers),
+		size:    max(1, size),
+		maxAge:  min(maxAge, defaultTTL/2),
+		log:     log,
+		queues:  make(map[poolKey]*poolQueue),
+		wake:    make(chan struct{}, max(1, workers)),
+	}
+}
+
+// Run запускает воркеры и ждёт отмены ctx
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Take выдаёт готовое задание из пула; его complexity — ступень, до которой
+// округлена запрошенная. Пустой или выключенный (nil) пул возвращает false,
+// и задание нужно сгенерировать на месте.
+func (p *Pool) Take(kind string, complexity int) (*Task, bool) {
+	if p == nil {
+		return nil, false
+	}
+	key := poolKey{kind, poolComplexity(complexity)}
+	now := time.Now()
+
+	p.mu.Lock()
+	q := p.queues[key]
+	if q == nil {
+		q = &poolQueue{}
+		p.queues[key] = q
+	}
+	q.lastDemand = now
+	p.dropStale(q, now)
+	var task *Task
+	if len(q.tasks) > 0 {
+		task = q.tasks[0].task
+		q.tasks = q.tasks[1:]
+		p.total--
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	if task == nil || !p.store.reissue(task.ID, defaultTTL) {
+		p.misses.Add(1)
+		return nil, false
+	}
+	p.hits.Add(1)
+	return task, true
+}
+
+// dropStale выбрасывает из очереди задания старше maxAge; вызывается под p.mu
+func (p *Pool) dropStale(q *poolQueue, now time.Time) {
+	n := 0
+	for n < len(q.tasks) && now.Sub(q.tasks[n].at) > p.maxAge {
+		p.store.Delete(q.tasks[n].task.ID)
+		n++
+	}
+	if n > 0 {
+		q.tasks = q.tasks[n:]
+		p.total -= n
+		p.expired.Add(int64(n))
+	}
+}
+
+// next выбирает самую пустую из очередей, которые спрашивали в пределах maxAge,
+// пока в пуле есть место. Очереди, которые больше не спрашивают, не пополняются
+// и освобождают место по мере выдачи и устаревания заданий.
+func (p *Pool) next() (poolKey, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var (
+		best  poolKey
+		found bool
+		least int
+	)
+	for key, q := range p.queues {
+		p.dropStale(q, now)
+		if now.Sub(q.lastDemand) > p.maxAge {
+			continue
+		}
+		if n := len(q.tasks) + q.pending; !found || n < least {
+			best, least, found = key, n, true
+		}
+	}
+	if !found || p.total >= p.size {
+		return poolKey{}, false
+	}
+	p.queues[best].pending++
+	p.total++
+	return best, true
+}
+
+func (p *Pool) work(ctx context.Context) {
+	tick := time.NewTicker(p.maxAge / 4)
+	defer tick.Stop()
+	for ctx.Err() == nil {
+		key, ok := p.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-p.wake:
+			case <-tick.C:
+			}
+			continue
+		}
+
+		task, err := newTask(p.store, utils.GenerateChallengeID(), key.kind, key.complexity)
+		p.mu.Lock()
+		q := p.queues[key]
+		q.pending--
+		if err == nil {
+			q.tasks = append(q.tasks, pooledTask{task: task, at: time.Now()})
+		} else {
+			p.total--
+		}
+		p.mu.Unlock()
+
+		if err != nil {
+			p.failed.Add(1)
+			p.log.Error("Failed to pre-generate challenge",
+				slog.String("type", key.kind),
+				slog.Int("complexity", key.complexity),
+				slog.Any("error", err))
+			// Тип, который не генерируется, не должен занимать воркер целиком
+			select {
+			case <-ctx.Done():
+			case <-tick.C:
+			}
+		}
+	}
+}
+
+// Collect отдаёт глубину очередей и счётчики выдачи
+func (p *Pool) Collect(w *metrics.Writer) {
+	p.mu.Lock()
+	for key, q := range p.queues {
+		w.Gauge("captcha_pool_depth", "Pre-generated challenges ready to be issued.", float64(len(q.tasks)),
+			"type", key.kind, "complexity", strconv.Itoa(key.complexity))
+	}
+	p.mu.Unlock()
+	w.Gauge("captcha_pool_capacity", "Total pool size across challenge types and complexity steps.", float64(p.size))
+	w.Counter("captcha_pool_hits_total", "Challenges issued from the pool.", float64(p.hits.Load()))
+	w.Counter("captcha_pool_misses_total", "Challenges generated inline because the pool was empty.", float64(p.misses.Load()))
+	w.Counter("captcha_pool_expired_total", "Pooled challenges dropped for exceeding the maximum age.", float64(p.expired.Load()))
+	w.Counter("captcha_pool_failures_total", "Failed background generations.", float64(p.failed.Load()))
+}
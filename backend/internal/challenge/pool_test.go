@@ -0,0 +1,117 @@
+package challenge
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestPoolComplexity(t *testing.T) {
+	tests := []struct {
+		complexity, want int
+	}{
+		{-5, 0}, {0, 0}, {1, 10}, {10, 10}, {11, 20}, {33, 40}, {95, 100}, {150, 100},
+	}
+	for _, tt := range tests {
+		if got := poolComplexity(tt.complexity); got != tt.want {
+			t.Errorf("poolComplexity(%d) = %d, want %d", tt.complexity, got, tt.want)
+		}
+	}
+}
+
+func newTestPool(t *testing.T, workers, size int) *Pool {
+	t.Helper()
+	p := NewPool(NewStoreWithClock(time.Now), workers, size, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return p
+}
+
+// waitPool ждёт, пока пул не придёт в нужное состояние
+func waitPool(t *testing.T, p *Pool, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		p.mu.Lock()
+		ok := cond()
+		p.mu.Unlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pool did not reach the expected state")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Первый запрос заводит очередь и генерируется на месте, следующий запрос
+// той же ступени получает готовое задание, а очередь пополняется снова
+func TestPoolTakeAndRefill(t *testing.T) {
+	p := newTestPool(t, 1, 2)
+	key := poolKey{TypeDragDrop, 40}
+
+	if _, ok := p.Take(TypeDragDrop, 33); ok {
+		t.Fatal("empty pool returned a task")
+	}
+	waitPool(t, p, func() bool { return len(p.queues[key].tasks) == 2 })
+
+	task, ok := p.Take(TypeDragDrop, 37)
+	if !ok {
+		t.Fatal("no pooled task for the same complexity step")
+	}
+	if task.Type != TypeDragDrop || task.Complexity != 40 {
+		t.Fatalf("got %s at complexity %d, want %s at 40", task.Type, task.Complexity, TypeDragDrop)
+	}
+	if kind, ok := p.store.Kind(task.ID); !ok || kind != TypeDragDrop {
+		t.Fatalf("pooled task answer missing from store: %q, %v", kind, ok)
+	}
+	if _, ok := p.Take(TypeDragDrop, 55); ok {
+		t.Fatal("task from another complexity step was issued")
+	}
+	if p.hits.Load() != 1 || p.misses.Load() != 2 {
+		t.Fatalf("got %d hits and %d misses, want 1 and 2", p.hits.Load(), p.misses.Load())
+	}
+
+	waitPool(t, p, func() bool { return len(p.queues[key].tasks) == 1 })
+}
+
+// Все очереди вместе не держат больше size заданий
+func TestPoolTotalCap(t *testing.T) {
+	const size = 3
+	p := newTestPool(t, 2, size)
+	for _, complexity := range []int{0, 20, 40, 60, 80, 100} {
+		p.Take(TypeDragDrop, complexity)
+		p.Take(TypeOddOneOut, complexity)
+	}
+
+	waitPool(t, p, func() bool { return p.total == size })
+	time.Sleep(50 * time.Millisecond)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queued := 0
+	for _, q := range p.queues {
+		queued += len(q.tasks) + q.pending
+	}
+	if queued != size || p.total != size {
+		t.Fatalf("pool holds %d tasks (total %d), want %d", queued, p.total, size)
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	var p *Pool
+	if _, ok := p.Take(TypeDragDrop, 50); ok {
+		t.Fatal("nil pool returned a task")
+	}
+}
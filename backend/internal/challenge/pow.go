@@ -0,0 +1,127 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// EventPoW — клиент нашёл nonce, в Data лежит его десятичная запись
+const EventPoW = "pow"
+
+// Воркер из assets/pow решает головоломку: ищет nonce, при котором хеш от seed:nonce
+// начинается с нужного числа нулевых бит. В memory-hard варианте хеш дополнительно
+// прогоняется через таблицу из mem блоков (по схеме ROMix из scrypt), так что перебор требует памяти.
+var powBundle = mustBundle("pow")
+
+// powState — параметры выданной головоломки, по ним ответ проверяется одним пересчётом хеша
+type powState struct {
+	seed   string
+	bits   int // сколько ведущих нулевых бит должно быть у хеша
+	memory int // число 32-байтных блоков для memory-hard варианта, 0 — обычный вариант
+}
+
+type powGenerator struct {
+	memoryHard bool
+}
+
+// params подбирает сложность головоломки по complexity 0-100. Обычный вариант
+// растёт по числу нулевых бит, memory-hard — в основном по объёму памяти,
+// потому что одна попытка там и так стоит 2*memory хешей.
+func (g powGenerator) params(complexity int) (int, int) {
+	complexity = clampComplexity(complexity)
+	if !g.memoryHard {
+		return 12 + complexity/10, 0
+	}
+	return 2 + complexity/20, 1 << (10 + complexity/25)
+}
+
+func (g powGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	// Предсказуемый seed позволил бы решить головоломку заранее
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	st := &powState{seed: hex.EncodeToString(seed)}
+	st.bits, st.memory = g.params(p.Complexity)
+
+	kind := TypePoW
+	if g.memoryHard {
+		kind = TypePoWMemoryHard
+	}
+	store.Set(p.ChallengeID, kind, p.Complexity, st, defaultTTL)
+
+	return powBundle.render(p, map[string]interface{}{
+		"Seed":   st.seed,
+		"Bits":   st.bits,
+		"Memory": st.memory,
+	})
+}
+
+func (g powGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	if event.Name != EventPoW {
+		return Reaction{}, nil
+	}
+
+	var st powState
+	if !store.view(event.ChallengeID, func(d *challengeData) {
+		st = *d.state.(*powState)
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+
+	nonce, err := strconv.ParseUint(event.Data, 10, 32)
+	if err != nil {
+		return Reaction{Done: true}, nil
+	}
+
+	confidence := 0
+	if hash := powHash(st.seed, nonce, st.memory); leadingZeros(hash[:]) >= st.bits {
+		confidence = 100
+	}
+	return Reaction{Done: true, Confidence: confidence}, nil
+}
+
+// SolveWindow: быстрый ответ для PoW нормален — его считает воркер, а не человек,
+// поэтому ограничено только сверху, с запасом на медленные устройства
+func (g powGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	return 0, 30*time.Second + time.Duration(clampComplexity(complexity))*time.Second
+}
+
+// powHash повторяет вычисление воркера из шаблона
+func powHash(seed string, nonce uint64, memory int) [32]byte {
+	x := sha256.Sum256([]byte(seed + ":" + strconv.FormatUint(nonce, 10)))
+	if memory == 0 {
+		return x
+	}
+
+	v := make([]byte, memory*32)
+	for i := 0; i < memory; i++ {
+		copy(v[i*32:], x[:])
+		x = sha256.Sum256(x[:])
+	}
+	var t [32]byte
+	for i := 0; i < memory; i++ {
+		j := int(binary.BigEndian.Uint32(x[:4]) % uint32(memory))
+		for k := range t {
+			t[k] = x[k] ^ v[j*32+k]
+		}
+		x = sha256.Sum256(t[:])
+	}
+	return x
+}
+
+func leadingZeros(hash []byte) int {
+	n := 0
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,270 @@
+package challenge
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventProbe — ответ клиента на проверки окружения, выданные через RunClientJS
+const EventProbe = "probe"
+
+// probesPerChallenge — сколько проверок из набора выдаётся на одно задание
+const probesPerChallenge = 4
+
+// ProbeWait — сколько итог задания ждёт ответа на проверки окружения, если ответ
+// на само задание пришёл раньше: самая долгая проверка сдаётся через 2 секунды
+const ProbeWait = 3 * time.Second
+
+// probeSpec — одна клиентская проверка: JS-выражение (значение или Promise)
+// и функция, решающая, похож ли полученный ответ на обычный браузер
+type probeSpec struct {
+	name string
+	js   string
+	pass func(v json.RawMessage) bool
+}
+
+var headlessRenderer = regexp.MustCompile(`(?i)swiftshader|llvmpipe|softpipe|offscreen`)
+
+var probeSpecs = []probeSpec{
+	{
+		name: "webdriver",
+		js:   `navigator.webdriver === true`,
+		pass: func(v json.RawMessage) bool { return string(v) == "false" },
+	},
+	{
+		name: "headless_ua",
+		js:   `/Headless|PhantomJS|Electron/i.test(navigator.userAgent)`,
+		pass: func(v json.RawMessage) bool { return string(v) == "false" },
+	},
+	{
+		name: "languages",
+		js:   `(navigator.languages || []).length`,
+		pass: func(v json.RawMessage) bool {
+			var n int
+			return json.Unmarshal(v, &n) == nil && n > 0
+		},
+	},
+	{
+		name: "window_size",
+		js:   `window.outerWidth > 0 && window.outerHeight > 0`,
+		pass: func(v json.RawMessage) bool { return string(v) == "true" },
+	},
+	{
+		// Интервалы между кадрами: у headless-браузеров они нулевые или вовсе не приходят
+		name: "raf_timing",
+		js: `new Promise(function (r) {
+			var t = [];
+			setTimeout(function () { r(null); }, 2000);
+			(function f(ts) { t.push(ts); if (t.length < 6) requestAnimationFrame(f); else r(t.slice(1).map(function (v, i) { return v - t[i]; })); })(performance.now());
+		})`,
+		pass: func(v json.RawMessage) bool {
+			var deltas []float64
+			if json.Unmarshal(v, &deltas) != nil || len(deltas) == 0 {
+				return false
+			}
+			var sum float64
+			for _, d := range deltas {
+				sum += d
+			}
+			mean := sum / float64(len(deltas))
+			return mean >= 4 && mean <= 1000
+		},
+	},
+	{
+		// Два одинаковых рендера должны совпасть: рандомизация canvas выдаёт подмену
+		name: "canvas",
+		js: `(function () {
+			function h() {
+				var c = document.createElement('canvas'); c.width = 64; c.height = 16;
+				var x = c.getContext('2d'); if (!x) return 0;
+				x.fillStyle = '#%06x'; x.font = '12px Arial'; x.fillText('%s', 2, 12);
+				var d = x.getImageData(0, 0, 64, 16).data, s = 2166136261;
+				for (var i = 0; i < d.length; i++) s = Math.imul(s ^ d[i], 16777619);
+				return s >>> 0;
+			}
+			var a = h(); return a !== 0 && a === h() ? a : 0;
+		})()`,
+		pass: func(v json.RawMessage) bool {
+			var n uint32
+			return json.Unmarshal(v, &n) == nil && n != 0
+		},
+	},
+	{
+		name: "webgl_renderer",
+		js: `(function () {
+			try {
+				var g = document.createElement('canvas').getContext('webgl');
+				var e = g && g.getExtension('WEBGL_debug_renderer_info');
+				return e ? String(g.getParameter(e.UNMASKED_RENDERER_WEBGL)) : '';
+			} catch (err) { return ''; }
+		})()`,
+		pass: func(v json.RawMessage) bool {
+			var renderer string
+			return json.Unmarshal(v, &renderer) == nil && !headlessRenderer.MatchString(renderer)
+		},
+	},
+}
+
+// probeExpectation — то, что нужно помнить о выданных проверках до получения ответа
+type probeExpectation struct {
+	keys     map[string]int // случайный ключ ответа -> индекс в probeSpecs
+	tokenKey string
+	secret   []byte // секрет выдачи, из которого выводится контрольное значение; клиенту не уходит
+}
+
+// token — контрольное значение выдачи: HMAC от задания и ключей ответа на секрете
+// выдачи. Клиент получает только само значение и возвращает его с ответами, так
+// что ответ годится лишь для этой выдачи, а вывести значение из того, что есть
+// у клиента, нельзя.
+func (e *probeExpectation) token(challengeID string) string {
+	keys := make([]string, 0, len(e.keys)+1)
+	for k := range e.keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	mac := hmac.New(sha256.New, e.secret)
+	mac.Write([]byte(challengeID + "|" + e.tokenKey + "|" + strings.Join(keys, ",")))
+	return hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// IssueProbes выбирает случайный набор проверок для задания и собирает JS для RunClientJS.
+// Ключи ответа и контрольное значение меняются от задания к заданию, поэтому
+// заранее заготовленный ответ не подойдёт. Повторно проверки не выдаются.
+// JS исполняется внутри iframe задания: страница сайта только пересылает его туда.
+func IssueProbes(store *ChallengeStore, challengeID string) (string, bool) {
+	keys := randomKeys(probesPerChallenge + 1)
+	exp := &probeExpectation{
+		keys:     make(map[string]int, probesPerChallenge),
+		tokenKey: keys[0],
+		secret:   make([]byte, 32),
+	}
+	if _, err := crand.Read(exp.secret); err != nil {
+		panic(err)
+	}
+
+	exprs := []string{""}
+	for n, i := range rand.Perm(len(probeSpecs))[:probesPerChallenge] {
+		exp.keys[keys[n+1]] = i
+		js := probeSpecs[i].js
+		if probeSpecs[i].name == "canvas" {
+			js = fmt.Sprintf(js, rand.Intn(1<<24), randomKeys(1)[0])
+		}
+		exprs = append(exprs, js)
+	}
+	exprs[0] = "'" + exp.token(challengeID) + "'"
+	// Порядок выражений тоже случайный
+	rand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+		exprs[i], exprs[j] = exprs[j], exprs[i]
+	})
+
+	issued := false
+	var fields map[string]string
+	store.update(challengeID, func(d *challengeData) {
+		if d.probes == nil {
+			d.probes = exp
+			issued = true
+			fields = d.fields
+		}
+	})
+	if !issued {
+		return "", false
+	}
+
+	var js strings.Builder
+	js.WriteString("(function () {\n\tvar k = ['")
+	js.WriteString(strings.Join(keys, "', '"))
+	js.WriteString("'];\n\tPromise.all([\n")
+	for _, e := range exprs {
+		fmt.Fprintf(&js, "\t\tPromise.resolve().then(function () { return %s; }).catch(function () { return null; }),\n", e)
+	}
+	fmt.Fprintf(&js, `	]).then(function (v) {
+		var o = {};
+		k.forEach(function (key, i) { o[key] = v[i]; });
+		window.parent.postMessage({type: 'captcha:sendData', data: JSON.stringify({%s: '%s', challenge_id: '%s', %s: JSON.stringify(o)})}, '*');
+	});
+})();`, fieldName(fields, "event"), EventProbe, challengeID, fieldName(fields, "data"))
+
+	return js.String(), true
+}
+
+// CheckProbes разбирает ответ клиента на проверки и сохраняет итоговую оценку 0-100.
+// Учитывается только первый ответ; при неверном контрольном значении оценка нулевая.
+func CheckProbes(store *ChallengeStore, challengeID, data string) (int, map[string]bool, bool) {
+	// Неразборчивый ответ просто не совпадёт ни с контрольным значением, ни с проверками
+	var answers map[string]json.RawMessage
+	_ = json.Unmarshal([]byte(data), &answers)
+
+	score := 0
+	results := make(map[string]bool, probesPerChallenge)
+	ok := false
+	store.update(challengeID, func(d *challengeData) {
+		if d.probes == nil || d.probeAnswered {
+			return
+		}
+		ok = true
+		d.probeAnswered = true
+
+		var token string
+		if json.Unmarshal(answers[d.probes.tokenKey], &token) != nil ||
+			!hmac.Equal([]byte(token), []byte(d.probes.token(challengeID))) {
+			d.probeScore = 0
+			return
+		}
+
+		passed := 0
+		for key, i := range d.probes.keys {
+			res := probeSpecs[i].pass(answers[key])
+			results[probeSpecs[i].name] = res
+			if res {
+				passed++
+			}
+		}
+		d.probeScore = passed * 100 / len(d.probes.keys)
+		d.probeResults = results
+		score = d.probeScore
+	})
+	return score, results, ok
+}
+
+// ProbesPending — выданы ли заданию проверки окружения, ответа на которые ещё нет
+func ProbesPending(store *ChallengeStore, challengeID string) bool {
+	pending := false
+	store.view(challengeID, func(d *challengeData) {
+		pending = d.probes != nil && !d.probeAnswered
+	})
+	return pending
+}
+
+// WeighByProbes учитывает результат проверок окружения в уверенности:
+// полностью пройденные проверки не меняют её, а без ответа она уменьшается вдвое
+func WeighByProbes(confidence, probeScore int) int {
+	return confidence * (50 + probeScore/2) / 100
+}
+
+// randomKeys генерирует n разных коротких ключей
+func randomKeys(n int) []string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	keys := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for len(keys) < n {
+		b := make([]byte, 4)
+		for i := range b {
+			b[i] = letters[rand.Intn(len(letters))]
+		}
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			keys = append(keys, string(b))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,223 @@
+package challenge
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// События rhythm: начало (после него сервер сам шлёт подсказки), нажатие клавиши
+// и конец, если клиент не дождался нажатий на все подсказки. В нажатии Data —
+// 4 байта в base64: номер подсказки, нажатая цифра и время реакции по часам
+// клиента в мс (uint16 big-endian).
+const (
+	EventRhythmStart = "rhythm_start"
+	EventKey         = "key"
+	EventRhythmEnd   = "rhythm_end"
+
+	keyRecordSize = 4
+)
+
+const (
+	promptSize = 96
+	// minKeyReaction — быстрее человек не успевает увидеть цифру и нажать клавишу
+	minKeyReaction = 120 * time.Millisecond
+	// clockSlack — насколько реакция по часам клиента может превышать реакцию,
+	// измеренную сервером: сервер видит её вместе с сетью, поэтому она не может быть меньше
+	clockSlack    = 40 * time.Millisecond
+	extraKeyCost  = 10                     // штраф за каждое лишнее нажатие
+	rhythmLatency = 500 * time.Millisecond // запас на сеть в обе стороны сверх предельной реакции
+)
+
+var (
+	rhythmBundle   = mustBundle("rhythm")
+	promptTextures = render.NewTextureCache(promptSize, promptSize, 16)
+)
+
+// keyReply — нажатие клавиши в ответ на подсказку
+type keyReply struct {
+	digit  int
+	client time.Duration // реакция по часам клиента
+	at     time.Time     // когда нажатие пришло на сервер
+}
+
+// rhythmState — какие цифры и когда показаны и что на них нажато
+type rhythmState struct {
+	digits  []int
+	start   time.Time
+	offsets []time.Duration // когда после start отправлена каждая подсказка
+	replies map[int]keyReply
+	extra   int
+}
+
+type rhythmGenerator struct{}
+
+// params подбирает по complexity 0-100 число подсказок (4-8), самый короткий
+// и самый длинный интервал между ними и предельное время реакции
+func (rhythmGenerator) params(complexity int) (int, time.Duration, time.Duration, time.Duration) {
+	complexity = clampComplexity(complexity)
+	c := time.Duration(complexity)
+	return 4 + complexity/25,
+		900*time.Millisecond - c*3*time.Millisecond,
+		1800*time.Millisecond - c*4*time.Millisecond,
+		1500*time.Millisecond - c*5*time.Millisecond
+}
+
+func (g rhythmGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	count, _, _, _ := g.params(p.Complexity)
+	store.Set(p.ChallengeID, TypeRhythm, p.Complexity, &rhythmState{}, defaultTTL)
+	return rhythmBundle.render(p, map[string]interface{}{
+		"Count": count,
+	})
+}
+
+// renderPrompt рисует подсказку — цифру на шумном фоне, чтобы её нельзя было прочитать из данных напрямую
+func renderPrompt(digit, complexity int) ([]byte, error) {
+	c := render.NewCanvas(promptSize, promptSize)
+	c.Texture(promptTextures.Pick())
+	maxAngle := 10 + clampComplexity(complexity)/4
+	angle := float64(rand.Intn(2*maxAngle+1)-maxAngle) * math.Pi / 180
+	c.Digit(digit, promptSize/2, promptSize/2, promptSize*3/5, angle, render.Color(rand.Intn(render.Hues), 0))
+	c.Noise(clampComplexity(complexity) / 3)
+	return c.PNG()
+}
+
+// start назначает подсказки: цифры 1-9 через случайные интервалы. Картинки
+// рисуются сразу, а отправляет их сервис по расписанию.
+func (g rhythmGenerator) start(store *ChallengeStore, challengeID string) ([]Push, error) {
+	var complexity int
+	if !store.view(challengeID, func(d *challengeData) { complexity = d.complexity }) {
+		return nil, ErrNotFound
+	}
+	count, minGap, maxGap, _ := g.params(complexity)
+
+	digits := make([]int, count)
+	offsets := make([]time.Duration, count)
+	images := make([][]byte, count)
+	at := time.Duration(0)
+	for i := range digits {
+		digits[i] = 1 + rand.Intn(9)
+		at += minGap + time.Duration(rand.Int63n(int64(maxGap-minGap)))
+		offsets[i] = at
+		img, err := renderPrompt(digits[i], complexity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render prompt: %v", err)
+		}
+		images[i] = img
+	}
+
+	now := store.Now()
+	started := false
+	if !store.update(challengeID, func(d *challengeData) {
+		st := d.state.(*rhythmState)
+		if !st.start.IsZero() {
+			return
+		}
+		st.digits, st.offsets, st.start = digits, offsets, now
+		st.replies = make(map[int]keyReply, count)
+		started = true
+	}) {
+		return nil, ErrNotFound
+	}
+	if !started {
+		return nil, nil
+	}
+
+	pushes := make([]Push, count)
+	for i := range pushes {
+		pushes[i] = Push{At: now.Add(offsets[i]), Data: appendBlob(nil, OpPrompt, images[i])}
+	}
+	return pushes, nil
+}
+
+// HandleEvent запускает подсказки и собирает нажатия. Когда нажатия пришли на все
+// подсказки или клиент закончил, проверяет их: верная ли цифра, не раньше ли
+// человеческой реакции после отправки подсказки, не врут ли часы клиента
+// и не слишком ли ровные реакции.
+func (g rhythmGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	switch event.Name {
+	case EventRhythmStart:
+		pushes, err := g.start(store, event.ChallengeID)
+		return Reaction{Pushes: pushes}, err
+	case EventKey, EventRhythmEnd:
+	default:
+		return Reaction{}, nil
+	}
+
+	now := store.Now()
+	var (
+		st         rhythmState
+		complexity int
+		complete   bool
+	)
+	if !store.update(event.ChallengeID, func(d *challengeData) {
+		s := d.state.(*rhythmState)
+		complexity = d.complexity
+		if event.Name == EventKey && !s.start.IsZero() {
+			raw, err := base64.StdEncoding.DecodeString(event.Data)
+			seq := -1
+			if err == nil && len(raw) == keyRecordSize {
+				seq = int(raw[0])
+			}
+			if _, dup := s.replies[seq]; seq < 0 || seq >= len(s.digits) || dup {
+				s.extra++
+			} else {
+				s.replies[seq] = keyReply{
+					digit:  int(raw[1]),
+					client: time.Duration(binary.BigEndian.Uint16(raw[2:])) * time.Millisecond,
+					at:     now,
+				}
+			}
+		}
+		complete = !s.start.IsZero() && len(s.replies) == len(s.digits)
+		st = *s
+		st.replies = maps.Clone(s.replies)
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+	if event.Name == EventKey && !complete {
+		return Reaction{}, nil
+	}
+	if st.start.IsZero() {
+		return Reaction{Done: true}, nil
+	}
+
+	_, _, _, maxReaction := g.params(complexity)
+	hits := 0
+	var reactions []float64
+	for i, digit := range st.digits {
+		r, ok := st.replies[i]
+		if !ok || r.digit != digit {
+			continue
+		}
+		server := r.at.Sub(st.start.Add(st.offsets[i]))
+		switch {
+		case r.client > server+clockSlack:
+			// Клиент видел подсказку дольше, чем она существует: время подделано
+			return Reaction{Done: true}, nil
+		case server < minKeyReaction || r.client > maxReaction || server > maxReaction+rhythmLatency:
+			continue
+		}
+		hits++
+		reactions = append(reactions, float64(r.client))
+	}
+
+	confidence := 100 * float64(hits) / float64(len(st.digits))
+	if len(reactions) > 2 && variation(reactions) < 0.08 {
+		confidence /= 2
+	}
+	confidence = max(0, confidence-float64(st.extra*extraKeyCost))
+	return Reaction{Done: true, Confidence: int(confidence)}, nil
+}
+
+// SolveWindow: подсказки идут по расписанию, быстрее него задание не пройти
+func (g rhythmGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	count, minGap, _, _ := g.params(complexity)
+	return time.Duration(count) * minGap, time.Minute
+}
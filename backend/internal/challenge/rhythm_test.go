@@ -0,0 +1,127 @@
+package challenge
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// keyPress — нажатие в ответ на подсказку: реакция по часам клиента и когда
+// после отправки подсказки нажатие пришло на сервер
+type keyPress struct {
+	seq, digit     int
+	client, server time.Duration
+}
+
+func (k keyPress) data() string {
+	raw := []byte{byte(k.seq), byte(k.digit), 0, 0}
+	binary.BigEndian.PutUint16(raw[2:], uint16(k.client/time.Millisecond))
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// Нажатия засчитываются, если цифра верная, реакция человеческая и часы клиента
+// не расходятся с сервером; ровные реакции и лишние нажатия снижают уверенность
+func TestRhythmHandleEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		presses    func(p []keyPress) []keyPress
+		end        bool // после нажатий клиент завершает задание сам
+		confidence int
+	}{
+		{name: "human reactions", confidence: 100},
+		{name: "wrong digit", presses: func(p []keyPress) []keyPress {
+			p[1].digit = p[1].digit%9 + 1
+			return p
+		}, confidence: 75},
+		{name: "faster than human", presses: func(p []keyPress) []keyPress {
+			p[2].client, p[2].server = 60*time.Millisecond, 90*time.Millisecond
+			return p
+		}, confidence: 75},
+		{name: "too slow", presses: func(p []keyPress) []keyPress {
+			p[3].client, p[3].server = 1600*time.Millisecond, 1630*time.Millisecond
+			return p
+		}, confidence: 75},
+		{name: "client clock ahead of server", presses: func(p []keyPress) []keyPress {
+			p[0].client = p[0].server + 100*time.Millisecond
+			return p
+		}},
+		{name: "even reactions", presses: func(p []keyPress) []keyPress {
+			for i := range p {
+				p[i].client, p[i].server = 400*time.Millisecond, 430*time.Millisecond
+			}
+			return p
+		}, confidence: 50},
+		{name: "extra key", presses: func(p []keyPress) []keyPress {
+			dup := p[0]
+			dup.server += 50 * time.Millisecond
+			return append([]keyPress{p[0], dup}, p[1:]...)
+		}, confidence: 100 - extraKeyCost},
+		{name: "ended early", presses: func(p []keyPress) []keyPress { return p[:2] }, end: true, confidence: 50},
+		{name: "no presses", presses: func(p []keyPress) []keyPress { return nil }, end: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			store.Set("c1", TypeRhythm, 0, &rhythmState{}, defaultTTL)
+
+			r, err := rhythmGenerator{}.HandleEvent(store, Event{Name: EventRhythmStart, ChallengeID: "c1"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			count, _, _, _ := rhythmGenerator{}.params(0)
+			if len(r.Pushes) != count {
+				t.Fatalf("got %d prompts, want %d", len(r.Pushes), count)
+			}
+			var st rhythmState
+			store.view("c1", func(d *challengeData) { st = *d.state.(*rhythmState) })
+
+			presses := make([]keyPress, count)
+			for i := range presses {
+				client := []time.Duration{310, 480, 620, 390}[i%4] * time.Millisecond
+				presses[i] = keyPress{seq: i, digit: st.digits[i], client: client, server: client + 30*time.Millisecond}
+			}
+			if tt.presses != nil {
+				presses = tt.presses(presses)
+			}
+
+			r = Reaction{}
+			for _, k := range presses {
+				clock.t = st.start.Add(st.offsets[k.seq] + k.server)
+				if r, err = (rhythmGenerator{}).HandleEvent(store, Event{Name: EventKey, ChallengeID: "c1", Data: k.data()}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if tt.end {
+				if r.Done {
+					t.Fatal("done before the client ended the challenge")
+				}
+				clock.advance(time.Second)
+				if r, err = (rhythmGenerator{}).HandleEvent(store, Event{Name: EventRhythmEnd, ChallengeID: "c1"}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if !r.Done || r.Confidence != tt.confidence {
+				t.Fatalf("got done=%v confidence=%d, want done confidence=%d", r.Done, r.Confidence, tt.confidence)
+			}
+		})
+	}
+}
+
+// Задание, которое не начали, проваливается по rhythm_end, а повторный старт
+// не назначает подсказки заново
+func TestRhythmStart(t *testing.T) {
+	store, _ := newTestStore()
+	store.Set("c1", TypeRhythm, 0, &rhythmState{}, defaultTTL)
+	r, err := rhythmGenerator{}.HandleEvent(store, Event{Name: EventRhythmEnd, ChallengeID: "c1"})
+	if err != nil || !r.Done || r.Confidence != 0 {
+		t.Fatalf("end before start: got %+v, %v", r, err)
+	}
+
+	if r, err = (rhythmGenerator{}).HandleEvent(store, Event{Name: EventRhythmStart, ChallengeID: "c1"}); err != nil || len(r.Pushes) == 0 {
+		t.Fatalf("start: got %+v, %v", r, err)
+	}
+	if r, err = (rhythmGenerator{}).HandleEvent(store, Event{Name: EventRhythmStart, ChallengeID: "c1"}); err != nil || len(r.Pushes) != 0 {
+		t.Fatalf("second start: got %d prompts, %v", len(r.Pushes), err)
+	}
+}
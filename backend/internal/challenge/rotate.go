@@ -0,0 +1,188 @@
+package challenge
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// События rotate: начало работы с диском, отсчёт траектории поворота и итог.
+// В каждом X — угол диска в градусах, Y всегда 0. Отсчёты приходят по ходу
+// поворота, время каждого сервер берёт по своим часам; итог — последний отсчёт.
+const (
+	EventRotateStart = "rotate_start"
+	EventTurn        = "turn"
+	EventRotate      = "rotate"
+
+	minRotateSamples = 4
+	maxRotateSamples = 120
+	// minTurnGap — отсчёты, пришедшие чаще, отправлены пачкой, а не по ходу поворота:
+	// клиент шлёт их не чаще раза в 30 мс
+	minTurnGap = 5 * time.Millisecond
+)
+
+const discSize = 140
+
+var (
+	rotateBundle = mustBundle("rotate")
+	discTextures = render.NewTextureCache(discSize, discSize, 16)
+)
+
+// rotateState — ответ на задание (на сколько градусов по часовой повёрнут предмет)
+// и пришедшие отсчёты поворота
+type rotateState struct {
+	offset  int
+	samples []rotateSample
+}
+
+// uprightObjects рисуют предметы, у которых однозначно понятно, где верх.
+// Координаты заданы для холста discSize x discSize.
+var uprightObjects = []func(c *render.Canvas, body, detail uint8){
+	// дом
+	func(c *render.Canvas, body, detail uint8) {
+		c.Rect(image.Rect(85, 38, 95, 60), body)
+		c.Polygon([]image.Point{{30, 72}, {70, 32}, {110, 72}}, body)
+		c.Rect(image.Rect(40, 70, 100, 112), body)
+		c.Rect(image.Rect(62, 86, 78, 112), detail)
+	},
+	// стрелка
+	func(c *render.Canvas, body, detail uint8) {
+		c.Rect(image.Rect(62, 60, 78, 114), body)
+		c.Polygon([]image.Point{{38, 64}, {70, 24}, {102, 64}}, body)
+		c.Rect(image.Rect(66, 70, 74, 108), detail)
+	},
+	// ёлка
+	func(c *render.Canvas, body, detail uint8) {
+		c.Rect(image.Rect(63, 90, 77, 116), detail)
+		c.Polygon([]image.Point{{36, 94}, {70, 44}, {104, 94}}, body)
+		c.Polygon([]image.Point{{44, 70}, {70, 24}, {96, 70}}, body)
+	},
+	// кошка
+	func(c *render.Canvas, body, detail uint8) {
+		c.Polygon([]image.Point{{90, 104}, {112, 78}, {117, 82}, {96, 110}}, body)
+		c.Ellipse(image.Rect(45, 70, 95, 116), body)
+		c.Ellipse(image.Rect(52, 38, 88, 74), body)
+		c.Polygon([]image.Point{{53, 50}, {57, 24}, {69, 42}}, body)
+		c.Polygon([]image.Point{{87, 50}, {83, 24}, {71, 42}}, body)
+		c.Ellipse(image.Rect(60, 50, 66, 56), detail)
+		c.Ellipse(image.Rect(74, 50, 80, 56), detail)
+	},
+}
+
+type rotateGenerator struct{}
+
+// tolerance — допустимое отклонение итогового угла в градусах, с ростом complexity уже
+func (rotateGenerator) tolerance(complexity int) int {
+	return 20 - clampComplexity(complexity)*14/100
+}
+
+func (rotateGenerator) Generate(store *ChallengeStore, p Params) (string, error) {
+	// Почти ровное положение не выдаём: его можно принять, ничего не делая
+	st := &rotateState{offset: 30 + rand.Intn(301)}
+
+	hue := rand.Intn(render.Hues)
+	c := render.NewCanvas(discSize, discSize)
+	c.Texture(discTextures.Pick())
+	uprightObjects[rand.Intn(len(uprightObjects))](c, render.Color(hue, 1), render.Color(hue, 4))
+	c.Noise(clampComplexity(p.Complexity) / 2)
+	c = c.Rotated(float64(st.offset) * math.Pi / 180)
+	c.ClipCircle()
+
+	store.Set(p.ChallengeID, TypeRotate, p.Complexity, st, defaultTTL)
+
+	disc, err := c.DataURI()
+	if err != nil {
+		return "", fmt.Errorf("failed to render disc: %v", err)
+	}
+	return rotateBundle.render(p, map[string]interface{}{
+		"Disc": template.URL(disc),
+	})
+}
+
+// rotateSample — отсчёт траектории поворота и когда он пришёл на сервер
+type rotateSample struct {
+	angle int
+	at    time.Time
+}
+
+// HandleEvent копит отсчёты поворота, а по итогу сверяет угол с поворотом предмета
+// и смотрит на траекторию: диск не может встать на место без промежуточных
+// положений, а человек крутит его с переменной скоростью
+func (g rotateGenerator) HandleEvent(store *ChallengeStore, event Event) (Reaction, error) {
+	switch event.Name {
+	case EventRotateStart, EventTurn, EventRotate:
+	default:
+		return Reaction{}, nil
+	}
+
+	now := store.Now()
+	var (
+		st         rotateState
+		complexity int
+	)
+	if !store.update(event.ChallengeID, func(d *challengeData) {
+		s := d.state.(*rotateState)
+		complexity = d.complexity
+		// Итоговый угол записывается всегда, промежуточные — пока не набралось максимума
+		if event.Name == EventRotate || len(s.samples) < maxRotateSamples {
+			s.samples = append(s.samples, rotateSample{angle: mod(event.X, 360), at: now})
+		}
+		st = *s
+		st.samples = append([]rotateSample(nil), s.samples...)
+	}) {
+		return Reaction{}, ErrNotFound
+	}
+	if event.Name != EventRotate {
+		return Reaction{}, nil
+	}
+
+	samples := st.samples
+	if len(samples) < minRotateSamples {
+		return Reaction{Done: true}, nil
+	}
+
+	tolerance := g.tolerance(complexity)
+	diff := angleDistance(st.offset+samples[len(samples)-1].angle, 0)
+	if diff > tolerance {
+		return Reaction{Done: true}, nil
+	}
+	confidence := 100 - 50*float64(diff)/float64(tolerance)
+
+	speeds := make([]float64, 0, len(samples))
+	bunched := 0
+	for i := 1; i < len(samples); i++ {
+		dt := samples[i].at.Sub(samples[i-1].at)
+		if dt < minTurnGap {
+			bunched++
+			continue
+		}
+		step := angleDistance(samples[i].angle, samples[i-1].angle)
+		speeds = append(speeds, float64(step)/dt.Seconds())
+	}
+	confidence *= 1 - float64(bunched)/float64(len(samples)-1)
+	if len(speeds) > 1 && variation(speeds) < 0.05 {
+		confidence /= 2
+	}
+
+	return Reaction{Done: true, Confidence: int(confidence)}, nil
+}
+
+// SolveWindow: понять, где у предмета верх, и довернуть диск занимает хотя бы секунду
+func (rotateGenerator) SolveWindow(complexity int) (time.Duration, time.Duration) {
+	return time.Second, 45 * time.Second
+}
+
+// angleDistance — наименьший угол между двумя направлениями в градусах
+func angleDistance(a, b int) int {
+	d := mod(a-b, 360)
+	return min(d, 360-d)
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
@@ -0,0 +1,96 @@
+package challenge
+
+import (
+	"testing"
+	"time"
+)
+
+// Поворот засчитывается по итоговому углу в пределах допуска, а траектория
+// должна прийти по ходу поворота и не с ровной скоростью
+func TestRotateHandleEvent(t *testing.T) {
+	type sample struct {
+		name  string
+		angle int
+		gap   time.Duration // пауза перед отсчётом
+	}
+	// Предмет повёрнут на 90°, верно довернуть диск до 270°
+	human := func(final int) []sample {
+		return []sample{
+			{EventRotateStart, 0, 0},
+			{EventTurn, 60, 40 * time.Millisecond},
+			{EventTurn, 150, 55 * time.Millisecond},
+			{EventTurn, 230, 70 * time.Millisecond},
+			{EventRotate, final, 45 * time.Millisecond},
+		}
+	}
+	tests := []struct {
+		name       string
+		complexity int
+		samples    []sample
+		done       bool
+		confidence int
+	}{
+		{name: "exact angle", samples: human(270), done: true, confidence: 100},
+		{name: "negative angle wraps", samples: human(-90), done: true, confidence: 100},
+		{name: "within tolerance", samples: human(280), done: true, confidence: 75},
+		{name: "beyond tolerance", samples: human(300), done: true},
+		{name: "tolerance narrows with complexity", complexity: 100, samples: human(280), done: true},
+		{
+			name:    "too few samples",
+			samples: []sample{{EventRotateStart, 0, 0}, {EventRotate, 270, time.Second}},
+			done:    true,
+		},
+		{
+			name: "bunched samples",
+			samples: []sample{
+				{EventRotateStart, 0, 0},
+				{EventTurn, 60, 40 * time.Millisecond},
+				{EventTurn, 150, time.Millisecond},
+				{EventTurn, 230, 70 * time.Millisecond},
+				{EventRotate, 270, time.Millisecond},
+			},
+			done: true, confidence: 50,
+		},
+		{
+			name: "all samples bunched",
+			samples: []sample{
+				{EventRotateStart, 0, 0},
+				{EventTurn, 90, time.Millisecond},
+				{EventTurn, 180, time.Millisecond},
+				{EventRotate, 270, time.Millisecond},
+			},
+			done: true,
+		},
+		{
+			name: "constant speed",
+			samples: []sample{
+				{EventRotateStart, 0, 0},
+				{EventTurn, 60, 40 * time.Millisecond},
+				{EventTurn, 120, 40 * time.Millisecond},
+				{EventTurn, 180, 40 * time.Millisecond},
+				{EventRotate, 270, 60 * time.Millisecond},
+			},
+			done: true, confidence: 50,
+		},
+		{name: "turn without result", samples: human(270)[:4]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			store.Set("c1", TypeRotate, tt.complexity, &rotateState{offset: 90}, defaultTTL)
+
+			var r Reaction
+			for _, s := range tt.samples {
+				clock.advance(s.gap)
+				var err error
+				r, err = rotateGenerator{}.HandleEvent(store, Event{Name: s.name, ChallengeID: "c1", X: s.angle})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if r.Done != tt.done || r.Confidence != tt.confidence {
+				t.Fatalf("got done=%v confidence=%d, want done=%v confidence=%d", r.Done, r.Confidence, tt.done, tt.confidence)
+			}
+		})
+	}
+}
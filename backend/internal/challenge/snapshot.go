@@ -0,0 +1,390 @@
+package challenge
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// Снимок задания нужен, чтобы повторить записанную сессию: ответ и все случайные
+// решения генератора берутся из записи, а события проверяются текущим кодом.
+
+// Part — какая часть задания снимается или восстанавливается
+type Part uint8
+
+const (
+	PartAll    Part = iota + 1 // всё задание, как его выдали
+	PartProbes                 // выданные проверки окружения
+	PartState                  // ответ генератора после случайного изменения по ходу решения
+)
+
+func (p Part) String() string {
+	switch p {
+	case PartAll:
+		return "all"
+	case PartProbes:
+		return "probes"
+	case PartState:
+		return "state"
+	}
+	return fmt.Sprintf("part(%d)", uint8(p))
+}
+
+type snapshot struct {
+	Kind       string
+	Complexity int
+	State      any
+	Mutations  int
+	ClientKey  string
+	Site       Site
+	TTL        time.Duration
+	Fields     map[string]string
+	Stages     []string // этапы цепочки, nil — задание не из цепочки
+	Results    []int
+
+	IssuedAt         time.Time
+	FirstInteraction time.Time
+
+	ProbeKeys     map[string]int // nil — проверки не выдавались
+	ProbeTokenKey string
+	ProbeSecret   []byte
+	ProbeAnswered bool
+	ProbeScore    int
+	ProbeResults  map[string]bool
+}
+
+func init() {
+	gob.Register(&dragDropState{})
+	gob.Register(&powState{})
+	gob.Register(&clickSeqState{})
+	gob.Register(&rotateState{})
+	gob.Register(&oddState{})
+	gob.Register(&mazeState{})
+	gob.Register(&audioState{})
+	gob.Register(&rhythmState{})
+}
+
+// Snapshot снимает часть живого задания
+func (s *ChallengeStore) Snapshot(challengeID string, part Part) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	// Кодируем под блокировкой хранилища: генераторы меняют состояние на месте
+	if !s.view(challengeID, func(d *challengeData) {
+		var snap snapshot
+		switch part {
+		case PartAll:
+			snap = snapshot{
+				Kind:             d.kind,
+				Complexity:       d.complexity,
+				State:            d.state,
+				Mutations:        d.mutations,
+				ClientKey:        d.clientKey,
+				Site:             d.site,
+				TTL:              d.ttl,
+				Fields:           d.fields,
+				IssuedAt:         d.issuedAt,
+				FirstInteraction: d.firstInteraction,
+				ProbeAnswered:    d.probeAnswered,
+				ProbeScore:       d.probeScore,
+				ProbeResults:     d.probeResults,
+			}
+			if d.chain != nil {
+				snap.Stages, snap.Results = d.chain.stages, d.chain.results
+			}
+		case PartState:
+			snap = snapshot{State: d.state, Mutations: d.mutations}
+		}
+		if part != PartState && d.probes != nil {
+			snap.ProbeKeys, snap.ProbeTokenKey, snap.ProbeSecret = d.probes.keys, d.probes.tokenKey, d.probes.secret
+		}
+		data, err = gobBytes(&snap)
+	}) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Restore восстанавливает часть задания из снимка. Задание целиком создаётся
+// заново со сроком жизни от текущего времени хранилища, остальные части
+// заменяются только у живого задания.
+func (s *ChallengeStore) Restore(challengeID string, part Part, data []byte) error {
+	var snap snapshot
+	if err := gobDecode(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if part == PartAll {
+		if _, ok := generators[snap.Kind]; !ok {
+			return fmt.Errorf("unknown challenge type %q", snap.Kind)
+		}
+		ttl := defaultTTL
+		if snap.TTL > 0 {
+			ttl = snap.TTL
+		}
+		s.Set(challengeID, snap.Kind, snap.Complexity, snap.State, ttl)
+	}
+	if !s.update(challengeID, func(d *challengeData) {
+		switch part {
+		case PartAll:
+			d.mutations, d.clientKey, d.fields = snap.Mutations, snap.ClientKey, snap.Fields
+			d.site, d.ttl = snap.Site, snap.TTL
+			d.issuedAt, d.firstInteraction = snap.IssuedAt, snap.FirstInteraction
+			d.probeAnswered, d.probeScore, d.probeResults = snap.ProbeAnswered, snap.ProbeScore, snap.ProbeResults
+			if snap.Stages != nil {
+				// Все этапы цепочки выдаются с её сложностью
+				d.chain = &chain{stages: snap.Stages, results: snap.Results, complexity: snap.Complexity}
+			}
+			fallthrough
+		case PartProbes:
+			d.probes = nil
+			if snap.ProbeKeys != nil {
+				d.probes = &probeExpectation{keys: snap.ProbeKeys, tokenKey: snap.ProbeTokenKey, secret: snap.ProbeSecret}
+			}
+		case PartState:
+			d.state, d.mutations = snap.State, snap.Mutations
+		}
+	}) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func gobBytes(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Состояния генераторов держат поля неэкспортируемыми, поэтому в снимок они
+// попадают через копии с открытыми полями.
+
+type decoySnapshot struct {
+	X, Y  int
+	Shape render.Shape
+}
+
+type dragDropSnapshot struct {
+	X, Y   int
+	Shape  render.Shape
+	Hue    int
+	Ring   int
+	Decoys []decoySnapshot
+}
+
+func (st *dragDropState) GobEncode() ([]byte, error) {
+	v := dragDropSnapshot{X: st.x, Y: st.y, Shape: st.shape, Hue: st.hue, Ring: st.ring}
+	for _, d := range st.decoys {
+		v.Decoys = append(v.Decoys, decoySnapshot{X: d.x, Y: d.y, Shape: d.shape})
+	}
+	return gobBytes(v)
+}
+
+func (st *dragDropState) GobDecode(data []byte) error {
+	var v dragDropSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = dragDropState{x: v.X, y: v.Y, shape: v.Shape, hue: v.Hue, ring: v.Ring}
+	for _, d := range v.Decoys {
+		st.decoys = append(st.decoys, decoy{x: d.X, y: d.Y, shape: d.Shape})
+	}
+	return nil
+}
+
+type powSnapshot struct {
+	Seed         string
+	Bits, Memory int
+}
+
+func (st *powState) GobEncode() ([]byte, error) {
+	return gobBytes(powSnapshot{Seed: st.seed, Bits: st.bits, Memory: st.memory})
+}
+
+func (st *powState) GobDecode(data []byte) error {
+	var v powSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = powState{seed: v.Seed, bits: v.Bits, memory: v.Memory}
+	return nil
+}
+
+type clickSnapshot struct {
+	P  image.Point
+	At time.Time
+}
+
+type clickSeqSnapshot struct {
+	Boxes  []image.Rectangle
+	Clicks []clickSnapshot
+}
+
+func (st *clickSeqState) GobEncode() ([]byte, error) {
+	v := clickSeqSnapshot{Boxes: st.boxes}
+	for _, c := range st.clicks {
+		v.Clicks = append(v.Clicks, clickSnapshot{P: c.p, At: c.at})
+	}
+	return gobBytes(v)
+}
+
+func (st *clickSeqState) GobDecode(data []byte) error {
+	var v clickSeqSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = clickSeqState{boxes: v.Boxes}
+	for _, c := range v.Clicks {
+		st.clicks = append(st.clicks, click{p: c.P, at: c.At})
+	}
+	return nil
+}
+
+type rotateSampleSnapshot struct {
+	Angle int
+	At    time.Time
+}
+
+type rotateSnapshot struct {
+	Offset  int
+	Samples []rotateSampleSnapshot
+}
+
+func (st *rotateState) GobEncode() ([]byte, error) {
+	v := rotateSnapshot{Offset: st.offset}
+	for _, s := range st.samples {
+		v.Samples = append(v.Samples, rotateSampleSnapshot{Angle: s.angle, At: s.at})
+	}
+	return gobBytes(v)
+}
+
+func (st *rotateState) GobDecode(data []byte) error {
+	var v rotateSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = rotateState{offset: v.Offset}
+	for _, s := range v.Samples {
+		st.samples = append(st.samples, rotateSample{angle: s.Angle, at: s.At})
+	}
+	return nil
+}
+
+type oddSnapshot struct {
+	N            int
+	Mask, Tapped uint32
+	Wrong        int
+}
+
+func (st *oddState) GobEncode() ([]byte, error) {
+	return gobBytes(oddSnapshot{N: st.n, Mask: st.mask, Tapped: st.tapped, Wrong: st.wrong})
+}
+
+func (st *oddState) GobDecode(data []byte) error {
+	var v oddSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = oddState{n: v.N, mask: v.Mask, tapped: v.Tapped, wrong: v.Wrong}
+	return nil
+}
+
+type mazeSnapshot struct {
+	Cols, Rows, Cell int
+	Origin           image.Point
+	Walls            []uint8
+	Started, Broken  bool
+	Pos, Cur, Prev   image.Point
+	Steps, Repeats   int
+	SumLen, SumSq    float64
+}
+
+func (st *mazeState) GobEncode() ([]byte, error) {
+	return gobBytes(mazeSnapshot{
+		Cols: st.cols, Rows: st.rows, Cell: st.cell, Origin: st.origin, Walls: st.walls,
+		Started: st.started, Broken: st.broken, Pos: st.pos, Cur: st.cur, Prev: st.prev,
+		Steps: st.steps, Repeats: st.repeats, SumLen: st.sumLen, SumSq: st.sumSq,
+	})
+}
+
+func (st *mazeState) GobDecode(data []byte) error {
+	var v mazeSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = mazeState{
+		cols: v.Cols, rows: v.Rows, cell: v.Cell, origin: v.Origin, walls: v.Walls,
+		started: v.Started, broken: v.Broken, pos: v.Pos, cur: v.Cur, prev: v.Prev,
+		steps: v.Steps, repeats: v.Repeats, sumLen: v.SumLen, sumSq: v.SumSq,
+	}
+	return nil
+}
+
+type audioSnapshot struct {
+	Digits   string
+	LastWord time.Duration
+	Played   time.Time
+}
+
+func (st *audioState) GobEncode() ([]byte, error) {
+	return gobBytes(audioSnapshot{Digits: st.digits, LastWord: st.lastWord, Played: st.played})
+}
+
+func (st *audioState) GobDecode(data []byte) error {
+	var v audioSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = audioState{digits: v.Digits, lastWord: v.LastWord, played: v.Played}
+	return nil
+}
+
+type keyReplySnapshot struct {
+	Digit  int
+	Client time.Duration
+	At     time.Time
+}
+
+type rhythmSnapshot struct {
+	Digits  []int
+	Start   time.Time
+	Offsets []time.Duration
+	Replies map[int]keyReplySnapshot
+	Extra   int
+}
+
+func (st *rhythmState) GobEncode() ([]byte, error) {
+	v := rhythmSnapshot{Digits: st.digits, Start: st.start, Offsets: st.offsets, Extra: st.extra}
+	if st.replies != nil {
+		v.Replies = make(map[int]keyReplySnapshot, len(st.replies))
+		for seq, r := range st.replies {
+			v.Replies[seq] = keyReplySnapshot{Digit: r.digit, Client: r.client, At: r.at}
+		}
+	}
+	return gobBytes(v)
+}
+
+func (st *rhythmState) GobDecode(data []byte) error {
+	var v rhythmSnapshot
+	if err := gobDecode(data, &v); err != nil {
+		return err
+	}
+	*st = rhythmState{digits: v.Digits, start: v.Start, offsets: v.Offsets, extra: v.Extra}
+	if v.Replies != nil || !v.Start.IsZero() {
+		st.replies = make(map[int]keyReply, len(v.Replies))
+		for seq, r := range v.Replies {
+			st.replies[seq] = keyReply{digit: r.Digit, client: r.Client, at: r.At}
+		}
+	}
+	return nil
+}
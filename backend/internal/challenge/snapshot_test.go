@@ -0,0 +1,96 @@
+package challenge
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/render"
+)
+
+// Снимок каждого генератора восстанавливается в то же состояние
+func TestSnapshotStateRoundTrip(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		kind  string
+		state any
+	}{
+		{TypeDragDrop, &dragDropState{x: 10, y: 20, shape: render.Star, hue: 3, ring: 5, decoys: []decoy{{x: 1, y: 2, shape: render.Circle}}}},
+		{TypePoW, &powState{seed: "abc", bits: 18, memory: 64}},
+		{TypeClickSequence, &clickSeqState{
+			boxes:  []image.Rectangle{image.Rect(1, 2, 30, 40), image.Rect(50, 60, 80, 90)},
+			clicks: []click{{p: image.Pt(12, 22), at: at}},
+		}},
+		{TypeRotate, &rotateState{offset: 135, samples: []rotateSample{{angle: 0, at: at}, {angle: 40, at: at.Add(40 * time.Millisecond)}}}},
+		{TypeOddOneOut, &oddState{n: 4, mask: 0b1001, tapped: 0b1000, wrong: 1}},
+		{TypeMaze, &mazeState{
+			cols: 2, rows: 2, cell: 60, origin: image.Pt(10, 10), walls: []uint8{5, 9, 15, 14},
+			started: true, pos: image.Pt(45, 41), cur: image.Pt(0, 0), prev: image.Pt(5, 1),
+			steps: 3, repeats: 1, sumLen: 15.5, sumSq: 80.25,
+		}},
+		{TypeAudio, &audioState{digits: "4821", lastWord: 2 * time.Second, played: at}},
+		{TypeRhythm, &rhythmState{}},
+		{TypeRhythm, &rhythmState{
+			digits: []int{3, 7}, start: at, offsets: []time.Duration{time.Second, 2 * time.Second},
+			replies: map[int]keyReply{0: {digit: 3, client: 400 * time.Millisecond, at: at.Add(1430 * time.Millisecond)}},
+			extra:   1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			store, _ := newTestStore()
+			store.Set("c1", tt.kind, 40, tt.state, defaultTTL)
+			data, err := store.Snapshot("c1", PartState)
+			if err != nil {
+				t.Fatalf("Snapshot: %v", err)
+			}
+
+			restored, _ := newTestStore()
+			restored.Set("c1", tt.kind, 40, nil, defaultTTL)
+			if err := restored.Restore("c1", PartState, data); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			var got any
+			restored.view("c1", func(d *challengeData) { got = d.state })
+			if !reflect.DeepEqual(got, tt.state) {
+				t.Fatalf("got %+v, want %+v", got, tt.state)
+			}
+		})
+	}
+}
+
+// Снимок всего задания переносит привязки, цепочку и проверки окружения
+func TestSnapshotAll(t *testing.T) {
+	store, clock := newTestStore()
+	store.Set("c1", TypeOddOneOut, 60, &oddState{n: 3, mask: 1}, 90*time.Second)
+	store.BindClient("c1", "client")
+	store.BindSite("c1", Site{Key: "site", Hostname: "example.com", Action: "login"})
+	store.update("c1", func(d *challengeData) {
+		d.chain = &chain{stages: []string{TypeOddOneOut, TypeRotate}, results: []int{80}, complexity: 60}
+		d.probes = &probeExpectation{keys: map[string]int{"k": 1}, tokenKey: "t", secret: []byte("secret")}
+	})
+	data, err := store.Snapshot("c1", PartAll)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restored := NewStoreWithClock(func() time.Time { return clock.t.Add(time.Hour) })
+	if err := restored.Restore("c1", PartAll, data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	var got, want challengeData
+	store.view("c1", func(d *challengeData) { want = *d })
+	restored.view("c1", func(d *challengeData) { got = *d })
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := store.Snapshot("missing", PartAll); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("snapshot of missing challenge: got %v, want ErrNotFound", err)
+	}
+	if err := restored.Restore("c1", PartAll, []byte("garbage")); err == nil {
+		t.Fatal("garbage snapshot restored")
+	}
+}
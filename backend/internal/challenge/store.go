@@ -1,47 +1,183 @@
 package challenge
 
 import (
+	"maps"
 	"sync"
 	"time"
+
+	"github.com/theborzet/captcha_service/internal/metrics"
 )
 
 type ChallengeStore struct {
 	mu       sync.RWMutex
 	answers  map[string]challengeData
 	expiries map[string]time.Time
+	now      func() time.Time
 }
 
 type challengeData struct {
-	answer     string
-	x, y       int
+	kind       string // тип задания, по нему выбирается генератор
 	complexity int
+	state      any // ответ в формате конкретного генератора
+	mutations  int // сколько раз задание уже менялось по ходу решения
+	clientKey  string
+	site       Site
+	ttl        time.Duration     // срок жизни, отличный от defaultTTL; 0 — по умолчанию
+	fields     map[string]string // случайные имена полей событий задания -> поля Event
+	chain      *chain            // этапы цепочки, если задание — её часть
+
+	issuedAt         time.Time
+	firstInteraction time.Time // первое действие пользователя с заданием
+	trail            []Mark    // действия пользователя по порядку, не больше maxTrail
+
+	probes        *probeExpectation // выданные клиенту проверки окружения
+	probeAnswered bool
+	probeScore    int
+	probeResults  map[string]bool // имя проверки -> похож ли ответ на обычный браузер
+}
+
+// Mark — одно действие пользователя с заданием; X и Y — из события, если оно их несёт
+type Mark struct {
+	At   time.Time
+	Name string
+	X, Y int
 }
 
+// maxTrail — сколько действий задания помнится для разбора траектории
+const maxTrail = 512
+
 func NewInMemoryStore() *ChallengeStore {
 	store := &ChallengeStore{
 		answers:  make(map[string]challengeData),
 		expiries: make(map[string]time.Time),
+		now:      time.Now,
 	}
 	go store.cleanup()
 	return store
 }
 
-func (s *ChallengeStore) Set(challengeID, answer string, x, y, complexity int, ttl time.Duration) {
+// NewStoreWithClock создаёт хранилище со своими часами: по ним задания выдаются,
+// истекают и проверяются. Так повторяется записанная сессия в её собственном времени.
+func NewStoreWithClock(now func() time.Time) *ChallengeStore {
+	return &ChallengeStore{
+		answers:  make(map[string]challengeData),
+		expiries: make(map[string]time.Time),
+		now:      now,
+	}
+}
+
+// Now — текущее время по часам хранилища
+func (s *ChallengeStore) Now() time.Time {
+	return s.now()
+}
+
+func (s *ChallengeStore) Set(challengeID, kind string, complexity int, state any, ttl time.Duration) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.answers[challengeID] = challengeData{answer: answer, x: x, y: y, complexity: complexity}
-	s.expiries[challengeID] = time.Now().Add(ttl)
+	now := s.now()
+	s.answers[challengeID] = challengeData{kind: kind, complexity: complexity, state: state, issuedAt: now}
+	s.expiries[challengeID] = now.Add(ttl)
+}
+
+// reissue выдаёт заранее сгенерированное задание: время выдачи и срок жизни
+// отсчитываются заново, как если бы оно было сгенерировано сейчас
+func (s *ChallengeStore) reissue(challengeID string, ttl time.Duration) bool {
+	return s.update(challengeID, func(d *challengeData) {
+		d.issuedAt = s.now()
+		s.expiries[challengeID] = d.issuedAt.Add(ttl)
+	})
+}
+
+// Kind возвращает тип живого задания
+func (s *ChallengeStore) Kind(challengeID string) (string, bool) {
+	var kind string
+	ok := s.view(challengeID, func(d *challengeData) {
+		kind = d.kind
+	})
+	return kind, ok
+}
+
+// BindClient привязывает задание к ключу клиента, чтобы учесть исход в его профиле риска
+func (s *ChallengeStore) BindClient(challengeID, clientKey string) {
+	s.update(challengeID, func(d *challengeData) {
+		d.clientKey = clientKey
+	})
+}
+
+// Site — сайт, ради которого проходится задание; из него берётся содержимое пропуска
+type Site struct {
+	Key      string // ключ сайта (тенанта), пусто — общие настройки сервиса
+	Hostname string
+	Action   string // действие на сайте
+}
+
+// BindSite запоминает сайт, ради которого проходится задание
+func (s *ChallengeStore) BindSite(challengeID string, site Site) {
+	s.update(challengeID, func(d *challengeData) {
+		d.site = site
+	})
+}
+
+// Site возвращает сайт, к которому привязано задание
+func (s *ChallengeStore) Site(challengeID string) Site {
+	var site Site
+	s.view(challengeID, func(d *challengeData) {
+		site = d.site
+	})
+	return site
+}
+
+// SetTTL меняет срок жизни задания: он отсчитывается от выдачи и переносится
+// на следующие этапы цепочки
+func (s *ChallengeStore) SetTTL(challengeID string, ttl time.Duration) {
+	s.update(challengeID, func(d *challengeData) {
+		d.ttl = ttl
+		s.expiries[challengeID] = d.issuedAt.Add(ttl)
+	})
 }
 
-func (s *ChallengeStore) Get(challengeID string) (string, int, int, bool) {
+// ClientKey возвращает ключ клиента, которому выдано задание
+func (s *ChallengeStore) ClientKey(challengeID string) string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	expiry, exists := s.expiries[challengeID]
-	if !exists || time.Now().After(expiry) {
-		return "", 0, 0, false
-	}
-	data, exists := s.answers[challengeID]
-	return data.answer, data.x, data.y, exists
+	return s.answers[challengeID].clientKey
+}
+
+// setFields запоминает словарь имён полей событий задания
+func (s *ChallengeStore) setFields(challengeID string, fields map[string]string) {
+	s.update(challengeID, func(d *challengeData) {
+		d.fields = fields
+	})
+}
+
+// fields возвращает словарь имён полей событий живого задания
+func (s *ChallengeStore) fields(challengeID string) map[string]string {
+	var fields map[string]string
+	s.view(challengeID, func(d *challengeData) {
+		fields = d.fields
+	})
+	return fields
+}
+
+// markInteraction запоминает действие пользователя с заданием и время первого из них
+func (s *ChallengeStore) markInteraction(challengeID string, event Event, at time.Time) {
+	s.update(challengeID, func(d *challengeData) {
+		if d.firstInteraction.IsZero() {
+			d.firstInteraction = at
+		}
+		if len(d.trail) < maxTrail {
+			d.trail = append(d.trail, Mark{At: at, Name: event.Name, X: event.X, Y: event.Y})
+		}
+	})
+}
+
+// Trail возвращает копию действий пользователя с живым заданием
+func (s *ChallengeStore) Trail(challengeID string) []Mark {
+	var trail []Mark
+	s.view(challengeID, func(d *challengeData) {
+		trail = append([]Mark(nil), d.trail...)
+	})
+	return trail
 }
 
 func (s *ChallengeStore) GetComplexity(challengeID string) int {
@@ -54,6 +190,62 @@ func (s *ChallengeStore) GetComplexity(challengeID string) int {
 	return data.complexity
 }
 
+// ProbeScore возвращает оценку проверок окружения 0-100 (0, если ответа не было)
+func (s *ChallengeStore) ProbeScore(challengeID string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.answers[challengeID].probeScore
+}
+
+// ProbeResults возвращает результаты отдельных проверок окружения (nil, если ответа не было)
+func (s *ChallengeStore) ProbeResults(challengeID string) map[string]bool {
+	var results map[string]bool
+	s.view(challengeID, func(d *challengeData) {
+		results = maps.Clone(d.probeResults)
+	})
+	return results
+}
+
+// view даёт fn прочитать данные живого задания под блокировкой на чтение
+func (s *ChallengeStore) view(challengeID string, fn func(d *challengeData)) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiry, exists := s.expiries[challengeID]
+	if !exists || s.now().After(expiry) {
+		return false
+	}
+	data := s.answers[challengeID]
+	fn(&data)
+	return true
+}
+
+// update применяет fn к данным живого задания под блокировкой
+func (s *ChallengeStore) update(challengeID string, fn func(d *challengeData)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, exists := s.expiries[challengeID]
+	if !exists || s.now().After(expiry) {
+		return false
+	}
+	data := s.answers[challengeID]
+	fn(&data)
+	s.answers[challengeID] = data
+	return true
+}
+
+// Collect отдаёт число живых заданий по типам
+func (s *ChallengeStore) Collect(w *metrics.Writer) {
+	counts := make(map[string]int)
+	s.mu.RLock()
+	for _, d := range s.answers {
+		counts[d.kind]++
+	}
+	s.mu.RUnlock()
+	for kind, n := range counts {
+		w.Gauge("captcha_active_challenges", "Challenges held in the store, including pooled ones.", float64(n), "type", kind)
+	}
+}
+
 func (s *ChallengeStore) Delete(challengeID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -67,7 +259,7 @@ func (s *ChallengeStore) cleanup() {
 	for range ticker.C {
 		s.mu.Lock()
 		for id, expiry := range s.expiries {
-			if time.Now().After(expiry) {
+			if s.now().After(expiry) {
 				delete(s.answers, id)
 				delete(s.expiries, id)
 			}
@@ -0,0 +1,48 @@
+package challenge
+
+import "time"
+
+// minReaction — быстрее этого человек не успевает увидеть задание и начать с ним работать
+const minReaction = 150 * time.Millisecond
+
+// Timing — временные отметки задания для проверки скорости решения
+type Timing struct {
+	Elapsed  time.Duration // от выдачи задания до ответа
+	Reaction time.Duration // от выдачи до первого взаимодействия, 0 если его не было
+}
+
+// Timing считает отметки живого задания относительно момента ответа
+func (s *ChallengeStore) Timing(challengeID string, answeredAt time.Time) (Timing, bool) {
+	var t Timing
+	ok := s.view(challengeID, func(d *challengeData) {
+		t.Elapsed = answeredAt.Sub(d.issuedAt)
+		if !d.firstInteraction.IsZero() {
+			t.Reaction = d.firstInteraction.Sub(d.issuedAt)
+		}
+	})
+	return t, ok
+}
+
+// TimingFactor — множитель уверенности 0-1 за скорость решения. Допустимое окно
+// задаёт генератор задания с учётом complexity: ответ быстрее минимума снижает
+// уверенность пропорционально, медленнее максимума — плавно, а мгновенная
+// первая реакция на задание урезает её вдвое.
+func TimingFactor(kind string, complexity int, t Timing) float64 {
+	gen, ok := generators[kind]
+	if !ok {
+		return 1
+	}
+	minSolve, maxSolve := gen.SolveWindow(complexity)
+
+	factor := 1.0
+	switch {
+	case minSolve > 0 && t.Elapsed < minSolve:
+		factor = float64(max(t.Elapsed, 0)) / float64(minSolve)
+	case maxSolve > 0 && t.Elapsed > maxSolve:
+		factor = float64(maxSolve) / float64(t.Elapsed)
+	}
+	if t.Reaction > 0 && t.Reaction < minReaction {
+		factor /= 2
+	}
+	return factor
+}
@@ -0,0 +1,14 @@
+package challenge
+
+// Verdict — из чего сложилась уверенность по заданию или этапу цепочки
+type Verdict struct {
+	Type       string
+	Complexity int
+	Raw        int // уверенность генератора по самому ответу
+	ProbeScore int // оценка проверок окружения 0-100
+	Timing     Timing
+	Confidence int // итог модели оценки
+
+	Model    string             // имя модели, выставившей Confidence
+	Features map[string]float64 // признаки, по которым оценивала модель
+}
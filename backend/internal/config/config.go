@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 
+	"github.com/theborzet/captcha_service/internal/challenge"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,6 +19,13 @@ type Config struct {
 	Instance InstanceConfig `yaml:"instance"`
 	Balancer BalancerConfig `yaml:"balancer"`
 	Logging  LoggingConfig  `yaml:"logging"`
+	Risk     RiskConfig     `yaml:"risk"`
+	Security SecurityConfig `yaml:"security"`
+	Pool     PoolConfig     `yaml:"pool"`
+	Record   RecordConfig   `yaml:"record"`
+	Scoring  ScoringConfig  `yaml:"scoring"`
+	Pass     PassConfig     `yaml:"pass"`
+	Tenants  []TenantConfig `yaml:"tenants"` // сайты со своими ключами и политикой
 	Host     string         // хост сервера капчи (из переменной окружения HOST, не из YAML)
 }
 
@@ -27,8 +37,9 @@ type ServerConfig struct {
 
 // InstanceConfig - настройки инстанса капчи
 type InstanceConfig struct {
-	ID            string `yaml:"id"`
-	ChallengeType string `yaml:"challenge_type"`
+	ID            string   `yaml:"id"`
+	ChallengeType string   `yaml:"challenge_type"`
+	Chain         []string `yaml:"chain"` // типы этапов после challenge_type под той же сессией, пусто - одно задание
 }
 
 // BalancerConfig - настройки подключения к балансеру
@@ -41,6 +52,83 @@ type LoggingConfig struct {
 	Level string `yaml:"level"`
 }
 
+// RiskConfig - настройки адаптивной сложности по истории клиента
+type RiskConfig struct {
+	ProfileTTL     int      `yaml:"profile_ttl"`     // сколько секунд помним клиента после последнего запроса
+	BurstWindow    int      `yaml:"burst_window"`    // окно подсчёта всплеска запросов, сек
+	BurstThreshold int      `yaml:"burst_threshold"` // сколько запросов в окне считается нормой
+	FastSolveMs    int      `yaml:"fast_solve_ms"`   // решение быстрее этого считается подозрительным
+	MaxComplexity  int      `yaml:"max_complexity"`  // потолок (0-100), до которого поднимается complexity
+	EscalateScore  int      `yaml:"escalate_score"`  // при таком риске (0-100) меняем тип задания
+	EscalateType   string   `yaml:"escalate_type"`   // на какой тип меняем, пусто - не меняем
+	EscalateChain  []string `yaml:"escalate_chain"`  // какие этапы добавляем к заданию при таком риске
+}
+
+// SecurityConfig - защита страницы капчи и WebSocket-прокси от чужих сайтов
+type SecurityConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"` // пусто - только свой origin
+	TicketSecret   string   `yaml:"ticket_secret"`   // ключ подписи билетов сессии
+	TicketTTL      int      `yaml:"ticket_ttl"`      // сколько секунд билет годен для открытия WebSocket
+}
+
+// PoolConfig - пул заранее сгенерированных заданий
+type PoolConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Workers int  `yaml:"workers"` // сколько горутин генерируют задания, 0 - по числу CPU
+	Size    int  `yaml:"size"`    // сколько заданий держать в пуле всего, по всем типам и ступеням complexity
+	MaxAge  int  `yaml:"max_age"` // сколько секунд задание может пролежать в пуле
+}
+
+// RecordConfig - запись выборки сессий для повтора в cmd/replay
+type RecordConfig struct {
+	Path       string  `yaml:"path"`        // файл записи, пусто - запись выключена
+	SampleRate float64 `yaml:"sample_rate"` // доля записываемых сессий, 0-1
+}
+
+// ScoringConfig - модель оценки уверенности по признакам этапа
+type ScoringConfig struct {
+	ModelPath      string `yaml:"model_path"`      // JSON-файл модели, пусто - встроенная формула
+	ReloadInterval int    `yaml:"reload_interval"` // как часто проверять, не сменился ли файл модели, сек
+}
+
+// PassConfig - пропуски за пройденную капчу, которые целевой сервис проверяет через /siteverify
+// или VerifyToken. Пропуск принимает любой инстанс с тем же ключом подписи, а погашенные пропуски
+// инстансы отмечают в общем Redis. Без Redis погашенные пропуски помнит только свой процесс,
+// и пропуски должен проверять один инстанс.
+type PassConfig struct {
+	Secret       string `yaml:"secret"`        // ключ подписи пропусков, пусто - случайный на процесс
+	TTL          int    `yaml:"ttl"`           // сколько секунд пропуск можно предъявить
+	VerifySecret string `yaml:"verify_secret"` // секрет, который целевой сервис передаёт в /siteverify и VerifyToken, пусто - пропуски не проверить
+	RedisAddr    string `yaml:"redis_addr"`    // Redis для погашенных пропусков, общий для инстансов, пусто - память процесса
+}
+
+// TenantConfig - сайт (тенант), который встраивает капчу под своим ключом.
+// Запросы без ключа сайта идут по общим настройкам сервиса (DefaultTenant).
+type TenantConfig struct {
+	SiteKey        string   `yaml:"site_key"`        // открытый ключ сайта, передаётся в /captcha и WebSocket
+	Name           string   `yaml:"name"`            // имя сайта для логов
+	AllowedOrigins []string `yaml:"allowed_origins"` // пусто - только свой origin
+	ChallengeTypes []string `yaml:"challenge_types"` // какие типы заданий можно выдавать, пусто - любые
+	MinComplexity  int      `yaml:"min_complexity"`  // границы запрошенной complexity, 0 - без границы; подъём по риску их не учитывает
+	MaxComplexity  int      `yaml:"max_complexity"`
+	RateLimit      int      `yaml:"rate_limit"`    // заданий в минуту с одного IP, 0 - без ограничения
+	ChallengeTTL   int      `yaml:"challenge_ttl"` // сколько секунд живёт задание, 0 - по умолчанию
+	PassSecret     string   `yaml:"pass_secret"`   // ключ подписи пропусков сайта
+	PassTTL        int      `yaml:"pass_ttl"`      // сколько секунд пропуск можно предъявить
+	VerifySecret   string   `yaml:"verify_secret"` // секрет сервера сайта для /siteverify, пусто - пропуски сайта не проверить
+}
+
+// DefaultTenant - политика запросов без ключа сайта из общих настроек
+func (c *Config) DefaultTenant() TenantConfig {
+	return TenantConfig{
+		Name:           "default",
+		AllowedOrigins: c.Security.AllowedOrigins,
+		PassSecret:     c.Pass.Secret,
+		PassTTL:        c.Pass.TTL,
+		VerifySecret:   c.Pass.VerifySecret,
+	}
+}
+
 func MustLoad() *Config {
 	configPath := fetchConfigPath()
 	if configPath == "" {
@@ -68,6 +156,7 @@ func Load(configPath string) (*Config, error) {
 
 	// Переопределяем из переменных окружения
 	overrideFromEnv(cfg)
+	setDefaults(cfg)
 
 	if err := validate(cfg); err != nil {
 		return nil, fmt.Errorf("incorrect config: %w", err)
@@ -118,6 +207,9 @@ func overrideFromEnv(cfg *Config) {
 	if v := os.Getenv("CHALLENGE_TYPE"); v != "" {
 		cfg.Instance.ChallengeType = v
 	}
+	if v := os.Getenv("CHALLENGE_CHAIN"); v != "" {
+		cfg.Instance.Chain = strings.Split(v, ",")
+	}
 
 	if v := os.Getenv("BALANCER_HOST"); v != "" {
 		cfg.Balancer.Host = v
@@ -132,11 +224,106 @@ func overrideFromEnv(cfg *Config) {
 		cfg.Logging.Level = strings.ToLower(v)
 	}
 
+	if v := os.Getenv("RISK_ESCALATE_TYPE"); v != "" {
+		cfg.Risk.EscalateType = v
+	}
+
+	if v := os.Getenv("POOL_ENABLED"); v != "" {
+		cfg.Pool.Enabled, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("POOL_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Pool.Workers = n
+		}
+	}
+
+	if v := os.Getenv("ALLOWED_ORIGINS"); v != "" {
+		cfg.Security.AllowedOrigins = strings.Split(v, ",")
+	}
+	if v := os.Getenv("TICKET_SECRET"); v != "" {
+		cfg.Security.TicketSecret = v
+	}
+
+	if v := os.Getenv("RECORD_PATH"); v != "" {
+		cfg.Record.Path = v
+	}
+	if v := os.Getenv("RECORD_SAMPLE_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.Record.SampleRate = rate
+		}
+	}
+
+	if v := os.Getenv("SCORING_MODEL"); v != "" {
+		cfg.Scoring.ModelPath = v
+	}
+
+	if v := os.Getenv("PASS_SECRET"); v != "" {
+		cfg.Pass.Secret = v
+	}
+	if v := os.Getenv("PASS_VERIFY_SECRET"); v != "" {
+		cfg.Pass.VerifySecret = v
+	}
+	if v := os.Getenv("PASS_REDIS_ADDR"); v != "" {
+		cfg.Pass.RedisAddr = v
+	}
+
 	if v := os.Getenv("HOST"); v != "" {
 		cfg.Host = v
 	}
 }
 
+// setDefaults - заполняет незаданные необязательные параметры
+func setDefaults(cfg *Config) {
+	if cfg.Risk.ProfileTTL <= 0 {
+		cfg.Risk.ProfileTTL = 900
+	}
+	if cfg.Risk.BurstWindow <= 0 {
+		cfg.Risk.BurstWindow = 60
+	}
+	if cfg.Risk.BurstThreshold <= 0 {
+		cfg.Risk.BurstThreshold = 10
+	}
+	if cfg.Risk.FastSolveMs <= 0 {
+		cfg.Risk.FastSolveMs = 800
+	}
+	if cfg.Risk.MaxComplexity <= 0 {
+		cfg.Risk.MaxComplexity = 100
+	}
+	if cfg.Risk.EscalateScore == 0 {
+		cfg.Risk.EscalateScore = 80
+	}
+	if cfg.Security.TicketTTL <= 0 {
+		cfg.Security.TicketTTL = 120
+	}
+	if cfg.Pool.Workers <= 0 {
+		cfg.Pool.Workers = runtime.NumCPU()
+	}
+	if cfg.Pool.Size <= 0 {
+		cfg.Pool.Size = 100
+	}
+	if cfg.Pool.MaxAge <= 0 {
+		cfg.Pool.MaxAge = 60
+	}
+	if cfg.Scoring.ReloadInterval <= 0 {
+		cfg.Scoring.ReloadInterval = 10
+	}
+	if cfg.Pass.TTL <= 0 {
+		cfg.Pass.TTL = 120
+	}
+	for i := range cfg.Tenants {
+		t := &cfg.Tenants[i]
+		if t.Name == "" {
+			t.Name = t.SiteKey
+		}
+		if t.PassTTL <= 0 {
+			t.PassTTL = cfg.Pass.TTL
+		}
+	}
+}
+
+// validSiteKey - ключ сайта передаётся в URL и билете сессии
+var validSiteKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // validate - валидирует конфиг после загрузки
 func validate(cfg *Config) error {
 	if cfg.Server.MinPort > cfg.Server.MaxPort {
@@ -151,6 +338,55 @@ func validate(cfg *Config) error {
 	if cfg.Balancer.Port < 1024 || cfg.Balancer.Port > 65535 {
 		return fmt.Errorf("balancer.port out of range 1024-65535")
 	}
+	if cfg.Risk.EscalateScore < 0 || cfg.Risk.EscalateScore > 100 {
+		return fmt.Errorf("risk.escalate_score out of range 0-100")
+	}
+	if cfg.Risk.MaxComplexity > 100 {
+		return fmt.Errorf("risk.max_complexity out of range 0-100")
+	}
+	// Опечатка в типе задания иначе всплыла бы только отказом на каждый запрос
+	if !challenge.Known(cfg.Instance.ChallengeType) {
+		return fmt.Errorf("instance.challenge_type: unknown challenge type %q", cfg.Instance.ChallengeType)
+	}
+	if err := knownTypes("instance.chain", cfg.Instance.Chain); err != nil {
+		return err
+	}
+	if cfg.Risk.EscalateType != "" && !challenge.Known(cfg.Risk.EscalateType) {
+		return fmt.Errorf("risk.escalate_type: unknown challenge type %q", cfg.Risk.EscalateType)
+	}
+	if err := knownTypes("risk.escalate_chain", cfg.Risk.EscalateChain); err != nil {
+		return err
+	}
+	if cfg.Record.SampleRate < 0 || cfg.Record.SampleRate > 1 {
+		return fmt.Errorf("record.sample_rate out of range 0-1")
+	}
+	// С общим Redis пропуск проверяет любой инстанс, и подписывать его нужно общим ключом:
+	// случайный ключ процесса другой инстанс не знает
+	if cfg.Pass.RedisAddr != "" && cfg.Pass.Secret == "" {
+		return fmt.Errorf("pass.secret is required with pass.redis_addr")
+	}
+	siteKeys := make(map[string]bool, len(cfg.Tenants))
+	for i, t := range cfg.Tenants {
+		if !validSiteKey.MatchString(t.SiteKey) {
+			return fmt.Errorf("tenants[%d].site_key must be non-empty and contain only letters, digits, - and _", i)
+		}
+		if siteKeys[t.SiteKey] {
+			return fmt.Errorf("tenants[%d].site_key %q is not unique", i, t.SiteKey)
+		}
+		siteKeys[t.SiteKey] = true
+		if t.MinComplexity < 0 || t.MinComplexity > 100 || t.MaxComplexity < 0 || t.MaxComplexity > 100 {
+			return fmt.Errorf("tenants[%d]: min_complexity and max_complexity out of range 0-100", i)
+		}
+		if t.MaxComplexity > 0 && t.MinComplexity > t.MaxComplexity {
+			return fmt.Errorf("tenants[%d]: min_complexity (%d) > max_complexity (%d)", i, t.MinComplexity, t.MaxComplexity)
+		}
+		if t.RateLimit < 0 || t.ChallengeTTL < 0 {
+			return fmt.Errorf("tenants[%d]: rate_limit and challenge_ttl must be >= 0", i)
+		}
+		if cfg.Pass.RedisAddr != "" && t.PassSecret == "" {
+			return fmt.Errorf("tenants[%d].pass_secret is required with pass.redis_addr", i)
+		}
+	}
 	return nil
 }
 
@@ -167,3 +403,13 @@ func fetchConfigPath() string {
 
 	return configPath
 }
+
+// knownTypes проверяет, что сервис умеет выдавать все перечисленные типы заданий
+func knownTypes(field string, kinds []string) error {
+	for i, kind := range kinds {
+		if !challenge.Known(kind) {
+			return fmt.Errorf("%s[%d]: unknown challenge type %q", field, i, kind)
+		}
+	}
+	return nil
+}
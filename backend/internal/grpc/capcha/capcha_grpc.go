@@ -2,42 +2,290 @@ package captcha
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log/slog"
-	"math"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/theborzet/captcha_service/internal/challenge"
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/risk"
+	"github.com/theborzet/captcha_service/internal/scoring"
+	"github.com/theborzet/captcha_service/internal/security"
+	"github.com/theborzet/captcha_service/internal/tenant"
 	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type GRPCCaptchaService struct {
 	pb.UnimplementedCaptchaServiceServer
-	store *challenge.ChallengeStore
-	log   *slog.Logger
+	store         *challenge.ChallengeStore
+	pool          *challenge.Pool // nil, если пул выключен
+	risk          *risk.Tracker
+	challengeType string              // тип заданий, под которым инстанс зарегистрирован в балансере
+	chain         []string            // следующие этапы под той же сессией
+	recorder      *recording.Recorder // nil, если запись сессий выключена
+	scorer        *scoring.Engine
+	tenants       *tenant.Registry
+	log           *slog.Logger
 }
 
-func NewCaptchaService(store *challenge.ChallengeStore, log *slog.Logger) *GRPCCaptchaService {
-	return &GRPCCaptchaService{store: store, log: log}
+func NewCaptchaService(store *challenge.ChallengeStore, pool *challenge.Pool, riskTracker *risk.Tracker, challengeType string, chain []string, recorder *recording.Recorder, scorer *scoring.Engine, tenants *tenant.Registry, log *slog.Logger) *GRPCCaptchaService {
+	return &GRPCCaptchaService{store: store, pool: pool, risk: riskTracker, challengeType: challengeType, chain: chain, recorder: recorder, scorer: scorer, tenants: tenants, log: log}
 }
 
 func (s *GRPCCaptchaService) NewChallenge(ctx context.Context, req *pb.ChallengeRequest) (*pb.ChallengeResponse, error) {
-	s.log.Info("Received CAPTCHA generation request", slog.Int("complexity", int(req.Complexity)))
+	s.log.Info("Received CAPTCHA generation request",
+		slog.Int("complexity", int(req.Complexity)),
+		slog.String("type", s.challengeType),
+		slog.Bool("accessible", req.Accessible),
+		slog.String("site_key", req.SiteKey),
+		slog.String("hostname", req.Hostname),
+		slog.String("action", req.Action))
 
-	challengeID, html, err := challenge.GenerateDragDropChallenge(s.store, int(req.Complexity))
+	site, err := s.tenants.Lookup(req.SiteKey)
+	if err != nil {
+		s.log.Warn("CAPTCHA request for unknown site rejected", slog.String("site_key", req.SiteKey))
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if limitKey := rateLimitKey(ctx, req.ClientKey); !site.Allow(limitKey) {
+		s.log.Warn("CAPTCHA rate limit exceeded", slog.String("site", site.Name), slog.String("client_key", limitKey))
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	// Пользователю, которому недоступны задания мышью, выдаётся аудиозадание
+	// независимо от типов, разрешённых сайтом. Границы сложности сайта применяются
+	// к запрошенной сложности, а подъём по риску ниже идёт уже поверх них.
+	complexity, kind := site.Complexity(int(req.Complexity)), site.Type(s.challengeType)
+	if req.Accessible {
+		kind = challenge.TypeAudio
+	}
+
+	// Для известного клиента сложность и тип задания зависят от его истории,
+	// а при высоком риске к заданию добавляются этапы
+	extra := s.chain
+	if req.ClientKey != "" {
+		var (
+			score    int
+			adjusted string
+		)
+		complexity, adjusted, score = s.risk.Adjust(req.ClientKey, complexity, kind)
+		if site.Allows(adjusted) {
+			kind = adjusted
+		}
+		extra = append(extra[:len(extra):len(extra)], s.risk.Chain(score)...)
+		s.log.Info("Risk profile applied",
+			slog.Int("risk_score", score),
+			slog.Int("effective_complexity", complexity),
+			slog.String("effective_type", kind),
+			slog.Any("chain", extra))
+	}
+
+	// Этапы типов, которые сайт не разрешает, заменяются разрешённым
+	stages := []string{kind}
+	for _, stage := range extra {
+		if req.Accessible {
+			stage = challenge.TypeAudio
+		} else {
+			stage = site.Type(stage)
+		}
+		stages = append(stages, stage)
+	}
+
+	task, err := challenge.NewChain(s.store, s.pool, stages, complexity)
 	if err != nil {
 		s.log.Error("Failed to generate CAPTCHA", slog.Any("error", err))
 		return nil, err
 	}
-	s.log.Info("CAPTCHA created", slog.String("challenge_id", challengeID))
+	if ttl := site.TTL(); ttl > 0 {
+		s.store.SetTTL(task.ID, ttl)
+	}
+	s.store.BindClient(task.ID, req.ClientKey)
+	s.store.BindSite(task.ID, challenge.Site{Key: site.SiteKey, Hostname: req.Hostname, Action: req.Action})
+	s.recorder.Start(task.ID, task.Type, task.Complexity, stages)
+	s.log.Info("CAPTCHA created", slog.String("challenge_id", task.ID), slog.Int("stages", task.Stages))
 
 	return &pb.ChallengeResponse{
-		ChallengeId: challengeID,
-		Html:        html,
+		ChallengeId:           task.ID,
+		Html:                  task.HTML,
+		Complexity:            int32(task.Complexity),
+		ChallengeType:         task.Type,
+		ContentSecurityPolicy: task.CSP,
+	}, nil
+}
+
+// rateLimitKey — по кому считать частоту запросов: по ключу клиента, а без него по
+// адресу вызывающего, чтобы вызовы gRPC без ключа не делили одну квоту на всех.
+// Ключ клиента /captcha строит только из IP, поэтому подменой заголовков квоту не сбросить.
+func rateLimitKey(ctx context.Context, clientKey string) string {
+	if clientKey != "" {
+		return clientKey
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return "peer:" + host
+	}
+	return "peer:" + addr
+}
+
+// Коды отказа VerifyToken — в духе siteverify у reCAPTCHA
+const (
+	verifyMissingToken    = "missing-input-response"
+	verifyInvalidToken    = "invalid-input-response"
+	verifyInvalidSecret   = "invalid-input-secret"
+	verifyInvalidSite     = "invalid-site-key"
+	verifyTimeoutOrReused = "timeout-or-duplicate"
+	verifyHostname        = "hostname-mismatch"
+	verifyAction          = "action-mismatch"
+)
+
+// VerifyToken проверяет пропуск, выданный с итогом пройденной капчи, и гасит его.
+// Сайт определяется по секрету его сервера, как в /siteverify: по одному открытому
+// ключу сайта чужой пропуск можно было бы погасить. Ключ сайта в запросе
+// необязателен, но если передан, должен совпасть с сайтом секрета.
+// Отказ — не ошибка вызова: причина возвращается в ErrorCode. Ошибка вызова —
+// только недоступное хранилище погашенных пропусков.
+func (s *GRPCCaptchaService) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
+	if req.Token == "" {
+		return &pb.VerifyTokenResponse{ErrorCode: verifyMissingToken}, nil
+	}
+	site, ok := s.tenants.BySecret(req.Secret)
+	if !ok {
+		s.log.Warn("Pass token with invalid secret rejected", slog.String("site_key", req.SiteKey))
+		return &pb.VerifyTokenResponse{ErrorCode: verifyInvalidSecret}, nil
+	}
+	if req.SiteKey != "" && req.SiteKey != site.SiteKey {
+		s.log.Warn("Pass token for another site rejected",
+			slog.String("site", site.Name),
+			slog.String("site_key", req.SiteKey))
+		return &pb.VerifyTokenResponse{ErrorCode: verifyInvalidSite}, nil
+	}
+	claims, err := site.Passes.Redeem(ctx, req.Token, site.SiteKey, req.Hostname, req.Action)
+	if errors.Is(err, security.ErrPassStore) {
+		s.log.Error("Failed to redeem pass token", slog.String("site", site.Name), slog.Any("error", err))
+		return nil, status.Error(codes.Unavailable, "pass store unavailable")
+	}
+	if err != nil {
+		code := verifyInvalidToken
+		switch {
+		case errors.Is(err, security.ErrExpiredPass), errors.Is(err, security.ErrRedeemedPass):
+			code = verifyTimeoutOrReused
+		case errors.Is(err, security.ErrPassHostname):
+			code = verifyHostname
+		case errors.Is(err, security.ErrPassAction):
+			code = verifyAction
+		}
+		s.log.Warn("Pass token rejected",
+			slog.String("challenge_id", claims.ChallengeID),
+			slog.String("site", site.Name),
+			slog.String("hostname", req.Hostname),
+			slog.String("action", req.Action),
+			slog.Any("error", err))
+		return &pb.VerifyTokenResponse{ErrorCode: code}, nil
+	}
+
+	s.log.Info("Pass token redeemed",
+		slog.String("challenge_id", claims.ChallengeID),
+		slog.String("site", site.Name),
+		slog.String("hostname", claims.Hostname),
+		slog.String("action", claims.Action),
+		slog.Int("score", claims.Score))
+	return &pb.VerifyTokenResponse{
+		Success:     true,
+		Score:       int32(claims.Score),
+		ChallengeId: claims.ChallengeID,
+		Hostname:    claims.Hostname,
+		Action:      claims.Action,
+		IssuedAt:    claims.IssuedAt,
 	}, nil
 }
 
-func (s *GRPCCaptchaService) MakeEventStream(stream pb.CaptchaService_MakeEventStreamServer) error {
+// lockedStream разрешает отправку в стрим из нескольких горутин: ответы на события
+// и отложенные обновления заданий уходят независимо друг от друга. Всё отправленное
+// попадает в запись сессии, если она ведётся.
+type lockedStream struct {
+	pb.CaptchaService_MakeEventStreamServer
+	mu       sync.Mutex
+	recorder *recording.Recorder
+
+	// Ответы на задания, пришедшие раньше ответа на проверки окружения:
+	// итог по ним подводится, когда проверки ответят, истечёт ProbeWait или закроется стрим
+	heldMu  sync.Mutex
+	held    map[string]heldAnswer
+	closed  bool
+	judging sync.WaitGroup
+}
+
+// heldAnswer — ответ на задание, ждущий ответа на проверки окружения
+type heldAnswer struct {
+	confidence int
+	answeredAt time.Time
+}
+
+func (l *lockedStream) Send(event *pb.ServerEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recorder.Server(event)
+	return l.CaptchaService_MakeEventStreamServer.Send(event)
+}
+
+// hold откладывает ответ на задание; false — ответ по нему уже ждёт
+func (l *lockedStream) hold(challengeID string, a heldAnswer) bool {
+	l.heldMu.Lock()
+	defer l.heldMu.Unlock()
+	if _, ok := l.held[challengeID]; ok {
+		return false
+	}
+	if l.held == nil {
+		l.held = make(map[string]heldAnswer)
+	}
+	l.held[challengeID] = a
+	return true
+}
+
+// holds — ждёт ли задание ответа на проверки окружения
+func (l *lockedStream) holds(challengeID string) bool {
+	l.heldMu.Lock()
+	defer l.heldMu.Unlock()
+	_, ok := l.held[challengeID]
+	return ok
+}
+
+// take забирает отложенный ответ, чтобы подвести по нему итог. Итог подводится
+// один раз; после take вызывающий обязан вызвать judging.Done.
+func (l *lockedStream) take(challengeID string) (heldAnswer, bool) {
+	l.heldMu.Lock()
+	defer l.heldMu.Unlock()
+	a, ok := l.held[challengeID]
+	if !ok || l.closed {
+		return heldAnswer{}, false
+	}
+	delete(l.held, challengeID)
+	l.judging.Add(1)
+	return a, true
+}
+
+// drain закрывает приём отложенных ответов и возвращает оставшиеся
+func (l *lockedStream) drain() map[string]heldAnswer {
+	l.heldMu.Lock()
+	defer l.heldMu.Unlock()
+	l.closed = true
+	held := l.held
+	l.held = nil
+	return held
+}
+
+func (s *GRPCCaptchaService) MakeEventStream(raw pb.CaptchaService_MakeEventStreamServer) error {
 	s.log.Info("Event stream opened")
+	stream := &lockedStream{CaptchaService_MakeEventStreamServer: raw, recorder: s.recorder}
+	defer s.flush(stream)
 
 	for {
 		clientEvent, err := stream.Recv()
@@ -63,59 +311,202 @@ func (s *GRPCCaptchaService) MakeEventStream(stream pb.CaptchaService_MakeEventS
 	}
 }
 
-func (s *GRPCCaptchaService) handleFrontendEvent(stream pb.CaptchaService_MakeEventStreamServer, event *pb.ClientEvent) error {
-	var payload struct {
-		Event       string `json:"event"`
-		X           int    `json:"x"`
-		Y           int    `json:"y"`
-		Success     bool   `json:"success"`
-		ChallengeID string `json:"challenge_id"`
+func (s *GRPCCaptchaService) handleFrontendEvent(stream *lockedStream, event *pb.ClientEvent) error {
+	// Имена полей у каждого задания свои, DecodeEvent переводит их по словарю задания
+	payload, err := challenge.DecodeEvent(s.store, event.Data)
+	if err != nil {
+		s.log.Warn("Failed to parse event data", slog.Any("error", err))
+		return sendError(stream, "invalid event data")
 	}
 
-	if err := json.Unmarshal(event.Data, &payload); err != nil {
-		s.log.Warn("Failed to parse event data", slog.Any("error", err))
-		return stream.Send(&pb.ServerEvent{
-			Event: &pb.ServerEvent_Result{
-				Result: &pb.ServerEvent_ChallengeResult{
-					ChallengeId:       "error: invalid event data",
-					ConfidencePercent: 0,
-				},
-			},
-		})
+	// Прокси привязывает стрим к заданию из билета сессии, чужие задания через него не решить
+	if event.ChallengeId != "" && payload.ChallengeID != event.ChallengeId {
+		s.log.Warn("Event for foreign challenge rejected",
+			slog.String("stream_challenge_id", event.ChallengeId),
+			slog.String("challenge_id", payload.ChallengeID))
+		return sendError(stream, "challenge does not match session")
 	}
+	s.recorder.Client(payload.ChallengeID, event)
 
 	s.log.Info("Event received",
-		slog.String("event", payload.Event),
+		slog.String("event", payload.Name),
 		slog.String("challenge_id", payload.ChallengeID),
 		slog.Int("x", payload.X),
 		slog.Int("y", payload.Y),
 		slog.Bool("success", payload.Success))
 
-	answer, expectedX, expectedY, exists := s.store.Get(payload.ChallengeID)
-	if !exists {
+	if payload.Name == challenge.EventProbe {
+		score, results, ok := challenge.CheckProbes(s.store, payload.ChallengeID, payload.Data)
+		if ok {
+			s.log.Info("Probe results received",
+				slog.String("challenge_id", payload.ChallengeID),
+				slog.Int("probe_score", score),
+				slog.Any("probes", results))
+		}
+		if held, ok := stream.take(payload.ChallengeID); ok {
+			defer stream.judging.Done()
+			return s.finish(stream, payload.ChallengeID, held)
+		}
+		return nil
+	}
+
+	// Ответ уже получен и ждёт проверок окружения, второй ответ не принимается
+	if stream.holds(payload.ChallengeID) {
+		s.log.Warn("Event after answer ignored",
+			slog.String("challenge_id", payload.ChallengeID),
+			slog.String("event", payload.Name))
+		return nil
+	}
+
+	// Первое событие по заданию — повод выдать клиенту проверки окружения
+	if js, issued := challenge.IssueProbes(s.store, payload.ChallengeID); issued {
+		if err := stream.Send(&pb.ServerEvent{
+			Event: &pb.ServerEvent_ClientJs{
+				ClientJs: &pb.ServerEvent_RunClientJS{
+					ChallengeId: payload.ChallengeID,
+					JsCode:      js,
+				},
+			},
+		}); err != nil {
+			s.log.Error("Failed to send client JS", slog.Any("error", err))
+			return err
+		}
+		s.log.Info("Probes issued", slog.String("challenge_id", payload.ChallengeID))
+		s.recorder.Checkpoint(payload.ChallengeID, challenge.PartProbes)
+	}
+
+	reaction, err := challenge.HandleEvent(s.store, payload)
+	if errors.Is(err, challenge.ErrNotFound) {
 		s.log.Warn("CAPTCHA not found or expired", slog.String("challenge_id", payload.ChallengeID))
-		return stream.Send(&pb.ServerEvent{
-			Event: &pb.ServerEvent_Result{
-				Result: &pb.ServerEvent_ChallengeResult{
-					ChallengeId:       "error: CAPTCHA not found or expired",
-					ConfidencePercent: 0,
+		return sendError(stream, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	// Генератор поменял задание по ходу решения — отправляем изменения клиенту
+	// Изменение задания и расписание подсказок случайны, их запись нужна для повтора
+	if len(reaction.ClientData) > 0 || len(reaction.Pushes) > 0 {
+		s.recorder.Checkpoint(payload.ChallengeID, challenge.PartState)
+	}
+
+	if len(reaction.ClientData) > 0 {
+		if err := stream.Send(&pb.ServerEvent{
+			Event: &pb.ServerEvent_ClientData{
+				ClientData: &pb.ServerEvent_SendClientData{
+					ChallengeId: payload.ChallengeID,
+					Data:        reaction.ClientData,
 				},
 			},
+		}); err != nil {
+			s.log.Error("Failed to send client data", slog.Any("error", err))
+			return err
+		}
+		s.log.Info("Challenge mutated",
+			slog.String("challenge_id", payload.ChallengeID),
+			slog.String("event", payload.Name),
+			slog.Int("bytes", len(reaction.ClientData)))
+	}
+
+	if len(reaction.Pushes) > 0 {
+		go s.push(stream, payload.ChallengeID, reaction.Pushes)
+		s.log.Info("Client updates scheduled",
+			slog.String("challenge_id", payload.ChallengeID),
+			slog.Int("count", len(reaction.Pushes)))
+	}
+
+	if !reaction.Done {
+		return nil
+	}
+
+	answer := heldAnswer{confidence: reaction.Confidence, answeredAt: s.store.Now()}
+	// Быстрый ответ (например, PoW) обгоняет ответ на проверки окружения, а без
+	// него уверенность уменьшается вдвое: итог подводится, когда проверки ответят
+	if challenge.ProbesPending(s.store, payload.ChallengeID) && stream.hold(payload.ChallengeID, answer) {
+		id := payload.ChallengeID
+		time.AfterFunc(challenge.ProbeWait, func() {
+			held, ok := stream.take(id)
+			if !ok {
+				return
+			}
+			defer stream.judging.Done()
+			s.log.Warn("Probe answer timed out", slog.String("challenge_id", id))
+			if err := s.finish(stream, id, held); err != nil {
+				s.log.Error("Failed to finish challenge", slog.String("challenge_id", id), slog.Any("error", err))
+			}
 		})
+		s.log.Info("Result waits for probe answer", slog.String("challenge_id", id))
+		return nil
 	}
+	return s.finish(stream, payload.ChallengeID, answer)
+}
 
-	maxDistance := 50.0 / float64(s.store.GetComplexity(payload.ChallengeID))
-	distance := math.Sqrt(math.Pow(float64(payload.X-expectedX), 2) + math.Pow(float64(payload.Y-expectedY), 2))
-	confidence := 0
-	if distance <= maxDistance && payload.Success && answer == "success" {
-		confidence = 100 - int(distance*100/maxDistance)
+// flush подводит итог по ответам, так и не дождавшимся проверок окружения,
+// и ждёт итогов, которые уже подводятся, чтобы не писать в закрытый стрим
+func (s *GRPCCaptchaService) flush(stream *lockedStream) {
+	for id, held := range stream.drain() {
+		if err := s.finish(stream, id, held); err != nil {
+			s.log.Error("Failed to finish challenge", slog.String("challenge_id", id), slog.Any("error", err))
+		}
+	}
+	stream.judging.Wait()
+}
+
+// finish оценивает ответ на задание и отправляет клиенту следующий этап цепочки или итог
+func (s *GRPCCaptchaService) finish(stream pb.CaptchaService_MakeEventStreamServer, challengeID string, answer heldAnswer) error {
+	verdict := s.scorer.Judge(s.store, challengeID, answer.confidence, answer.answeredAt)
+	confidence := verdict.Confidence
+	s.recorder.Verdict(challengeID, verdict)
+	clientKey := s.store.ClientKey(challengeID)
+
+	// Пройденный этап цепочки сменяется следующим, итог отправляется один на всю цепочку
+	next, total, err := challenge.CompleteStage(s.store, challengeID, confidence, confidence >= risk.PassThreshold)
+	if err != nil {
+		s.log.Error("Failed to generate next stage", slog.Any("error", err))
+		return sendError(stream, "failed to generate next stage")
+	}
+	if next != nil {
+		if err := stream.Send(&pb.ServerEvent{
+			Event: &pb.ServerEvent_Stage{
+				Stage: &pb.ServerEvent_NextStage{
+					ChallengeId:           next.ID,
+					Html:                  next.HTML,
+					ChallengeType:         next.Type,
+					ContentSecurityPolicy: next.CSP,
+					Stage:                 int32(next.Stage),
+					Stages:                int32(next.Stages),
+				},
+			},
+		}); err != nil {
+			s.log.Error("Failed to send next stage", slog.Any("error", err))
+			return err
+		}
+		s.log.Info("Stage passed",
+			slog.String("challenge_id", challengeID),
+			slog.Int("confidence", confidence),
+			slog.Int("next_stage", next.Stage),
+			slog.String("next_type", next.Type))
+		s.recorder.Checkpoint(next.ID, challenge.PartAll)
+		return nil
+	}
+	confidence = total
+
+	// За пройденную капчу клиент получает пропуск, подписанный ключом сайта,
+	// который целевой сервис проверит через VerifyToken
+	var token string
+	if confidence >= risk.PassThreshold {
+		bound := s.store.Site(challengeID)
+		if site, err := s.tenants.Lookup(bound.Key); err == nil {
+			token = site.Passes.Issue(challengeID, site.SiteKey, bound.Hostname, bound.Action, confidence)
+		}
 	}
 
 	result := &pb.ServerEvent{
 		Event: &pb.ServerEvent_Result{
 			Result: &pb.ServerEvent_ChallengeResult{
-				ChallengeId:       payload.ChallengeID,
+				ChallengeId:       challengeID,
 				ConfidencePercent: int32(confidence),
+				Token:             token,
 			},
 		},
 	}
@@ -126,10 +517,58 @@ func (s *GRPCCaptchaService) handleFrontendEvent(stream pb.CaptchaService_MakeEv
 	}
 
 	s.log.Info("Result sent",
-		slog.String("challenge_id", payload.ChallengeID),
+		slog.String("challenge_id", challengeID),
 		slog.Int("confidence", confidence),
-		slog.Float64("distance", distance))
+		slog.String("model", verdict.Model),
+		slog.Bool("pass_token", token != ""),
+		slog.Int("probe_score", verdict.ProbeScore),
+		slog.Duration("elapsed", verdict.Timing.Elapsed),
+		slog.Duration("reaction", verdict.Timing.Reaction))
+
+	s.recorder.Finish(challengeID, confidence)
+	if clientKey != "" {
+		s.risk.RecordResult(clientKey, confidence, verdict.Timing.Elapsed)
+	}
 
-	s.store.Delete(payload.ChallengeID)
+	s.store.Delete(challengeID)
 	return nil
 }
+
+// push отправляет клиенту отложенные обновления задания в назначенное генератором
+// время, пока стрим открыт
+func (s *GRPCCaptchaService) push(stream pb.CaptchaService_MakeEventStreamServer, challengeID string, pushes []challenge.Push) {
+	ctx := stream.Context()
+	for _, p := range pushes {
+		timer := time.NewTimer(time.Until(p.At))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := stream.Send(&pb.ServerEvent{
+			Event: &pb.ServerEvent_ClientData{
+				ClientData: &pb.ServerEvent_SendClientData{
+					ChallengeId: challengeID,
+					Data:        p.Data,
+				},
+			},
+		}); err != nil {
+			s.log.Error("Failed to push client data", slog.String("challenge_id", challengeID), slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// sendError сообщает клиенту об ошибке в прежнем формате:
+// результатом с нулевой уверенностью и текстом ошибки вместо ID задания
+func sendError(stream pb.CaptchaService_MakeEventStreamServer, msg string) error {
+	return stream.Send(&pb.ServerEvent{
+		Event: &pb.ServerEvent_Result{
+			Result: &pb.ServerEvent_ChallengeResult{
+				ChallengeId:       "error: " + msg,
+				ConfidencePercent: 0,
+			},
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package harness
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"time"
+)
+
+// Кодировщики данных событий повторяют скрипты заданий из internal/challenge/assets.
+// Точки пакуются как x<<13|y, числа — big-endian, всё в base64.
+
+const coordMask = 1<<13 - 1
+
+func packPoint(p image.Point) uint32 {
+	return uint32(p.X&coordMask)<<13 | uint32(p.Y&coordMask)
+}
+
+func ms(d time.Duration) uint16 {
+	return uint16(min(65535, max(0, d.Milliseconds())))
+}
+
+// Click — клик click-sequence и пауза после предыдущего
+type Click struct {
+	P     image.Point
+	Pause time.Duration
+}
+
+// Trace кодирует кусок пути maze: первый кусок начинается с точки старта,
+// смещения длиннее 127 дробятся, как в скрипте
+func Trace(start *image.Point, steps []image.Point) string {
+	var b []byte
+	if start != nil {
+		b = binary.BigEndian.AppendUint32(b, packPoint(*start))
+	}
+	for _, d := range steps {
+		for d != (image.Point{}) {
+			s := image.Pt(max(-127, min(127, d.X)), max(-127, min(127, d.Y)))
+			b = append(b, byte(int8(s.X)), byte(int8(s.Y)))
+			d = d.Sub(s)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// KeyPress кодирует нажатие rhythm: номер подсказки, цифра и реакция по часам клиента
+func KeyPress(seq, digit int, reaction time.Duration) string {
+	b := []byte{byte(seq), byte(digit)}
+	b = binary.BigEndian.AppendUint16(b, ms(reaction))
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Op — операция частичного обновления задания от сервера
+type Op struct {
+	Code byte
+	Data []byte
+}
+
+// Операции обновления, как их понимают скрипты заданий
+const (
+	OpField    byte = 1
+	OpMarkTile byte = 2
+	OpPrompt   byte = 3
+)
+
+// DecodeOps разбирает обновление задания: [op][длина uint32][данные]...
+func DecodeOps(data []byte) []Op {
+	var ops []Op
+	for len(data) >= 5 {
+		n := int(binary.BigEndian.Uint32(data[1:5]))
+		if n > len(data)-5 {
+			break
+		}
+		ops = append(ops, Op{Code: data[0], Data: data[5 : 5+n]})
+		data = data[5+n:]
+	}
+	return ops
+}
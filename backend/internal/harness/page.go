@@ -0,0 +1,112 @@
+// Package harness — клиентская сторона протокола заданий для служебных утилит
+// (нагрузочного теста, ботов): разбор HTML задания и сборка событий в его именах полей.
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// readyEvent — событие, которое скрипт любого задания шлёт после загрузки.
+// По нему находится случайное имя поля с названием события.
+var readyEvent = regexp.MustCompile(`[{,]\s*(\w+)\s*:\s*'ready'`)
+
+// Page — задание, каким его видит клиент
+type Page struct {
+	ChallengeID string
+	Type        string
+	HTML        string
+
+	// Случайные имена полей событий этого задания; пустое, если в скрипте его нет
+	EventKey, XKey, YKey, SuccessKey, DataKey string
+}
+
+// Event — событие в терминах Event сервиса, до перевода в имена полей задания
+type Event struct {
+	Name    string
+	X, Y    int
+	Success bool
+	Data    string
+}
+
+// ErrNoEvents — в HTML не нашлось скрипта, который отправляет события
+var ErrNoEvents = errors.New("challenge script sends no events")
+
+// Parse восстанавливает имена полей событий по литералам объектов в скрипте задания.
+// Скрипты собирают события как {event: 'name', x: ..., y: ..., success: true}
+// или {event: 'name', data: ...}, порядок полей в них постоянный.
+func Parse(challengeID, kind, html string) (*Page, error) {
+	p := &Page{ChallengeID: challengeID, Type: kind, HTML: html}
+	m := readyEvent.FindStringSubmatch(html)
+	if m == nil {
+		return nil, ErrNoEvents
+	}
+	p.EventKey = m[1]
+
+	open := "{" + p.EventKey + ":"
+	for rest := html; ; {
+		i := strings.Index(rest, open)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+1:]
+		keys, values := objectKeys(rest)
+		switch {
+		case len(keys) == 4 && values[3] == "true":
+			p.XKey, p.YKey, p.SuccessKey = keys[1], keys[2], keys[3]
+		case len(keys) == 3:
+			p.XKey, p.YKey = keys[1], keys[2]
+		case len(keys) == 2:
+			p.DataKey = keys[1]
+		}
+	}
+	return p, nil
+}
+
+// objectKeys разбирает верхний уровень литерала объекта до закрывающей скобки:
+// имена ключей и их значения как текст
+func objectKeys(src string) ([]string, []string) {
+	var keys, values []string
+	depth, start := 0, 0
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || (c == '}' && depth > 0):
+			depth--
+		case c == '\'' || c == '"':
+			if j := strings.IndexByte(src[i+1:], c); j >= 0 {
+				i += j + 1
+			}
+		case depth == 0 && (c == ',' || c == '}'):
+			if k := strings.IndexByte(src[start:i], ':'); k > 0 {
+				keys = append(keys, strings.TrimSpace(src[start:start+k]))
+				values = append(values, strings.TrimSpace(src[start+k+1:i]))
+			}
+			if c == '}' {
+				return keys, values
+			}
+			start = i + 1
+		}
+	}
+	return keys, values
+}
+
+// Encode собирает сообщение события в именах полей задания. Нулевые значения
+// не отправляются, сервис и так считает пропущенные поля нулевыми.
+func (p *Page) Encode(e Event) []byte {
+	msg := map[string]any{"challenge_id": p.ChallengeID, p.EventKey: e.Name}
+	if p.XKey != "" && (e.X != 0 || e.Y != 0) {
+		msg[p.XKey], msg[p.YKey] = e.X, e.Y
+	}
+	if p.SuccessKey != "" && e.Success {
+		msg[p.SuccessKey] = true
+	}
+	if p.DataKey != "" && e.Data != "" {
+		msg[p.DataKey] = e.Data
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
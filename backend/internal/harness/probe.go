@@ -0,0 +1,87 @@
+package harness
+
+import (
+	"encoding/json"
+	"math/rand"
+	"regexp"
+	"strings"
+)
+
+// Проверки окружения приходят как JS, который вычисляет выражения и отправляет
+// их значения под случайными ключами. Подделка не исполняет JS: она узнаёт
+// выражения по тексту и подставляет ответы обычного браузера.
+var (
+	probeKeys  = regexp.MustCompile(`var k = \[([^\]]*)\]`)
+	probeToken = regexp.MustCompile(`^'([0-9a-f]+)'$`)
+	probeSend  = regexp.MustCompile(`(\w+): JSON\.stringify\(o\)`)
+)
+
+const (
+	probeExprStart = "Promise.resolve().then(function () { return "
+	probeExprEnd   = "; }).catch("
+)
+
+// browserAnswers — ответы обычного браузера по узнаваемому фрагменту выражения
+var browserAnswers = []struct {
+	marker string
+	answer func() any
+}{
+	{"navigator.webdriver", func() any { return false }},
+	{"Headless", func() any { return false }},
+	{"navigator.languages", func() any { return 2 }},
+	{"outerWidth", func() any { return true }},
+	{"requestAnimationFrame", func() any {
+		deltas := make([]float64, 5)
+		for i := range deltas {
+			deltas[i] = 16.6 + rand.Float64()*0.3
+		}
+		return deltas
+	}},
+	{"getImageData", func() any { return 1 + rand.Uint32()>>1 }},
+	{"UNMASKED_RENDERER_WEBGL", func() any {
+		return "ANGLE (Intel, Intel(R) UHD Graphics 620 Direct3D11 vs_5_0 ps_5_0, D3D11)"
+	}},
+}
+
+// ForgeProbes собирает ответ на проверки окружения — значение поля data события probe —
+// и возвращает имя этого поля: в заданиях, которые сами data не шлют, его больше негде узнать
+func ForgeProbes(js string) (string, string, bool) {
+	m := probeKeys.FindStringSubmatch(js)
+	send := probeSend.FindStringSubmatch(js)
+	if m == nil || send == nil {
+		return "", "", false
+	}
+	var keys []string
+	for _, k := range strings.Split(m[1], ",") {
+		keys = append(keys, strings.Trim(strings.TrimSpace(k), "'"))
+	}
+
+	var exprs []string
+	for _, chunk := range strings.Split(js, probeExprStart)[1:] {
+		expr, _, ok := strings.Cut(chunk, probeExprEnd)
+		if !ok {
+			return "", "", false
+		}
+		exprs = append(exprs, strings.TrimSpace(expr))
+	}
+	if len(exprs) != len(keys) {
+		return "", "", false
+	}
+
+	answers := make(map[string]any, len(keys))
+	for i, expr := range exprs {
+		answers[keys[i]] = nil
+		if t := probeToken.FindStringSubmatch(expr); t != nil {
+			answers[keys[i]] = t[1]
+			continue
+		}
+		for _, known := range browserAnswers {
+			if strings.Contains(expr, known.marker) {
+				answers[keys[i]] = known.answer()
+				break
+			}
+		}
+	}
+	data, _ := json.Marshal(answers)
+	return string(data), send[1], true
+}
@@ -0,0 +1,360 @@
+package harness
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+)
+
+// Session — задание и канал его событий до сервиса: WebSocket под билетом,
+// полученным через /captcha, как у страницы с капчей в браузере (Open), или
+// стрим MakeEventStream прямо к инстансу, как у прокси (Stream)
+type Session struct {
+	Page   *Page
+	Ticket string
+
+	// ForgeProbes отвечает на проверки окружения так, как ответил бы обычный браузер;
+	// без него проверки остаются без ответа, как у клиента, не исполняющего JS
+	ForgeProbes bool
+
+	write   func(challengeID string, data []byte) error // отправка события в канал
+	close   func() error
+	mu      sync.Mutex // запись в канал и transcript
+	started time.Time
+	sent    []Sent
+	events  chan ServerEvent
+	err     error
+}
+
+// Sent — отправленное событие и когда оно ушло относительно начала сессии
+type Sent struct {
+	At    time.Duration
+	Event Event
+}
+
+// ServerEvent — сообщение сервиса, пересланное прокси. Заполнено одно из полей.
+type ServerEvent struct {
+	Result *Result
+	Data   []byte // частичное обновление задания
+	JS     string // проверки окружения
+	Stage  *Stage
+}
+
+// Result — итог задания; при ошибке ChallengeID начинается с "error:"
+type Result struct {
+	ChallengeID string `json:"challenge_id"`
+	Confidence  int    `json:"confidence_percent"`
+	Token       string `json:"token"` // пропуск за пройденную капчу
+}
+
+// Stage — следующий этап цепочки под той же сессией
+type Stage struct {
+	ChallengeID string `json:"challenge_id"`
+	HTML        string `json:"html"`
+	Type        string `json:"challenge_type"`
+	Stage       int    `json:"stage"`
+	Stages      int    `json:"stages"`
+}
+
+// Options — параметры запроса задания
+type Options struct {
+	Origin     string // origin встраивающей страницы, должен быть в allowed_origins
+	UserAgent  string // вместе с адресом клиента образует ключ его профиля риска
+	Complexity int
+	Accessible bool
+	Action     string // действие на сайте, попадает в пропуск
+	SiteKey    string // ключ сайта, пусто — общие настройки сервиса
+}
+
+// Open запрашивает задание у HTTP-сервера капчи base (http://host:8080),
+// открывает WebSocket по выданному билету и отправляет ready, как загруженный iframe
+func Open(ctx context.Context, base string, opts Options) (*Session, error) {
+	q := url.Values{}
+	if opts.Complexity > 0 {
+		q.Set("complexity", strconv.Itoa(opts.Complexity))
+	}
+	if opts.Accessible {
+		q.Set("accessible", "1")
+	}
+	if opts.Action != "" {
+		q.Set("action", opts.Action)
+	}
+	if opts.SiteKey != "" {
+		q.Set("sitekey", opts.SiteKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/captcha?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Origin != "" {
+		req.Header.Set("Origin", opts.Origin)
+	}
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("captcha request failed: %s", resp.Status)
+	}
+
+	ticket := resp.Header.Get("X-Captcha-Ticket")
+	id, err := TicketChallengeID(ticket)
+	if err != nil {
+		return nil, err
+	}
+	page, err := Parse(id, resp.Header.Get("X-Captcha-Type"), string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := Dial(ctx, base, ticket, opts.Origin, opts.SiteKey)
+	if err != nil {
+		return nil, err
+	}
+	s.Page = page
+	if err := s.Send(Event{Name: "ready"}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Dial открывает WebSocket по билету без запроса задания — например, чтобы
+// проверить, что билет завершённой сессии больше не действует. siteKey должен
+// совпасть с тем, для которого выдан билет.
+func Dial(ctx context.Context, base, ticket, origin, siteKey string) (*Session, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = "/ws"
+	q := url.Values{"ticket": {ticket}}
+	if siteKey != "" {
+		q.Set("sitekey", siteKey)
+	}
+	u.RawQuery = q.Encode()
+
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+		}
+		return nil, err
+	}
+
+	s := &Session{
+		Ticket: ticket,
+		write: func(_ string, data []byte) error {
+			msg, _ := json.Marshal(map[string]string{"type": "captcha:sendData", "data": string(data)})
+			return conn.WriteMessage(websocket.TextMessage, msg)
+		},
+		close:   conn.Close,
+		started: time.Now(),
+		events:  make(chan ServerEvent, 32),
+	}
+	go s.readSocket(conn)
+	return s, nil
+}
+
+// Stream открывает MakeEventStream к инстансу капчи для уже выданного задания page
+// и отправляет ready. Прокси в этом пути нет, поэтому билет не нужен.
+func Stream(ctx context.Context, client pb.CaptchaServiceClient, page *Page) (*Session, error) {
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Page: page,
+		write: func(challengeID string, data []byte) error {
+			return stream.Send(&pb.ClientEvent{
+				EventType:   pb.ClientEvent_FRONTEND_EVENT,
+				ChallengeId: challengeID,
+				Data:        data,
+			})
+		},
+		started: time.Now(),
+		events:  make(chan ServerEvent, 32),
+	}
+	s.close = func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		stream.Send(&pb.ClientEvent{EventType: pb.ClientEvent_CONNECTION_CLOSED, ChallengeId: s.Page.ChallengeID})
+		return stream.CloseSend()
+	}
+	go s.readStream(stream)
+	if err := s.Send(Event{Name: "ready"}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// TicketChallengeID достаёт ID задания из билета сессии: подписан билет, но не зашифрован
+func TicketChallengeID(ticket string) (string, error) {
+	payload, _, _ := strings.Cut(ticket, ".")
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || payload == "" {
+		return "", errors.New("malformed session ticket")
+	}
+	id, _, _ := strings.Cut(string(raw), "|")
+	return id, nil
+}
+
+func (s *Session) readSocket(conn *websocket.Conn) {
+	defer close(s.events)
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+		var raw struct {
+			Event struct {
+				Result     *Result
+				ClientData *struct {
+					Data []byte `json:"data"`
+				}
+				ClientJs *struct {
+					JS string `json:"js_code"`
+				}
+				Stage *Stage
+			}
+		}
+		if err := json.Unmarshal(msg, &raw); err != nil {
+			continue
+		}
+		ev := ServerEvent{Result: raw.Event.Result, Stage: raw.Event.Stage}
+		if raw.Event.ClientData != nil {
+			ev.Data = raw.Event.ClientData.Data
+		}
+		if raw.Event.ClientJs != nil {
+			ev.JS = raw.Event.ClientJs.JS
+		}
+		s.events <- ev
+	}
+}
+
+func (s *Session) readStream(stream pb.CaptchaService_MakeEventStreamClient) {
+	defer close(s.events)
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			s.err = err
+			return
+		}
+		var ev ServerEvent
+		switch e := msg.Event.(type) {
+		case *pb.ServerEvent_Result:
+			ev.Result = &Result{ChallengeID: e.Result.ChallengeId, Confidence: int(e.Result.ConfidencePercent), Token: e.Result.Token}
+		case *pb.ServerEvent_ClientData:
+			ev.Data = e.ClientData.Data
+		case *pb.ServerEvent_ClientJs:
+			ev.JS = e.ClientJs.JsCode
+		case *pb.ServerEvent_Stage:
+			ev.Stage = &Stage{
+				ChallengeID: e.Stage.ChallengeId,
+				HTML:        e.Stage.Html,
+				Type:        e.Stage.ChallengeType,
+				Stage:       int(e.Stage.Stage),
+				Stages:      int(e.Stage.Stages),
+			}
+		default:
+			continue
+		}
+		s.events <- ev
+	}
+}
+
+// Send отправляет событие задания так же, как его отправил бы скрипт в iframe
+func (s *Session) Send(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, Sent{At: time.Since(s.started), Event: e})
+	return s.write(s.Page.ChallengeID, s.Page.Encode(e))
+}
+
+// Next ждёт следующее сообщение сервиса. Проверки окружения обрабатываются
+// здесь же согласно ForgeProbes, а при переходе на следующий этап цепочки
+// Page заменяется заданием нового этапа.
+func (s *Session) Next(ctx context.Context) (ServerEvent, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return ServerEvent{}, ctx.Err()
+		case ev, ok := <-s.events:
+			if !ok {
+				if s.err == nil {
+					s.err = io.EOF
+				}
+				return ServerEvent{}, fmt.Errorf("connection closed: %w", s.err)
+			}
+			if ev.JS != "" {
+				if s.ForgeProbes {
+					if answer, key, ok := ForgeProbes(ev.JS); ok {
+						if s.Page.DataKey == "" {
+							s.Page.DataKey = key
+						}
+						if err := s.Send(Event{Name: "probe", Data: answer}); err != nil {
+							return ServerEvent{}, err
+						}
+					}
+				}
+				continue
+			}
+			if ev.Stage != nil {
+				page, err := Parse(ev.Stage.ChallengeID, ev.Stage.Type, ev.Stage.HTML)
+				if err != nil {
+					return ServerEvent{}, err
+				}
+				s.Page = page
+			}
+			return ev, nil
+		}
+	}
+}
+
+// Await пропускает обновления задания до итога или следующего этапа
+func (s *Session) Await(ctx context.Context) (ServerEvent, error) {
+	for {
+		ev, err := s.Next(ctx)
+		if err != nil || ev.Result != nil || ev.Stage != nil {
+			return ev, err
+		}
+	}
+}
+
+// Transcript возвращает отправленные события с их временем
+func (s *Session) Transcript() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+func (s *Session) Close() error {
+	return s.close()
+}
@@ -0,0 +1,81 @@
+// Package metrics отдаёт метрики сервиса в текстовом формате Prometheus.
+// Метрик немного, поэтому вместо клиентской библиотеки каждая подсистема
+// сама пишет свои значения в Writer при каждом запросе /metrics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// Collector пишет метрики подсистемы
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// CollectorFunc позволяет использовать функцию как Collector
+type CollectorFunc func(w *Writer)
+
+func (f CollectorFunc) Collect(w *Writer) { f(w) }
+
+// Writer записывает метрики, выводя HELP и TYPE перед первым значением каждой метрики.
+// Значения одной метрики должны идти подряд.
+type Writer struct {
+	w     *bufio.Writer
+	known map[string]bool
+}
+
+// Gauge записывает текущее значение. Метки передаются парами имя, значение.
+func (w *Writer) Gauge(name, help string, value float64, labels ...string) {
+	w.write(name, help, "gauge", value, labels)
+}
+
+// Counter записывает значение монотонно растущего счётчика
+func (w *Writer) Counter(name, help string, value float64, labels ...string) {
+	w.write(name, help, "counter", value, labels)
+}
+
+func (w *Writer) write(name, help, kind string, value float64, labels []string) {
+	if !w.known[name] {
+		w.known[name] = true
+		fmt.Fprintf(w.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	w.w.WriteString(name)
+	if len(labels) > 0 {
+		w.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			// %q экранирует кавычки, обратную косую черту и переводы строк, как требует формат
+			fmt.Fprintf(w.w, "%s=%q", labels[i], labels[i+1])
+		}
+		w.w.WriteByte('}')
+	}
+	fmt.Fprintf(w.w, " %g\n", value)
+}
+
+// Handler отдаёт метрики всех коллекторов
+func Handler(collectors ...Collector) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := &Writer{w: bufio.NewWriter(rw), known: make(map[string]bool)}
+		for _, c := range collectors {
+			c.Collect(w)
+		}
+		w.w.Flush()
+	})
+}
+
+// Runtime отдаёт память и горутины процесса
+var Runtime = CollectorFunc(func(w *Writer) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	w.Gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	w.Gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(m.HeapAlloc))
+	w.Gauge("go_memstats_heap_inuse_bytes", "Number of bytes in in-use heap spans.", float64(m.HeapInuse))
+	w.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from the OS.", float64(m.Sys))
+	w.Counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(m.NumGC))
+})
@@ -0,0 +1,384 @@
+// Package recording пишет выборку сессий заданий в файл, чтобы повторить их
+// проверку на другой версии сервиса (cmd/replay).
+//
+// Сессия — это снимок задания при выдаче и все события клиента и сервиса по нему,
+// вместе со снимками случайных решений, которые сервис принял по ходу решения.
+// Каждая сессия пишется в файл отдельным gzip-потоком, когда пришёл итог или
+// сессия заброшена, поэтому файл можно дописывать между перезапусками.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Kind — что записано в Entry
+type Kind uint8
+
+const (
+	KindClient   Kind = iota + 1 // событие клиента, Data — pb.ClientEvent в protobuf
+	KindServer                   // сообщение сервиса, Data — pb.ServerEvent в protobuf
+	KindSnapshot                 // снимок части задания, Data — из ChallengeStore.Snapshot
+	KindVerdict                  // из чего сложилась уверенность по этапу, Data — challenge.Verdict в gob
+)
+
+// Entry — одна запись сессии; At — по часам хранилища заданий
+type Entry struct {
+	At   time.Time
+	Kind Kind
+	Part challenge.Part // для KindSnapshot
+	Data []byte
+}
+
+// NoResult — Confidence сессии, по которой итог так и не отправлен
+const NoResult = -1
+
+// Session — записанная сессия задания
+type Session struct {
+	ChallengeID string
+	Type        string
+	Complexity  int
+	Stages      []string
+	Started     time.Time
+	Entries     []Entry
+	Confidence  int // итог всей цепочки или NoResult
+
+	touched time.Time
+}
+
+// Verdicts возвращает разбор уверенности по каждому завершённому этапу сессии
+func (s *Session) Verdicts() []challenge.Verdict {
+	var verdicts []challenge.Verdict
+	for _, e := range s.Entries {
+		var v challenge.Verdict
+		if e.Kind == KindVerdict && gob.NewDecoder(bytes.NewReader(e.Data)).Decode(&v) == nil {
+			verdicts = append(verdicts, v)
+		}
+	}
+	return verdicts
+}
+
+// idleTimeout — через сколько без событий сессия считается заброшенной
+// и пишется без итога; задание к этому времени уже истекло
+const idleTimeout = 10 * time.Minute
+
+// Recorder пишет сессии, выбранные с вероятностью rate. Выключенный (nil)
+// Recorder ничего не делает, поэтому вызывать его можно без проверок.
+type Recorder struct {
+	store *challenge.ChallengeStore
+	rate  float64
+	log   *slog.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+
+	fileMu  sync.Mutex
+	file    *os.File         // nil у Recorder из NewCapture
+	capture func(s *Session) // куда уходят сессии без файла
+}
+
+// New открывает файл записи на дозапись
+func New(store *challenge.ChallengeStore, path string, rate float64, log *slog.Logger) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+	return &Recorder{
+		store:    store,
+		rate:     rate,
+		log:      log,
+		sessions: make(map[string]*Session),
+		file:     file,
+	}, nil
+}
+
+// NewCapture записывает все сессии в память и отдаёт завершённые в fn
+func NewCapture(store *challenge.ChallengeStore, fn func(s *Session), log *slog.Logger) *Recorder {
+	return &Recorder{
+		store:    store,
+		rate:     1,
+		log:      log,
+		sessions: make(map[string]*Session),
+		capture:  fn,
+	}
+}
+
+// Start решает, записывать ли только что выданное задание, и снимает его целиком
+func (r *Recorder) Start(challengeID, kind string, complexity int, stages []string) {
+	if r == nil || rand.Float64() >= r.rate {
+		return
+	}
+	snap, err := r.store.Snapshot(challengeID, challenge.PartAll)
+	if err != nil {
+		r.log.Warn("Failed to snapshot challenge", slog.String("challenge_id", challengeID), slog.Any("error", err))
+		return
+	}
+	now := r.store.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[challengeID] = &Session{
+		ChallengeID: challengeID,
+		Type:        kind,
+		Complexity:  complexity,
+		Stages:      stages,
+		Started:     now,
+		Entries:     []Entry{{At: now, Kind: KindSnapshot, Part: challenge.PartAll, Data: snap}},
+		Confidence:  NoResult,
+		touched:     now,
+	}
+}
+
+// Client записывает событие клиента по заданию
+func (r *Recorder) Client(challengeID string, event *pb.ClientEvent) {
+	r.message(challengeID, KindClient, event)
+}
+
+// Server записывает сообщение сервиса клиенту
+func (r *Recorder) Server(event *pb.ServerEvent) {
+	r.message(ServerChallengeID(event), KindServer, event)
+}
+
+func (r *Recorder) message(challengeID string, kind Kind, m proto.Message) {
+	if r == nil || !r.recording(challengeID) {
+		return
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		r.log.Warn("Failed to encode recorded event", slog.String("challenge_id", challengeID), slog.Any("error", err))
+		return
+	}
+	r.add(challengeID, Entry{Kind: kind, Data: data})
+}
+
+// Checkpoint снимает часть задания после случайного решения сервиса: выдачи
+// проверок окружения, изменения задания или следующего этапа цепочки
+func (r *Recorder) Checkpoint(challengeID string, part challenge.Part) {
+	if r == nil || !r.recording(challengeID) {
+		return
+	}
+	snap, err := r.store.Snapshot(challengeID, part)
+	if err != nil {
+		r.log.Warn("Failed to snapshot challenge", slog.String("challenge_id", challengeID), slog.Any("error", err))
+		return
+	}
+	r.add(challengeID, Entry{Kind: KindSnapshot, Part: part, Data: snap})
+}
+
+// Verdict записывает, из чего сложилась уверенность по завершённому этапу
+func (r *Recorder) Verdict(challengeID string, v challenge.Verdict) {
+	if r == nil || !r.recording(challengeID) {
+		return
+	}
+	data, err := gobBytes(v)
+	if err != nil {
+		r.log.Warn("Failed to encode verdict", slog.String("challenge_id", challengeID), slog.Any("error", err))
+		return
+	}
+	r.add(challengeID, Entry{Kind: KindVerdict, Data: data})
+}
+
+// Finish запоминает итог сессии и пишет её в файл
+func (r *Recorder) Finish(challengeID string, confidence int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	s := r.sessions[challengeID]
+	delete(r.sessions, challengeID)
+	r.mu.Unlock()
+	if s == nil {
+		return
+	}
+	s.Confidence = confidence
+	r.write(s)
+}
+
+func (r *Recorder) recording(challengeID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[challengeID] != nil
+}
+
+func (r *Recorder) add(challengeID string, e Entry) {
+	e.At = r.store.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.sessions[challengeID]; s != nil {
+		s.Entries = append(s.Entries, e)
+		s.touched = e.At
+	}
+}
+
+// Run пишет заброшенные сессии, пока ctx не отменён
+func (r *Recorder) Run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.flush(r.store.Now().Add(-idleTimeout))
+		}
+	}
+}
+
+// Close пишет все незавершённые сессии и закрывает файл
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.flush(time.Time{})
+	if r.file == nil {
+		return nil
+	}
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+	return r.file.Close()
+}
+
+// flush пишет сессии без событий с момента before; нулевое before — все
+func (r *Recorder) flush(before time.Time) {
+	var idle []*Session
+	r.mu.Lock()
+	for id, s := range r.sessions {
+		if before.IsZero() || s.touched.Before(before) {
+			idle = append(idle, s)
+			delete(r.sessions, id)
+		}
+	}
+	r.mu.Unlock()
+	for _, s := range idle {
+		r.write(s)
+	}
+}
+
+// write дописывает сессию отдельным gzip-потоком: [длина uvarint][gob Session]
+func (r *Recorder) write(s *Session) {
+	if r.capture != nil {
+		r.capture(s)
+		return
+	}
+	data, err := encode(s)
+	if err == nil {
+		r.fileMu.Lock()
+		zw := gzip.NewWriter(r.file)
+		_, err = zw.Write(data)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		r.fileMu.Unlock()
+	}
+	if err != nil {
+		r.log.Error("Failed to write recorded session", slog.String("challenge_id", s.ChallengeID), slog.Any("error", err))
+		return
+	}
+	r.log.Debug("Session recorded",
+		slog.String("challenge_id", s.ChallengeID),
+		slog.Int("entries", len(s.Entries)),
+		slog.Int("confidence", s.Confidence))
+}
+
+func encode(s *Session) ([]byte, error) {
+	body, err := gobBytes(s)
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.AppendUvarint(nil, uint64(len(body))), body...), nil
+}
+
+func gobBytes(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Reader читает сессии из файла записи по одной
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader начинает чтение файла записи
+func NewReader(r io.Reader) (*Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return &Reader{r: bufio.NewReader(zr)}, nil
+}
+
+// Next возвращает следующую сессию или io.EOF
+func (r *Reader) Next() (*Session, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return nil, fmt.Errorf("truncated session: %w", err)
+	}
+	var s Session
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &s, nil
+}
+
+// ReadFile читает все сессии файла записи и отдаёт их fn по одной
+func ReadFile(path string, fn func(s *Session)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		return err
+	}
+	for {
+		s, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(s)
+	}
+}
+
+// ServerChallengeID — ID задания, к которому относится сообщение сервиса
+func ServerChallengeID(event *pb.ServerEvent) string {
+	switch e := event.Event.(type) {
+	case *pb.ServerEvent_Result:
+		return e.Result.ChallengeId
+	case *pb.ServerEvent_ClientJs:
+		return e.ClientJs.ChallengeId
+	case *pb.ServerEvent_ClientData:
+		return e.ClientData.ChallengeId
+	case *pb.ServerEvent_Stage:
+		return e.Stage.ChallengeId
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Сессия, записанная в файл, читается обратно целиком, а её снимок
+// восстанавливает задание в том же состоянии
+func TestRecordingRoundTrip(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := challenge.NewStoreWithClock(func() time.Time { return now })
+	task, err := challenge.NewChain(store, nil, []string{challenge.TypeDragDrop}, 30)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "sessions.rec")
+	rec, err := New(store, path, 1, log)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	event := &pb.ClientEvent{EventType: pb.ClientEvent_FRONTEND_EVENT, ChallengeId: task.ID, Data: []byte(`{"x":1}`)}
+	verdict := challenge.Verdict{Type: task.Type, Complexity: 30, Raw: 90, ProbeScore: 100, Confidence: 85, Model: "formula", Features: map[string]float64{"raw": 0.9}}
+	rec.Start(task.ID, task.Type, task.Complexity, nil)
+	now = now.Add(time.Second)
+	rec.Client(task.ID, event)
+	rec.Checkpoint(task.ID, challenge.PartState)
+	rec.Verdict(task.ID, verdict)
+	rec.Finish(task.ID, 85)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var sessions []*Session
+	if err := ReadFile(path, func(s *Session) { sessions = append(sessions, s) }); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("read %d sessions, want 1", len(sessions))
+	}
+	s := sessions[0]
+	if s.ChallengeID != task.ID || s.Type != task.Type || s.Complexity != task.Complexity || s.Confidence != 85 {
+		t.Fatalf("got session %s %s complexity %d confidence %d", s.ChallengeID, s.Type, s.Complexity, s.Confidence)
+	}
+	kinds := []Kind{KindSnapshot, KindClient, KindSnapshot, KindVerdict}
+	if len(s.Entries) != len(kinds) {
+		t.Fatalf("got %d entries, want %d", len(s.Entries), len(kinds))
+	}
+	for i, e := range s.Entries {
+		if e.Kind != kinds[i] {
+			t.Fatalf("entry %d: got kind %d, want %d", i, e.Kind, kinds[i])
+		}
+	}
+	if !s.Entries[1].At.Equal(now) {
+		t.Fatalf("client event recorded at %v, want %v", s.Entries[1].At, now)
+	}
+
+	var got pb.ClientEvent
+	if err := proto.Unmarshal(s.Entries[1].Data, &got); err != nil || !proto.Equal(&got, event) {
+		t.Fatalf("client event: got %v, %v", &got, err)
+	}
+	if v := s.Verdicts(); len(v) != 1 || !reflect.DeepEqual(v[0], verdict) {
+		t.Fatalf("got verdicts %+v", v)
+	}
+
+	restored := challenge.NewStoreWithClock(func() time.Time { return now })
+	if err := restored.Restore(s.ChallengeID, challenge.PartAll, s.Entries[0].Data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if kind, _ := restored.Kind(task.ID); kind != task.Type || restored.GetComplexity(task.ID) != task.Complexity {
+		t.Fatalf("restored %s at complexity %d", kind, restored.GetComplexity(task.ID))
+	}
+	want, _ := store.Snapshot(task.ID, challenge.PartState)
+	state, _ := restored.Snapshot(task.ID, challenge.PartState)
+	if !bytes.Equal(state, want) {
+		t.Fatal("restored challenge state differs from the recorded one")
+	}
+}
+
+// Без снимка задание не начато, и писать нечего
+func TestRecorderSkipsUnknownChallenge(t *testing.T) {
+	store := challenge.NewStoreWithClock(time.Now)
+	var captured []*Session
+	rec := NewCapture(store, func(s *Session) { captured = append(captured, s) }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	rec.Start("missing", challenge.TypeDragDrop, 0, nil)
+	rec.Client("missing", &pb.ClientEvent{})
+	rec.Finish("missing", 100)
+	rec.Close()
+	if len(captured) != 0 {
+		t.Fatalf("captured %d sessions for a missing challenge", len(captured))
+	}
+
+	var nilRec *Recorder
+	nilRec.Start("missing", challenge.TypeDragDrop, 0, nil)
+	nilRec.Finish("missing", 100)
+	if err := nilRec.Close(); err != nil {
+		t.Fatalf("nil recorder Close: %v", err)
+	}
+}
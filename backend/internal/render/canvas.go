@@ -0,0 +1,176 @@
+// Package render рисует картинки заданий на сервере: фоны, шум, фигуры и искажения.
+// Всё рисуется в палитровое изображение с общей палитрой, поэтому PNG получается
+// компактным, а фоновые текстуры копируются из кэша без пересчёта цветов.
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Canvas — изображение задания в общей палитре
+type Canvas struct {
+	img *image.Paletted
+}
+
+// NewCanvas создаёт прозрачный холст w x h
+func NewCanvas(w, h int) *Canvas {
+	return &Canvas{img: image.NewPaletted(image.Rect(0, 0, w, h), Palette)}
+}
+
+// Image возвращает нарисованное изображение
+func (c *Canvas) Image() *image.Paletted {
+	return c.img
+}
+
+// Fill заливает холст одним цветом палитры
+func (c *Canvas) Fill(col uint8) {
+	for i := range c.img.Pix {
+		c.img.Pix[i] = col
+	}
+}
+
+// Texture копирует на холст текстуру того же размера
+func (c *Canvas) Texture(t *image.Paletted) {
+	copy(c.img.Pix, t.Pix)
+}
+
+// Noise накладывает шум уровня 0-100: случайные точки и линии поверх изображения.
+// Цвета шума берутся из тех же оттенков, что и фигуры, чтобы его нельзя было
+// отфильтровать по цвету.
+func (c *Canvas) Noise(level int) {
+	level = max(0, min(100, level))
+	b := c.img.Bounds()
+
+	dots := b.Dx() * b.Dy() * level / 1000
+	for i := 0; i < dots; i++ {
+		c.img.SetColorIndex(b.Min.X+rand.Intn(b.Dx()), b.Min.Y+rand.Intn(b.Dy()), randomColor(0, Levels-1))
+	}
+
+	for i := 0; i < level/10; i++ {
+		col := randomColor(1, 4)
+		c.Line(rand.Intn(b.Dx()), rand.Intn(b.Dy()), rand.Intn(b.Dx()), rand.Intn(b.Dy()), col)
+	}
+}
+
+// Line рисует отрезок по алгоритму Брезенхэма
+func (c *Canvas) Line(x0, y0, x1, y1 int, col uint8) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	err := dx + dy
+	for {
+		c.img.SetColorIndex(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// Distort сдвигает строки, а затем столбцы прямоугольника r по синусоиде с амплитудой amp,
+// чтобы фигуру нельзя было найти сравнением с эталоном
+func (c *Canvas) Distort(r image.Rectangle, amp float64) {
+	r = r.Intersect(c.img.Bounds())
+	if r.Empty() || amp <= 0 {
+		return
+	}
+	freq := 2 * math.Pi / float64(8+rand.Intn(16))
+	phase := rand.Float64() * 2 * math.Pi
+
+	row := make([]uint8, r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		shift := int(math.Round(amp * math.Sin(float64(y)*freq+phase)))
+		line := c.img.Pix[c.img.PixOffset(r.Min.X, y):c.img.PixOffset(r.Max.X, y)]
+		copy(row, line)
+		for x := range line {
+			line[x] = row[mod(x-shift, len(row))]
+		}
+	}
+
+	col := make([]uint8, r.Dy())
+	for x := r.Min.X; x < r.Max.X; x++ {
+		shift := int(math.Round(amp * math.Sin(float64(x)*freq+phase)))
+		for y := range col {
+			col[y] = c.img.ColorIndexAt(x, r.Min.Y+y)
+		}
+		for y := range col {
+			c.img.SetColorIndex(x, r.Min.Y+y, col[mod(y-shift, len(col))])
+		}
+	}
+}
+
+var (
+	encoder = png.Encoder{CompressionLevel: png.BestSpeed, BufferPool: &bufferPool{}}
+	buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+)
+
+// PNG кодирует холст в PNG
+func (c *Canvas) PNG() ([]byte, error) {
+	buf := buffers.Get().(*bytes.Buffer)
+	defer buffers.Put(buf)
+	buf.Reset()
+	if err := encoder.Encode(buf, c.img); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// DataURI кодирует холст в PNG и упаковывает в data: URI для встраивания в HTML
+func (c *Canvas) DataURI() (string, error) {
+	data, err := c.PNG()
+	if err != nil {
+		return "", err
+	}
+	return DataURI(data), nil
+}
+
+// DataURI упаковывает PNG в data: URI
+func DataURI(pngData []byte) string {
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData)
+}
+
+// bufferPool переиспользует внутренние буферы png.Encoder между запросами
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func (p *bufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *bufferPool) Put(b *png.EncoderBuffer) {
+	p.pool.Put(b)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Rect заливает прямоугольник
+func (c *Canvas) Rect(r image.Rectangle, col uint8) {
+	r = r.Intersect(c.img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		line := c.img.Pix[c.img.PixOffset(r.Min.X, y):c.img.PixOffset(r.Max.X, y)]
+		for x := range line {
+			line[x] = col
+		}
+	}
+}
+
+// Ellipse заливает эллипс, вписанный в прямоугольник r
+func (c *Canvas) Ellipse(r image.Rectangle, col uint8) {
+	rx, ry := float64(r.Dx())/2, float64(r.Dy())/2
+	cx, cy := float64(r.Min.X)+rx, float64(r.Min.Y)+ry
+	bounds := r.Intersect(c.img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dx, dy := (float64(x)+0.5-cx)/rx, (float64(y)+0.5-cy)/ry
+			if dx*dx+dy*dy <= 1 {
+				c.img.SetColorIndex(x, y, col)
+			}
+		}
+	}
+}
+
+// Polygon заливает многоугольник по правилу чётности пересечений
+func (c *Canvas) Polygon(pts []image.Point, col uint8) {
+	if len(pts) < 3 {
+		return
+	}
+	var bounds image.Rectangle
+	for i, p := range pts {
+		r := image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))}
+		if i == 0 {
+			bounds = r
+		} else {
+			bounds = bounds.Union(r)
+		}
+	}
+	bounds = bounds.Intersect(c.img.Bounds())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		py := float64(y) + 0.5
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px := float64(x) + 0.5
+			inside := false
+			for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+				xi, yi := float64(pts[i].X), float64(pts[i].Y)
+				xj, yj := float64(pts[j].X), float64(pts[j].Y)
+				if (yi > py) != (yj > py) && px < (xj-xi)*(py-yi)/(yj-yi)+xi {
+					inside = !inside
+				}
+			}
+			if inside {
+				c.img.SetColorIndex(x, y, col)
+			}
+		}
+	}
+}
+
+// ClipCircle делает прозрачным всё за пределами вписанного в холст круга
+func (c *Canvas) ClipCircle() {
+	b := c.img.Bounds()
+	cx, cy := float64(b.Min.X+b.Max.X)/2, float64(b.Min.Y+b.Max.Y)/2
+	r := float64(min(b.Dx(), b.Dy())) / 2
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) > r {
+				c.img.SetColorIndex(x, y, Transparent)
+			}
+		}
+	}
+}
+
+// Rotated возвращает копию холста, повёрнутую вокруг центра на angle радиан по часовой стрелке.
+// Точки, для которых нет источника, остаются прозрачными.
+func (c *Canvas) Rotated(angle float64) *Canvas {
+	b := c.img.Bounds()
+	out := NewCanvas(b.Dx(), b.Dy())
+	cx, cy := float64(b.Dx())/2, float64(b.Dy())/2
+	sin, cos := math.Sincos(-angle)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			sx := int(math.Floor(dx*cos - dy*sin + cx))
+			sy := int(math.Floor(dx*sin + dy*cos + cy))
+			if sx >= 0 && sy >= 0 && sx < b.Dx() && sy < b.Dy() {
+				out.img.Pix[out.img.PixOffset(x, y)] = c.img.Pix[c.img.PixOffset(b.Min.X+sx, b.Min.Y+sy)]
+			}
+		}
+	}
+	return out
+}
+
+// Draw копирует src на холст с левым верхним углом в at, пропуская прозрачные точки
+func (c *Canvas) Draw(src *Canvas, at image.Point) {
+	sb := src.img.Bounds()
+	r := sb.Sub(sb.Min).Add(at).Intersect(c.img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if col := src.img.ColorIndexAt(sb.Min.X+x-at.X, sb.Min.Y+y-at.Y); col != Transparent {
+				c.img.SetColorIndex(x, y, col)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// digits — растровый шрифт 5x7 для цифр 0-9, по строке на байт, старший из 5 бит слева
+var digits = [10][7]uint8{
+	{0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	{0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	{0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	{0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	{0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	{0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	{0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	{0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	{0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	{0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+}
+
+// Digit рисует цифру высотой size с центром в (cx, cy), повёрнутую на angle радиан.
+// Возвращает прямоугольник, который цифра может занимать при любом повороте.
+func (c *Canvas) Digit(d, cx, cy, size int, angle float64, col uint8) image.Rectangle {
+	glyph := digits[mod(d, 10)]
+	cell := float64(size) / 7
+	half := int(math.Ceil(cell * math.Hypot(2.5, 3.5))) // радиус описанной окружности знака
+	sin, cos := math.Sincos(-angle)
+
+	rect := image.Rect(cx-half, cy-half, cx+half, cy+half).Intersect(c.img.Bounds())
+	for py := rect.Min.Y; py < rect.Max.Y; py++ {
+		for px := rect.Min.X; px < rect.Max.X; px++ {
+			// Обратный поворот точки холста в координаты шрифта
+			dx, dy := float64(px)+0.5-float64(cx), float64(py)+0.5-float64(cy)
+			u := (dx*cos-dy*sin)/cell + 2.5
+			v := (dx*sin+dy*cos)/cell + 3.5
+			if u < 0 || v < 0 || u >= 5 || v >= 7 {
+				continue
+			}
+			if glyph[int(v)]&(0x10>>int(u)) != 0 {
+				c.img.SetColorIndex(px, py, col)
+			}
+		}
+	}
+	return rect
+}
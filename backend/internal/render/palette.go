@@ -0,0 +1,66 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// Палитра: индекс 0 прозрачный, дальше Hues оттенков по Levels уровней яркости.
+// Уровень 0 — самый тёмный и насыщенный, Levels-1 — самый светлый.
+const (
+	Hues   = 8
+	Levels = 8
+
+	Transparent uint8 = 0
+)
+
+// Palette — общая палитра всех картинок заданий
+var Palette = func() color.Palette {
+	p := color.Palette{color.RGBA{}}
+	for h := 0; h < Hues; h++ {
+		for l := 0; l < Levels; l++ {
+			p = append(p, hsl(float64(h)/Hues, 0.65, 0.25+float64(l)*0.09))
+		}
+	}
+	return p
+}()
+
+// Color возвращает индекс цвета палитры по оттенку и уровню яркости
+func Color(hue, level int) uint8 {
+	return uint8(1 + mod(hue, Hues)*Levels + max(0, min(Levels-1, level)))
+}
+
+// randomColor выбирает случайный оттенок с яркостью в пределах [minLevel, maxLevel]
+func randomColor(minLevel, maxLevel int) uint8 {
+	return Color(rand.Intn(Hues), minLevel+rand.Intn(maxLevel-minLevel+1))
+}
+
+// hsl переводит цвет из HSL (все компоненты 0-1) в RGBA
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h*6, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch int(h * 6) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
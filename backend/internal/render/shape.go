@@ -0,0 +1,60 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Shape — фигура, которую умеет рисовать Canvas
+type Shape int
+
+const (
+	Circle Shape = iota
+	Square
+	Diamond
+	Triangle
+	Star
+)
+
+// Shapes перечисляет все фигуры, чтобы генераторы могли выбирать из них случайно
+var Shapes = []Shape{Circle, Square, Diamond, Triangle, Star}
+
+// contains проверяет, попадает ли точка (dx, dy) относительно центра в фигуру радиуса r
+func (s Shape) contains(dx, dy, r float64) bool {
+	switch s {
+	case Square:
+		return math.Abs(dx) <= r*0.85 && math.Abs(dy) <= r*0.85
+	case Diamond:
+		return math.Abs(dx)+math.Abs(dy) <= r
+	case Triangle:
+		// равнобедренный треугольник вершиной вверх
+		return dy <= r*0.8 && dy >= -r && math.Abs(dx) <= (dy+r)*0.6
+	case Star:
+		angle := math.Atan2(dy, dx)
+		bound := r * (0.6 + 0.4*math.Cos(5*angle))
+		return math.Hypot(dx, dy) <= bound
+	default:
+		return math.Hypot(dx, dy) <= r
+	}
+}
+
+// Shape рисует фигуру, вписанную в квадрат size x size с левым верхним углом (x, y).
+// При outline > 0 рисуется только контур такой толщины.
+func (c *Canvas) Shape(s Shape, x, y, size int, col uint8, outline int) image.Rectangle {
+	r := float64(size) / 2
+	cx, cy := float64(x)+r, float64(y)+r
+	rect := image.Rect(x, y, x+size, y+size).Intersect(c.img.Bounds())
+	for py := rect.Min.Y; py < rect.Max.Y; py++ {
+		for px := rect.Min.X; px < rect.Max.X; px++ {
+			dx, dy := float64(px)+0.5-cx, float64(py)+0.5-cy
+			if !s.contains(dx, dy, r) {
+				continue
+			}
+			if outline > 0 && s.contains(dx, dy, r-float64(outline)) {
+				continue
+			}
+			c.img.SetColorIndex(px, py, col)
+		}
+	}
+	return rect
+}
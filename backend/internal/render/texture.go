@@ -0,0 +1,70 @@
+package render
+
+import (
+	"image"
+	"math/rand"
+	"sync"
+)
+
+// bayer — матрица упорядоченного дизеринга 4x4, даёт плавные переходы в палитре
+var bayer = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// TextureCache — набор заранее нарисованных фоновых текстур одного размера.
+// Рисовать фон на каждый запрос дорого, а копирование готовой текстуры почти
+// ничего не стоит; разнообразие дают шум и фигуры поверх неё.
+type TextureCache struct {
+	w, h, n  int
+	once     sync.Once
+	textures []*image.Paletted
+}
+
+// NewTextureCache описывает кэш из n текстур w x h; рисуются они при первом обращении
+func NewTextureCache(w, h, n int) *TextureCache {
+	return &TextureCache{w: w, h: h, n: n}
+}
+
+// Pick возвращает случайную текстуру из кэша. Текстуры общие, менять их нельзя.
+func (tc *TextureCache) Pick() *image.Paletted {
+	tc.once.Do(func() {
+		for i := 0; i < tc.n; i++ {
+			tc.textures = append(tc.textures, texture(tc.w, tc.h))
+		}
+	})
+	return tc.textures[rand.Intn(len(tc.textures))]
+}
+
+// NewTexture рисует новую текстуру w x h в обход кэша — для картинок, фон которых
+// не должен повторяться между заданиями
+func NewTexture(w, h int) *image.Paletted {
+	return texture(w, h)
+}
+
+// texture рисует светлый фон из шума значений: случайная решётка яркостей,
+// билинейно растянутая на всё изображение и переведённая в палитру дизерингом
+func texture(w, h int) *image.Paletted {
+	const cell = 40
+	gw, gh := w/cell+2, h/cell+2
+	grid := make([]float64, gw*gh)
+	for i := range grid {
+		grid[i] = float64(Levels-3) + rand.Float64()*2.99
+	}
+	hue := rand.Intn(Hues)
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), Palette)
+	for y := 0; y < h; y++ {
+		gy, fy := y/cell, float64(y%cell)/cell
+		for x := 0; x < w; x++ {
+			gx, fx := x/cell, float64(x%cell)/cell
+			top := grid[gy*gw+gx]*(1-fx) + grid[gy*gw+gx+1]*fx
+			bottom := grid[(gy+1)*gw+gx]*(1-fx) + grid[(gy+1)*gw+gx+1]*fx
+			v := top*(1-fy) + bottom*fy + bayer[y%4][x%4]/16 - 0.5
+			img.Pix[img.PixOffset(x, y)] = Color(hue, int(v+0.5))
+		}
+	}
+	return img
+}
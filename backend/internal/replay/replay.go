@@ -0,0 +1,199 @@
+// Package replay прогоняет записанные сессии через текущую версию GRPCCaptchaService
+// в их записанном времени. Ответы заданий и случайные решения сервиса берутся
+// из снимков записи, поэтому от записи итог может отличаться только проверкой.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+	"github.com/theborzet/captcha_service/internal/config"
+	captcha "github.com/theborzet/captcha_service/internal/grpc/capcha"
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/risk"
+	"github.com/theborzet/captcha_service/internal/scoring"
+	"github.com/theborzet/captcha_service/internal/security"
+	"github.com/theborzet/captcha_service/internal/tenant"
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Result — итог повтора одной сессии
+type Result struct {
+	Confidence int                // recording.NoResult, если итога не было
+	Session    *recording.Session // сессия, заново записанная при повторе, с разбором уверенности по этапам
+}
+
+// Replayer повторяет сессии одну за другой
+type Replayer struct {
+	tracker *risk.Tracker
+	scorer  *scoring.Engine
+	tenants *tenant.Registry
+	log     *slog.Logger
+}
+
+// New готовит повтор с оценкой этапов моделью из файла modelPath;
+// пустой modelPath — встроенная формула
+func New(modelPath string) (*Replayer, error) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	scorer, err := scoring.New(modelPath, log)
+	if err != nil {
+		return nil, err
+	}
+	// Профиль риска и сайты на итог не влияют, но сервису они нужны
+	tenants, err := tenant.NewRegistry(config.TenantConfig{Name: "default", PassTTL: 60}, nil, security.NewMemoryRedeemed())
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{
+		tracker: risk.NewTracker(config.RiskConfig{ProfileTTL: 900, BurstWindow: 60, BurstThreshold: 10, FastSolveMs: 800, MaxComplexity: 100}),
+		scorer:  scorer,
+		tenants: tenants,
+		log:     log,
+	}, nil
+}
+
+// Run прогоняет события сессии через новый сервис
+func (r *Replayer) Run(s *recording.Session) (Result, error) {
+	if len(s.Entries) == 0 || s.Entries[0].Kind != recording.KindSnapshot || s.Entries[0].Part != challenge.PartAll {
+		return Result{Confidence: recording.NoResult}, errors.New("session does not start with a challenge snapshot")
+	}
+	clock := &clock{now: s.Started}
+	store := challenge.NewStoreWithClock(clock.Now)
+	if err := store.Restore(s.ChallengeID, challenge.PartAll, s.Entries[0].Data); err != nil {
+		return Result{Confidence: recording.NoResult}, fmt.Errorf("failed to restore challenge: %w", err)
+	}
+
+	var replayed *recording.Session
+	recorder := recording.NewCapture(store, func(rs *recording.Session) { replayed = rs }, r.log)
+	recorder.Start(s.ChallengeID, s.Type, s.Complexity, s.Stages)
+	service := captcha.NewCaptchaService(store, nil, r.tracker, s.Type, nil, recorder, r.scorer, r.tenants, r.log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &stream{ctx: ctx, session: s, store: store, clock: clock, next: 1}
+	err := service.MakeEventStream(stream)
+	// Отложенные обновления заданий больше никто не ждёт
+	cancel()
+	recorder.Close()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Result{Confidence: recording.NoResult}, err
+	}
+	return Result{Confidence: stream.result(), Session: replayed}, nil
+}
+
+// clock — часы хранилища при повторе: идут по времени записей сессии
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// stream подаёт сервису события клиента из записи. Снимки между событиями
+// восстанавливаются перед следующим событием, как если бы сервис принял те же
+// случайные решения, что и при записи.
+type stream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	session *recording.Session
+	store   *challenge.ChallengeStore
+	clock   *clock
+	next    int
+
+	// Задание до последнего поданного события и что с тех пор было в записи:
+	// случайное изменение, которого при записи не случилось, откатывается
+	before  []byte
+	sentAt  int
+	changed bool
+
+	mu   sync.Mutex
+	sent []*pb.ServerEvent
+}
+
+func (st *stream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *stream) Send(event *pb.ServerEvent) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sent = append(st.sent, event)
+	return nil
+}
+
+func (st *stream) Recv() (*pb.ClientEvent, error) {
+	id := st.session.ChallengeID
+	for st.next < len(st.session.Entries) {
+		e := st.session.Entries[st.next]
+		st.next++
+		st.clock.set(e.At)
+		switch e.Kind {
+		case recording.KindSnapshot:
+			err := st.store.Restore(id, e.Part, e.Data)
+			// Новая версия могла завершить задание раньше записанной — восстанавливать уже нечего
+			if err != nil && !(errors.Is(err, challenge.ErrNotFound) && e.Part != challenge.PartAll) {
+				return nil, fmt.Errorf("failed to restore %s snapshot: %w", e.Part, err)
+			}
+			st.changed = st.changed || e.Part != challenge.PartProbes
+		case recording.KindClient:
+			if st.before != nil && !st.changed && st.mutated() {
+				if err := st.store.Restore(id, challenge.PartState, st.before); err != nil && !errors.Is(err, challenge.ErrNotFound) {
+					return nil, fmt.Errorf("failed to revert state: %w", err)
+				}
+			}
+			var event pb.ClientEvent
+			if err := proto.Unmarshal(e.Data, &event); err != nil {
+				return nil, fmt.Errorf("failed to decode client event: %w", err)
+			}
+			st.before, _ = st.store.Snapshot(id, challenge.PartState)
+			st.changed = false
+			st.mu.Lock()
+			st.sentAt = len(st.sent)
+			st.mu.Unlock()
+			return &event, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// mutated — прислал ли сервис изменение задания в ответ на последнее событие
+func (st *stream) mutated() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, event := range st.sent[st.sentAt:] {
+		if event.GetClientData() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// result — первый итог, отправленный сервисом при повторе
+func (st *stream) result() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, event := range st.sent {
+		if res := event.GetResult(); res != nil && !strings.HasPrefix(res.ChallengeId, "error:") {
+			return int(res.ConfidencePercent)
+		}
+	}
+	return recording.NoResult
+}
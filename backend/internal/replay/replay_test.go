@@ -0,0 +1,117 @@
+package replay
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+	captcha "github.com/theborzet/captcha_service/internal/grpc/capcha"
+	"github.com/theborzet/captcha_service/internal/harness"
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/solver"
+	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// record решает задание типа kind ботом через настоящий сервис и возвращает
+// записанную сессию
+func record(t *testing.T, r *Replayer, kind string) *recording.Session {
+	t.Helper()
+	store := challenge.NewStoreWithClock(time.Now)
+	var (
+		mu       sync.Mutex
+		sessions []*recording.Session
+	)
+	recorder := recording.NewCapture(store, func(s *recording.Session) {
+		mu.Lock()
+		defer mu.Unlock()
+		sessions = append(sessions, s)
+	}, r.log)
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterCaptchaServiceServer(server, captcha.NewCaptchaService(store, nil, r.tracker, kind, nil, recorder, r.scorer, r.tenants, r.log))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewCaptchaServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resp, err := client.NewChallenge(ctx, &pb.ChallengeRequest{Complexity: 10, ClientKey: "replay-test"})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	page, err := harness.Parse(resp.ChallengeId, resp.ChallengeType, resp.Html)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	bot := solver.Style{Forge: true, Informed: true}
+	s, err := harness.Stream(ctx, client, page)
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	s.ForgeProbes = bot.Forge
+	if _, err := solver.Solve(ctx, s, bot); err != nil {
+		t.Fatalf("Solve: %v", err)
+	}
+	s.Close()
+	recorder.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sessions) != 1 {
+		t.Fatalf("recorded %d sessions, want 1", len(sessions))
+	}
+	return sessions[0]
+}
+
+// Сессия, записанная сервисом, при повторе текущим кодом даёт тот же итог
+func TestReplayMatchesRecording(t *testing.T) {
+	for _, kind := range []string{challenge.TypePoW, challenge.TypeDragDrop, challenge.TypeRotate} {
+		t.Run(kind, func(t *testing.T) {
+			r, err := New("")
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			recorded := record(t, r, kind)
+			if recorded.Confidence == recording.NoResult {
+				t.Fatal("session finished without a result")
+			}
+			if kind == challenge.TypePoW && recorded.Confidence == 0 {
+				t.Fatal("solved proof of work recorded as failed")
+			}
+
+			res, err := r.Run(recorded)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if res.Confidence != recorded.Confidence {
+				t.Fatalf("replayed confidence %d, recorded %d", res.Confidence, recorded.Confidence)
+			}
+		})
+	}
+}
+
+func TestReplayRequiresSnapshot(t *testing.T) {
+	r, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := r.Run(&recording.Session{ChallengeID: "c1", Entries: []recording.Entry{{Kind: recording.KindClient}}})
+	if err == nil || res.Confidence != recording.NoResult {
+		t.Fatalf("got %d, %v; want an error without result", res.Confidence, err)
+	}
+}
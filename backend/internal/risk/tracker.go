@@ -0,0 +1,142 @@
+package risk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/config"
+)
+
+// PassThreshold — уверенность, начиная с которой задание считается пройденным
+const PassThreshold = 50
+
+// Tracker хранит короткоживущие профили риска по ключу клиента
+// (IP, сессия, хеш отпечатка браузера — как его собрал балансер)
+type Tracker struct {
+	mu       sync.Mutex
+	profiles map[string]*profile
+	cfg      config.RiskConfig
+}
+
+type profile struct {
+	failures   int
+	fastSolves int
+	requests   []time.Time // запросы заданий в пределах окна всплеска
+	lastSeen   time.Time
+}
+
+func NewTracker(cfg config.RiskConfig) *Tracker {
+	t := &Tracker{
+		profiles: make(map[string]*profile),
+		cfg:      cfg,
+	}
+	go t.cleanup()
+	return t
+}
+
+// ClientKey собирает ключ клиента из доступных признаков; пустые части пропускаются
+func ClientKey(parts ...string) string {
+	nonEmpty := parts[:0:0]
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(nonEmpty, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Adjust учитывает новый запрос задания и возвращает эффективные complexity и тип.
+// Complexity (0-100, как у всех генераторов) поднимается от запрошенной к
+// MaxComplexity пропорционально риску, а при риске выше EscalateScore задание
+// меняется на EscalateType. Третьим значением риск возвращается для логов.
+func (t *Tracker) Adjust(key string, complexity int, kind string) (int, string, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.profile(key)
+	now := time.Now()
+	window := now.Add(-time.Duration(t.cfg.BurstWindow) * time.Second)
+	recent := p.requests[:0]
+	for _, at := range p.requests {
+		if at.After(window) {
+			recent = append(recent, at)
+		}
+	}
+	p.requests = append(recent, now)
+
+	score := t.score(p)
+	if complexity < t.cfg.MaxComplexity {
+		complexity += score * (t.cfg.MaxComplexity - complexity) / 100
+	}
+	if t.cfg.EscalateType != "" && score >= t.cfg.EscalateScore {
+		kind = t.cfg.EscalateType
+	}
+	return complexity, kind, score
+}
+
+// Chain возвращает этапы, которые добавляются к заданию клиента с таким риском
+func (t *Tracker) Chain(score int) []string {
+	if score < t.cfg.EscalateScore {
+		return nil
+	}
+	return t.cfg.EscalateChain
+}
+
+// RecordResult запоминает исход задания: провалы и слишком быстрые решения повышают риск,
+// обычное успешное прохождение постепенно его снимает
+func (t *Tracker) RecordResult(key string, confidence int, solveTime time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.profile(key)
+	switch {
+	case confidence < PassThreshold:
+		p.failures++
+	case solveTime < time.Duration(t.cfg.FastSolveMs)*time.Millisecond:
+		p.fastSolves++
+	case p.failures > 0:
+		p.failures--
+	}
+}
+
+// score сводит профиль к риску 0-100
+func (t *Tracker) score(p *profile) int {
+	burst := max(0, len(p.requests)-t.cfg.BurstThreshold)
+	return min(100, p.failures*20+p.fastSolves*30+burst*10)
+}
+
+// profile возвращает живой профиль клиента, создавая новый при необходимости. Вызывается под mu.
+func (t *Tracker) profile(key string) *profile {
+	p, ok := t.profiles[key]
+	if !ok || time.Since(p.lastSeen) > t.ttl() {
+		p = &profile{}
+		t.profiles[key] = p
+	}
+	p.lastSeen = time.Now()
+	return p
+}
+
+func (t *Tracker) ttl() time.Duration {
+	return time.Duration(t.cfg.ProfileTTL) * time.Second
+}
+
+func (t *Tracker) cleanup() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.mu.Lock()
+		for key, p := range t.profiles {
+			if time.Since(p.lastSeen) > t.ttl() {
+				delete(t.profiles, key)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
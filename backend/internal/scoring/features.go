@@ -0,0 +1,129 @@
+package scoring
+
+import (
+	"math"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+)
+
+// Input — всё, что известно о завершённом этапе к моменту ответа
+type Input struct {
+	Type       string
+	Complexity int
+	Raw        int             // уверенность генератора по самому ответу
+	ProbeScore int             // оценка проверок окружения 0-100
+	Probes     map[string]bool // результаты отдельных проверок, nil — ответа не было
+	Timing     challenge.Timing
+	Trail      []challenge.Mark
+}
+
+// Features — именованные признаки этапа. Признак, который на этапе не определён
+// (например, траектория задания без координат), в наборе отсутствует.
+type Features map[string]float64
+
+// extractor дописывает в f свои признаки этапа
+type extractor func(in Input, f Features)
+
+var extractors = []extractor{
+	answerFeatures,
+	timingFeatures,
+	probeFeatures,
+	environmentFeatures,
+	trajectoryFeatures,
+}
+
+// Extract собирает признаки этапа всеми извлекателями
+func Extract(in Input) Features {
+	f := make(Features)
+	for _, extract := range extractors {
+		extract(in, f)
+	}
+	return f
+}
+
+func answerFeatures(in Input, f Features) {
+	f["raw"] = float64(in.Raw)
+	f["complexity"] = float64(in.Complexity)
+}
+
+func timingFeatures(in Input, f Features) {
+	f["elapsed_ms"] = ms(in.Timing.Elapsed)
+	if in.Timing.Reaction > 0 {
+		f["reaction_ms"] = ms(in.Timing.Reaction)
+	}
+	f["timing_factor"] = challenge.TimingFactor(in.Type, in.Complexity, in.Timing)
+}
+
+func probeFeatures(in Input, f Features) {
+	f["probe_score"] = float64(in.ProbeScore)
+	f["probes_answered"] = flag(in.Probes != nil)
+}
+
+// environmentFeatures — каждая проверка окружения отдельным признаком:
+// probe_<имя> равен 1, если ответ похож на обычный браузер
+func environmentFeatures(in Input, f Features) {
+	for name, passed := range in.Probes {
+		f["probe_"+name] = flag(passed)
+	}
+}
+
+// trajectoryFeatures разбирает действия пользователя: число и ритм событий,
+// а по событиям с координатами — длину и прямоту пути
+func trajectoryFeatures(in Input, f Features) {
+	f["events"] = float64(len(in.Trail))
+	if len(in.Trail) >= 3 {
+		intervals := make([]float64, 0, len(in.Trail)-1)
+		for i := 1; i < len(in.Trail); i++ {
+			intervals = append(intervals, ms(in.Trail[i].At.Sub(in.Trail[i-1].At)))
+		}
+		mean, sd := meanStd(intervals)
+		f["interval_mean_ms"] = mean
+		if mean > 0 {
+			f["interval_cv"] = sd / mean
+		}
+	}
+
+	// Точкой считается событие с ненулевыми координатами
+	var points []challenge.Mark
+	for _, m := range in.Trail {
+		if m.X != 0 || m.Y != 0 {
+			points = append(points, m)
+		}
+	}
+	if len(points) < 2 {
+		return
+	}
+	var path float64
+	for i := 1; i < len(points); i++ {
+		path += math.Hypot(float64(points[i].X-points[i-1].X), float64(points[i].Y-points[i-1].Y))
+	}
+	f["path_px"] = path
+	if path > 0 {
+		first, last := points[0], points[len(points)-1]
+		f["straightness"] = math.Hypot(float64(last.X-first.X), float64(last.Y-first.Y)) / path
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func flag(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func meanStd(values []float64) (float64, float64) {
+	var sum, sq float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
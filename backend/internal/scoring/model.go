@@ -0,0 +1,195 @@
+package scoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+)
+
+// Model превращает признаки этапа в уверенность 0-100 и раскладывает итог
+// по вкладам признаков. Вклад "bias" — часть итога, не зависящая от признаков.
+type Model interface {
+	Name() string
+	Predict(in Input, f Features) (int, map[string]float64)
+}
+
+// Formula — встроенная оценка: уверенность генератора по ответу, взвешенная
+// проверками окружения и временем решения. Вклады — в пунктах уверенности.
+type Formula struct{}
+
+func (Formula) Name() string { return "formula" }
+
+func (Formula) Predict(in Input, _ Features) (int, map[string]float64) {
+	weighed := challenge.WeighByProbes(in.Raw, in.ProbeScore)
+	confidence := int(float64(weighed) * challenge.TimingFactor(in.Type, in.Complexity, in.Timing))
+	return confidence, map[string]float64{
+		"raw":           float64(in.Raw),
+		"probe_score":   float64(weighed - in.Raw),
+		"timing_factor": float64(confidence - weighed),
+	}
+}
+
+// Файл модели — JSON одного из видов:
+//
+//	{"name": "lr-1", "kind": "logistic", "bias": -2.1,
+//	 "weights": {"raw": 0.04, "probe_score": 0.02},
+//	 "scale": {"elapsed_ms": {"mean": 4200, "std": 1900}}}
+//
+//	{"name": "gbt-1", "kind": "trees", "bias": -0.3, "trees": [[
+//	  {"feature": "raw", "threshold": 60, "left": 1, "right": 2, "value": 0.1},
+//	  {"value": -1.2},
+//	  {"value": 0.9}]]}
+//
+// Обе модели считают отступ в логитах, уверенность — 100·sigmoid(отступ).
+// Вклады признаков тоже в логитах.
+type modelFile struct {
+	Name    string             `json:"name"`
+	Kind    string             `json:"kind"`
+	Bias    float64            `json:"bias"`
+	Weights map[string]float64 `json:"weights"`
+	Scale   map[string]scaling `json:"scale"`
+	Trees   [][]node           `json:"trees"`
+}
+
+// Load читает модель из JSON-файла; имя по умолчанию — имя файла
+func Load(path string) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mf modelFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&mf); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	if mf.Name == "" {
+		mf.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	switch mf.Kind {
+	case "logistic":
+		return newLogistic(mf)
+	case "trees":
+		return newTrees(mf)
+	}
+	return nil, fmt.Errorf("unknown model kind %q", mf.Kind)
+}
+
+// scaling — стандартизация признака перед логистической регрессией
+type scaling struct {
+	Mean float64 `json:"mean"`
+	Std  float64 `json:"std"`
+}
+
+// logistic — логистическая регрессия; отсутствующий признак ничего не вносит
+type logistic struct {
+	name    string
+	bias    float64
+	weights map[string]float64
+	scale   map[string]scaling
+}
+
+func newLogistic(mf modelFile) (*logistic, error) {
+	if len(mf.Weights) == 0 {
+		return nil, errors.New("logistic model has no weights")
+	}
+	for name, s := range mf.Scale {
+		if s.Std <= 0 {
+			return nil, fmt.Errorf("feature %q: std must be > 0", name)
+		}
+	}
+	return &logistic{name: mf.Name, bias: mf.Bias, weights: mf.Weights, scale: mf.Scale}, nil
+}
+
+func (m *logistic) Name() string { return m.name }
+
+func (m *logistic) Predict(_ Input, f Features) (int, map[string]float64) {
+	contributions := map[string]float64{"bias": m.bias}
+	margin := m.bias
+	for name, w := range m.weights {
+		x, ok := f[name]
+		if !ok {
+			continue
+		}
+		if s, ok := m.scale[name]; ok {
+			x = (x - s.Mean) / s.Std
+		}
+		contributions[name] = w * x
+		margin += w * x
+	}
+	return confidence(margin), contributions
+}
+
+// node — узел дерева решений. Лист — узел без признака, его Value входит в отступ.
+// У развилки Value — среднее по её листьям, из разницы с ним считаются вклады.
+type node struct {
+	Feature     string  `json:"feature"`
+	Threshold   float64 `json:"threshold"`
+	Left        int     `json:"left"`         // признак < threshold
+	Right       int     `json:"right"`        // признак >= threshold
+	DefaultLeft bool    `json:"default_left"` // куда идти, если признака нет
+	Value       float64 `json:"value"`
+}
+
+// trees — ансамбль деревьев градиентного бустинга, корень каждого дерева — узел 0
+type trees struct {
+	name  string
+	bias  float64
+	trees [][]node
+}
+
+func newTrees(mf modelFile) (*trees, error) {
+	if len(mf.Trees) == 0 {
+		return nil, errors.New("trees model has no trees")
+	}
+	for t, nodes := range mf.Trees {
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("tree %d is empty", t)
+		}
+		// Дети всегда дальше родителя, поэтому обход дерева конечен
+		for i, n := range nodes {
+			if n.Feature == "" {
+				continue
+			}
+			if n.Left <= i || n.Right <= i || n.Left >= len(nodes) || n.Right >= len(nodes) {
+				return nil, fmt.Errorf("tree %d, node %d: children must follow the node", t, i)
+			}
+		}
+	}
+	return &trees{name: mf.Name, bias: mf.Bias, trees: mf.Trees}, nil
+}
+
+func (m *trees) Name() string { return m.name }
+
+// Predict раскладывает отступ по признакам развилок на пути к листу (метод Саабаса)
+func (m *trees) Predict(_ Input, f Features) (int, map[string]float64) {
+	contributions := map[string]float64{"bias": m.bias}
+	margin := m.bias
+	for _, nodes := range m.trees {
+		n := nodes[0]
+		contributions["bias"] += n.Value
+		for n.Feature != "" {
+			x, ok := f[n.Feature]
+			next := n.Right
+			if (ok && x < n.Threshold) || (!ok && n.DefaultLeft) {
+				next = n.Left
+			}
+			child := nodes[next]
+			contributions[n.Feature] += child.Value - n.Value
+			n = child
+		}
+		margin += n.Value
+	}
+	return confidence(margin), contributions
+}
+
+func confidence(margin float64) int {
+	return int(math.Round(100 / (1 + math.Exp(-margin))))
+}
@@ -0,0 +1,167 @@
+// Package scoring оценивает завершённый этап задания. Извлекатели признаков
+// разбирают ответ, время решения, проверки окружения и траекторию действий
+// пользователя, а модель превращает признаки в уверенность 0-100.
+//
+// Без файла модели работает встроенная формула (Formula). Модель из файла
+// читается при старте и подменяется на лету, когда файл меняется, поэтому
+// новую модель можно выкатить без перезапуска инстанса.
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+)
+
+// Engine оценивает этапы текущей моделью
+type Engine struct {
+	path  string // пусто — модель не подменяется
+	log   *slog.Logger
+	model atomic.Pointer[loadedModel]
+
+	// Состояние проверок файла, его меняет только Run
+	missing  bool      // файла модели нет, об этом уже сказано в логе
+	failedAt time.Time // время изменения файла, который не удалось прочитать
+}
+
+type loadedModel struct {
+	Model
+	modTime time.Time
+}
+
+// New загружает модель из path; пустой path — встроенная формула
+func New(path string, log *slog.Logger) (*Engine, error) {
+	e := &Engine{path: path, log: log}
+	if path == "" {
+		e.model.Store(&loadedModel{Model: Formula{}})
+		return e, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scoring model: %w", err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scoring model: %w", err)
+	}
+	e.model.Store(&loadedModel{Model: m, modTime: info.ModTime()})
+	return e, nil
+}
+
+// Model — имя текущей модели
+func (e *Engine) Model() string {
+	return e.model.Load().Name()
+}
+
+// Judge оценивает завершённый этап живого задания текущей моделью
+func (e *Engine) Judge(store *challenge.ChallengeStore, challengeID string, raw int, answeredAt time.Time) challenge.Verdict {
+	in := Input{
+		Complexity: store.GetComplexity(challengeID),
+		Raw:        raw,
+		ProbeScore: store.ProbeScore(challengeID),
+		Probes:     store.ProbeResults(challengeID),
+		Trail:      store.Trail(challengeID),
+	}
+	in.Type, _ = store.Kind(challengeID)
+	in.Timing, _ = store.Timing(challengeID, answeredAt)
+
+	m := e.model.Load()
+	features := Extract(in)
+	conf, contributions := m.Predict(in, features)
+	conf = max(0, min(100, conf))
+
+	if e.log.Enabled(context.Background(), slog.LevelDebug) {
+		e.log.Debug("Stage scored",
+			slog.String("challenge_id", challengeID),
+			slog.String("model", m.Name()),
+			slog.Int("confidence", conf),
+			slog.Any("features", features),
+			slog.Any("contributions", byWeight(contributions)))
+	}
+
+	return challenge.Verdict{
+		Type:       in.Type,
+		Complexity: in.Complexity,
+		Raw:        raw,
+		ProbeScore: in.ProbeScore,
+		Timing:     in.Timing,
+		Confidence: conf,
+		Model:      m.Name(),
+		Features:   features,
+	}
+}
+
+// contribution — вклад признака в итог модели
+type contribution struct {
+	Feature string  `json:"feature"`
+	Value   float64 `json:"value"`
+}
+
+// byWeight — вклады признаков для лога, самые весомые первыми
+func byWeight(contributions map[string]float64) []contribution {
+	list := make([]contribution, 0, len(contributions))
+	for name, v := range contributions {
+		list = append(list, contribution{Feature: name, Value: v})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := math.Abs(list[i].Value), math.Abs(list[j].Value)
+		if a != b {
+			return a > b
+		}
+		return list[i].Feature < list[j].Feature
+	})
+	return list
+}
+
+// Run раз в interval проверяет файл модели и подменяет модель, если файл
+// изменился. Модель из испорченного файла не подменяет текущую.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if e.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.reload()
+		}
+	}
+}
+
+func (e *Engine) reload() {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		if !e.missing {
+			e.missing = true
+			e.log.Error("Scoring model file unavailable", slog.String("path", e.path), slog.Any("error", err))
+		}
+		return
+	}
+	e.missing = false
+	modTime := info.ModTime()
+	if modTime.Equal(e.model.Load().modTime) || modTime.Equal(e.failedAt) {
+		return
+	}
+	m, err := Load(e.path)
+	if err != nil {
+		e.failedAt = modTime
+		e.log.Error("Failed to reload scoring model, keeping the current one",
+			slog.String("path", e.path),
+			slog.String("model", e.Model()),
+			slog.Any("error", err))
+		return
+	}
+	old := e.model.Swap(&loadedModel{Model: m, modTime: modTime})
+	e.failedAt = time.Time{}
+	e.log.Info("Scoring model reloaded", slog.String("previous", old.Name()), slog.String("model", m.Name()))
+}
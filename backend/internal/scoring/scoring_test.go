@@ -0,0 +1,206 @@
+package scoring
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+)
+
+const (
+	logisticModel = `{"name": "lr-1", "kind": "logistic", "bias": 0, "weights": {"raw": 1}, "scale": {"raw": {"mean": 50, "std": 10}}}`
+	treesModel    = `{"name": "gbt-1", "kind": "trees", "trees": [[
+		{"feature": "raw", "threshold": 60, "left": 1, "right": 2, "default_left": true},
+		{"value": -2},
+		{"value": 2}]]}`
+)
+
+func writeModel(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		body string
+		want string // имя модели
+		err  string // подстрока ошибки, пусто — модель читается
+	}{
+		{name: "logistic", body: logisticModel, want: "lr-1"},
+		{name: "trees", body: treesModel, want: "gbt-1"},
+		{name: "name from file", file: "fallback.json", body: `{"kind": "logistic", "weights": {"raw": 1}}`, want: "fallback"},
+		{name: "unknown kind", body: `{"kind": "forest"}`, err: `unknown model kind "forest"`},
+		{name: "unknown field", body: `{"kind": "logistic", "weights": {"raw": 1}, "extra": 1}`, err: "unknown field"},
+		{name: "no weights", body: `{"kind": "logistic"}`, err: "no weights"},
+		{name: "zero std", body: `{"kind": "logistic", "weights": {"raw": 1}, "scale": {"raw": {"mean": 1}}}`, err: "std must be > 0"},
+		{name: "no trees", body: `{"kind": "trees"}`, err: "no trees"},
+		{name: "empty tree", body: `{"kind": "trees", "trees": [[]]}`, err: "tree 0 is empty"},
+		{name: "child before parent", body: `{"kind": "trees", "trees": [[{"value": 1}, {"feature": "raw", "left": 0, "right": 2}, {"value": 0}]]}`, err: "children must follow"},
+		{name: "broken json", body: `{"kind": `, err: "failed to parse model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.file
+			if file == "" {
+				file = "model.json"
+			}
+			path := filepath.Join(t.TempDir(), file)
+			writeModel(t, path, tt.body)
+			m, err := Load(path)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("expected error containing %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("error %q does not contain %q", err, tt.err)
+			case tt.err == "" && m.Name() != tt.want:
+				t.Fatalf("got model %q, want %q", m.Name(), tt.want)
+			}
+		})
+	}
+}
+
+// Модели из файла считают уверенность как 100·sigmoid(отступ), а вклады
+// признаков вместе с bias складываются в отступ
+func TestModelPredict(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		features Features
+		want     int
+	}{
+		{name: "logistic above mean", body: logisticModel, features: Features{"raw": 70}, want: 88},
+		{name: "logistic below mean", body: logisticModel, features: Features{"raw": 30}, want: 12},
+		{name: "logistic missing feature", body: logisticModel, features: Features{}, want: 50},
+		{name: "trees right branch", body: treesModel, features: Features{"raw": 80}, want: 88},
+		{name: "trees left branch", body: treesModel, features: Features{"raw": 30}, want: 12},
+		{name: "trees missing feature", body: treesModel, features: Features{}, want: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "model.json")
+			writeModel(t, path, tt.body)
+			m, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, contributions := m.Predict(Input{}, tt.features)
+			if got != tt.want {
+				t.Fatalf("got confidence %d, want %d", got, tt.want)
+			}
+			margin := 0.0
+			for _, v := range contributions {
+				margin += v
+			}
+			if c := confidence(margin); c != got {
+				t.Fatalf("contributions add up to confidence %d, want %d", c, got)
+			}
+		})
+	}
+}
+
+// Вклады встроенной формулы — в пунктах уверенности и складываются в итог
+func TestFormulaContributions(t *testing.T) {
+	for _, in := range []Input{
+		{Type: challenge.TypeDragDrop, Raw: 100, ProbeScore: 100, Timing: challenge.Timing{Elapsed: 3 * time.Second}},
+		{Type: challenge.TypeDragDrop, Raw: 80, ProbeScore: 0, Timing: challenge.Timing{Elapsed: 50 * time.Millisecond}},
+	} {
+		got, contributions := Formula{}.Predict(in, Extract(in))
+		sum := 0.0
+		for _, v := range contributions {
+			sum += v
+		}
+		if int(math.Round(sum)) != got {
+			t.Fatalf("%+v: contributions add up to %v, confidence %d", in, sum, got)
+		}
+	}
+}
+
+// Изменённый файл модели подменяет текущую модель, а испорченный
+// или пропавший файл оставляет прежнюю
+func TestEngineReload(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if e, err := New("", log); err != nil || e.Model() != "formula" {
+		t.Fatalf("without model file: got %v, %v", e, err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.json")
+	if _, err := New(path, log); err == nil {
+		t.Fatal("missing model file accepted")
+	}
+
+	writeModel(t, path, logisticModel)
+	e, err := New(path, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Model() != "lr-1" {
+		t.Fatalf("got model %q, want lr-1", e.Model())
+	}
+
+	// Время изменения ставится явно: запись в пределах одного тика часов ФС его не меняет
+	touch := func(age time.Duration) {
+		t.Helper()
+		at := time.Now().Add(-age)
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	steps := []struct {
+		name string
+		body string // пусто — файл удаляется
+		want string
+	}{
+		{name: "new model", body: treesModel, want: "gbt-1"},
+		{name: "broken file", body: `{"kind": `, want: "gbt-1"},
+		{name: "removed file", want: "gbt-1"},
+		{name: "fixed file", body: logisticModel, want: "lr-1"},
+	}
+	for i, s := range steps {
+		if s.body == "" {
+			os.Remove(path)
+		} else {
+			writeModel(t, path, s.body)
+			touch(time.Duration(len(steps)-i) * time.Minute)
+		}
+		e.reload()
+		if e.Model() != s.want {
+			t.Fatalf("%s: got model %q, want %q", s.name, e.Model(), s.want)
+		}
+	}
+}
+
+// Judge оценивает живое задание текущей моделью и сохраняет признаки в вердикте
+func TestEngineJudge(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := challenge.NewStoreWithClock(func() time.Time { return now })
+	task, err := challenge.NewChain(store, nil, []string{challenge.TypeDragDrop}, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	writeModel(t, path, logisticModel)
+	e, err := New(path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := e.Judge(store, task.ID, 70, now.Add(3*time.Second))
+	if v.Type != challenge.TypeDragDrop || v.Complexity != 40 || v.Raw != 70 || v.Model != "lr-1" {
+		t.Fatalf("got verdict %+v", v)
+	}
+	if v.Confidence != 88 || v.Features["raw"] != 70 {
+		t.Fatalf("got confidence %d with raw feature %v, want 88 and 70", v.Confidence, v.Features["raw"])
+	}
+}
@@ -0,0 +1,61 @@
+package security
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginPolicy решает, каким сайтам разрешено встраивать капчу.
+// Записи списка — точные origin ("https://shop.example.com") или шаблоны
+// поддоменов ("https://*.example.com"). Пустой список разрешает только свой origin.
+type OriginPolicy struct {
+	allowed []string
+}
+
+func NewOriginPolicy(allowed []string) *OriginPolicy {
+	return &OriginPolicy{allowed: allowed}
+}
+
+// RequestOrigin возвращает origin запроса: заголовок Origin, а если браузер
+// его не прислал (запрос со своей страницы) — origin самого сервиса
+func RequestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// Allowed проверяет origin запроса по списку
+func (p *OriginPolicy) Allowed(r *http.Request) bool {
+	origin := RequestOrigin(r)
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if len(p.allowed) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range p.allowed {
+		if matchOrigin(allowed, u) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOrigin(pattern string, origin *url.URL) bool {
+	scheme, host, ok := strings.Cut(pattern, "://")
+	if !ok || !strings.EqualFold(scheme, origin.Scheme) {
+		return false
+	}
+	if suffix, wildcard := strings.CutPrefix(host, "*."); wildcard {
+		return strings.HasSuffix(strings.ToLower(origin.Host), "."+strings.ToLower(suffix))
+	}
+	return strings.EqualFold(host, origin.Host)
+}
@@ -0,0 +1,128 @@
+package security
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPass  = errors.New("invalid pass token")
+	ErrExpiredPass  = errors.New("pass token expired")
+	ErrRedeemedPass = errors.New("pass token already redeemed")
+	ErrPassSite     = errors.New("pass token issued for another site key")
+	ErrPassHostname = errors.New("pass token issued for another hostname")
+	ErrPassAction   = errors.New("pass token issued for another action")
+	ErrPassStore    = errors.New("redeemed pass store unavailable")
+)
+
+// PassClaims — что подтверждает пропуск
+type PassClaims struct {
+	ID          string `json:"id"` // случайный, по нему пропуск гасится
+	ChallengeID string `json:"cid"`
+	SiteKey     string `json:"site,omitempty"`
+	Hostname    string `json:"host"`
+	Action      string `json:"act,omitempty"`
+	Score       int    `json:"score"`
+	IssuedAt    int64  `json:"iat"` // unix-время выдачи
+	Expiry      int64  `json:"exp"` // unix-время, после которого пропуск недействителен
+}
+
+// PassIssuer выдаёт подписанные пропуски за пройденную капчу и гасит их. Пропуск
+// уходит клиенту вместе с итогом, а целевой сервис сам проверяет его через
+// VerifyToken или /siteverify: подпись, срок, сайт и действие, для которых
+// капча проходилась, и то, что пропуск предъявлен впервые.
+//
+// Пропуск, выданный одним инстансом, принимает любой инстанс с тем же ключом
+// подписи, а погашенные пропуски они отмечают в общем хранилище redeemed.
+type PassIssuer struct {
+	key      []byte
+	ttl      time.Duration
+	redeemed Redeemed
+}
+
+// NewPassIssuer создаёт издателя пропусков. Без секрета ключ генерируется случайно,
+// и пропуски принимает только этот процесс.
+func NewPassIssuer(secret string, ttl time.Duration, redeemed Redeemed) *PassIssuer {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &PassIssuer{key: key, ttl: ttl, redeemed: redeemed}
+}
+
+// Issue выдаёт пропуск вида base64(JSON PassClaims).base64(hmac)
+func (p *PassIssuer) Issue(challengeID, siteKey, hostname, action string, score int) string {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	claims, _ := json.Marshal(PassClaims{
+		ID:          hex.EncodeToString(id),
+		ChallengeID: challengeID,
+		SiteKey:     siteKey,
+		Hostname:    hostname,
+		Action:      action,
+		Score:       score,
+		IssuedAt:    now.Unix(),
+		Expiry:      now.Add(p.ttl).Unix(),
+	})
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + p.sign(payload)
+}
+
+// Redeem проверяет подпись, срок и ключ сайта пропуска и гасит его. Пустые
+// hostname и action не проверяются. Пропуск гасится и тогда, когда hostname
+// или действие не совпали, чтобы его нельзя было подобрать повторными попытками.
+// Недоступное хранилище погашенных пропусков — ErrPassStore: это сбой проверки, а не отказ.
+func (p *PassIssuer) Redeem(ctx context.Context, token, siteKey, hostname, action string) (PassClaims, error) {
+	var claims PassClaims
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(p.sign(payload))) {
+		return claims, ErrInvalidPass
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(raw, &claims) != nil || claims.ID == "" {
+		return PassClaims{}, ErrInvalidPass
+	}
+
+	// Пропуск чужого сайта с тем же ключом подписи не гасится: его владелец ещё может его предъявить
+	if claims.SiteKey != siteKey {
+		return claims, ErrPassSite
+	}
+	if time.Now().Unix() > claims.Expiry {
+		return claims, ErrExpiredPass
+	}
+	fresh, err := p.redeemed.Mark(ctx, claims.ID, time.Unix(claims.Expiry, 0))
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrPassStore, err)
+	}
+	if !fresh {
+		return claims, ErrRedeemedPass
+	}
+	if hostname != "" && !strings.EqualFold(hostname, claims.Hostname) {
+		return claims, ErrPassHostname
+	}
+	if action != "" && action != claims.Action {
+		return claims, ErrPassAction
+	}
+	return claims, nil
+}
+
+func (p *PassIssuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,121 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Пропуск проходит один раз и только с верной подписью, в срок, для своего
+// сайта, hostname и действия
+func TestPassRedeem(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		token    func(token string) string // портит выданный пропуск, nil — как есть
+		siteKey  string
+		hostname string
+		action   string
+		err      error
+	}{
+		{name: "valid", siteKey: "site", hostname: "shop.example.com", action: "login"},
+		{name: "hostname and action not checked", siteKey: "site"},
+		{name: "hostname case", siteKey: "site", hostname: "Shop.Example.COM"},
+		{name: "bad signature", token: func(s string) string { return s[:len(s)-2] + "xx" }, siteKey: "site", err: ErrInvalidPass},
+		{name: "foreign key", token: func(string) string {
+			return NewPassIssuer("other", time.Minute, NewMemoryRedeemed()).Issue("c1", "site", "shop.example.com", "login", 90)
+		}, siteKey: "site", err: ErrInvalidPass},
+		{name: "no signature", token: func(s string) string { payload, _, _ := strings.Cut(s, "."); return payload }, siteKey: "site", err: ErrInvalidPass},
+		{name: "expired", ttl: -2 * time.Second, siteKey: "site", err: ErrExpiredPass},
+		{name: "another site", siteKey: "other-site", err: ErrPassSite},
+		{name: "hostname mismatch", siteKey: "site", hostname: "evil.example.com", err: ErrPassHostname},
+		{name: "action mismatch", siteKey: "site", action: "signup", err: ErrPassAction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl := tt.ttl
+			if ttl == 0 {
+				ttl = time.Minute
+			}
+			p := NewPassIssuer("secret", ttl, NewMemoryRedeemed())
+			token := p.Issue("c1", "site", "shop.example.com", "login", 90)
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+			claims, err := p.Redeem(context.Background(), token, tt.siteKey, tt.hostname, tt.action)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && (claims.ChallengeID != "c1" || claims.Score != 90 || claims.Action != "login") {
+				t.Fatalf("got claims %+v", claims)
+			}
+		})
+	}
+}
+
+// Пропуск гасится при первой проверке, даже неудачной по hostname или действию,
+// а пропуск чужого сайта остаётся его владельцу
+func TestPassReuse(t *testing.T) {
+	ctx := context.Background()
+	p := NewPassIssuer("secret", time.Minute, NewMemoryRedeemed())
+
+	token := p.Issue("c1", "site", "shop.example.com", "login", 90)
+	if _, err := p.Redeem(ctx, token, "site", "", ""); err != nil {
+		t.Fatalf("first redeem: %v", err)
+	}
+	if _, err := p.Redeem(ctx, token, "site", "", ""); !errors.Is(err, ErrRedeemedPass) {
+		t.Fatalf("second redeem: got %v, want ErrRedeemedPass", err)
+	}
+
+	token = p.Issue("c2", "site", "shop.example.com", "login", 90)
+	if _, err := p.Redeem(ctx, token, "site", "evil.example.com", ""); !errors.Is(err, ErrPassHostname) {
+		t.Fatalf("got %v, want ErrPassHostname", err)
+	}
+	if _, err := p.Redeem(ctx, token, "site", "shop.example.com", ""); !errors.Is(err, ErrRedeemedPass) {
+		t.Fatalf("retry after hostname mismatch: got %v, want ErrRedeemedPass", err)
+	}
+
+	token = p.Issue("c3", "site", "shop.example.com", "login", 90)
+	if _, err := p.Redeem(ctx, token, "other-site", "", ""); !errors.Is(err, ErrPassSite) {
+		t.Fatalf("got %v, want ErrPassSite", err)
+	}
+	if _, err := p.Redeem(ctx, token, "site", "", ""); err != nil {
+		t.Fatalf("redeem by owner after foreign attempt: %v", err)
+	}
+}
+
+// Инстансы с общим ключом и общим Redis принимают пропуски друг друга,
+// но погасить пропуск можно только один раз на всех
+func TestPassSharedRedis(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	issuer := func() *PassIssuer {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewPassIssuer("secret", time.Minute, NewRedisRedeemed(client))
+	}
+	a, b := issuer(), issuer()
+
+	token := a.Issue("c1", "site", "shop.example.com", "login", 90)
+	claims, err := b.Redeem(ctx, token, "site", "shop.example.com", "login")
+	if err != nil {
+		t.Fatalf("redeem on another instance: %v", err)
+	}
+	if _, err := a.Redeem(ctx, token, "site", "", ""); !errors.Is(err, ErrRedeemedPass) {
+		t.Fatalf("redeem on issuing instance: got %v, want ErrRedeemedPass", err)
+	}
+	if ttl := mr.TTL(redeemedPrefix + claims.ID); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("redeemed pass kept for %v, want up to its expiry", ttl)
+	}
+
+	mr.SetError("connection lost")
+	token = a.Issue("c2", "site", "shop.example.com", "login", 90)
+	if _, err := b.Redeem(ctx, token, "site", "", ""); !errors.Is(err, ErrPassStore) {
+		t.Fatalf("redeem with redis down: got %v, want ErrPassStore", err)
+	}
+}
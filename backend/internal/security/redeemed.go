@@ -0,0 +1,71 @@
+package security
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redeemed — погашенные пропуски. Хранилище должно быть общим для всех инстансов,
+// которые проверяют пропуски одним ключом подписи: иначе за балансером один
+// пропуск можно погасить на каждом инстансе по разу.
+type Redeemed interface {
+	// Mark отмечает пропуск id погашенным до expiry; false — он уже был погашен
+	Mark(ctx context.Context, id string, expiry time.Time) (bool, error)
+}
+
+// MemoryRedeemed помнит погашенные пропуски в памяти процесса. Годится, когда
+// пропуски проверяет один инстанс.
+type MemoryRedeemed struct {
+	mu        sync.Mutex
+	ids       map[string]time.Time // ID погашенного пропуска -> его срок
+	lastSweep time.Time
+}
+
+// sweepInterval — как часто забывать истёкшие пропуски
+const sweepInterval = time.Minute
+
+func NewMemoryRedeemed() *MemoryRedeemed {
+	return &MemoryRedeemed{ids: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+func (m *MemoryRedeemed) Mark(_ context.Context, id string, expiry time.Time) (bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Истёкшие пропуски отклоняются и без записи, их можно забыть
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, exp := range m.ids {
+			if now.After(exp) {
+				delete(m.ids, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	if _, ok := m.ids[id]; ok {
+		return false, nil
+	}
+	m.ids[id] = expiry
+	return true, nil
+}
+
+// RedisRedeemed помнит погашенные пропуски в Redis, общем для всех инстансов.
+// Ключ живёт до срока пропуска, после него пропуск отклоняется и без записи.
+type RedisRedeemed struct {
+	client redis.UniversalClient
+}
+
+// redeemedPrefix — префикс ключей погашенных пропусков в Redis
+const redeemedPrefix = "captcha:pass:"
+
+func NewRedisRedeemed(client redis.UniversalClient) *RedisRedeemed {
+	return &RedisRedeemed{client: client}
+}
+
+func (r *RedisRedeemed) Mark(ctx context.Context, id string, expiry time.Time) (bool, error) {
+	// Срок пропуска — в целых секундах, и до него может оставаться меньше секунды
+	ttl := max(time.Until(expiry), time.Second)
+	return r.client.SetNX(ctx, redeemedPrefix+id, 1, ttl).Result()
+}
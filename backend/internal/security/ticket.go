@@ -0,0 +1,85 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTicket = errors.New("invalid session ticket")
+	ErrExpiredTicket = errors.New("session ticket expired")
+	ErrOriginTicket  = errors.New("session ticket issued for another origin")
+	ErrSiteTicket    = errors.New("session ticket issued for another site key")
+)
+
+// TicketIssuer выдаёт короткоживущие подписанные билеты сессии. Билет выдаётся вместе
+// с заданием и привязан к его ID, origin страницы и ключу сайта; WebSocket без валидного билета
+// не принимается, так что сторонняя страница не может управлять чужой сессией капчи.
+type TicketIssuer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewTicketIssuer создаёт издателя билетов. Без секрета ключ генерируется случайно:
+// билеты тогда действуют только в пределах одного процесса.
+func NewTicketIssuer(secret string, ttl time.Duration) *TicketIssuer {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &TicketIssuer{key: key, ttl: ttl}
+}
+
+// Issue выдаёт билет вида base64(challengeID|origin|siteKey|expiry).base64(hmac)
+func (t *TicketIssuer) Issue(challengeID, origin, siteKey string) string {
+	expiry := strconv.FormatInt(time.Now().Add(t.ttl).Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(challengeID + "|" + origin + "|" + siteKey + "|" + expiry))
+	return payload + "." + t.sign(payload)
+}
+
+// Verify проверяет подпись, срок действия, origin и ключ сайта билета и возвращает ID задания
+func (t *TicketIssuer) Verify(ticket, origin, siteKey string) (string, error) {
+	payload, sig, ok := strings.Cut(ticket, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(t.sign(payload))) {
+		return "", ErrInvalidTicket
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalidTicket
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 {
+		return "", ErrInvalidTicket
+	}
+
+	expiry, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return "", ErrInvalidTicket
+	}
+	if time.Now().Unix() > expiry {
+		return "", ErrExpiredTicket
+	}
+	if parts[1] != origin {
+		return "", ErrOriginTicket
+	}
+	if parts[2] != siteKey {
+		return "", ErrSiteTicket
+	}
+	return parts[0], nil
+}
+
+func (t *TicketIssuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -13,6 +13,10 @@ import (
 
 	"github.com/theborzet/captcha_service/internal/challenge"
 	captcha "github.com/theborzet/captcha_service/internal/grpc/capcha"
+	"github.com/theborzet/captcha_service/internal/recording"
+	"github.com/theborzet/captcha_service/internal/risk"
+	"github.com/theborzet/captcha_service/internal/scoring"
+	"github.com/theborzet/captcha_service/internal/tenant"
 	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
 )
 
@@ -29,12 +33,18 @@ type Server struct {
 func NewCaptchaServer(
 	log *slog.Logger,
 	challengeStore *challenge.ChallengeStore,
+	pool *challenge.Pool,
+	riskTracker *risk.Tracker,
+	recorder *recording.Recorder,
+	scorer *scoring.Engine,
+	tenants *tenant.Registry,
+	chain []string,
 	instanceID, challengeType, captchaHost, balancerHost string,
 	captchaPort, balancerPort int,
 ) *Server {
 	grpcServer := grpc.NewServer()
 
-	captchaService := captcha.NewCaptchaService(challengeStore, log)
+	captchaService := captcha.NewCaptchaService(challengeStore, pool, riskTracker, challengeType, chain, recorder, scorer, tenants, log)
 
 	// Регистрируем сервис капчи
 	pb.RegisterCaptchaServiceServer(grpcServer, captchaService)
@@ -0,0 +1,185 @@
+package solver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"html"
+	"image"
+	"image/png"
+	"math/bits"
+	"regexp"
+	"strconv"
+)
+
+// Всё, что скрапер узнаёт о задании без распознавания картинок: значения
+// data-атрибутов, встроенные PNG и то, что выдаёт их палитра.
+
+var (
+	dataAttr = regexp.MustCompile(`data-\w+="([^"]*)"`)
+	pngURI   = regexp.MustCompile(`data:image/png;base64,([A-Za-z0-9+/=&#;]+)`)
+)
+
+// Палитра картинок заданий общая и видна в любом PNG: индекс 0 прозрачный,
+// дальше по paletteLevels уровней яркости на каждый оттенок
+const paletteLevels = 8
+
+// attrs возвращает значения data-атрибутов в порядке разметки. Имена атрибутов
+// случайные, но первым у корня задания всегда идёт ID, а за ним параметры.
+func attrs(page string) []string {
+	var values []string
+	for _, m := range dataAttr.FindAllStringSubmatch(page, -1) {
+		values = append(values, html.UnescapeString(m[1]))
+	}
+	return values
+}
+
+// attrInt — числовой data-атрибут с номером i или def, если его нет
+func attrInt(page string, i, def int) int {
+	values := attrs(page)
+	if i >= len(values) {
+		return def
+	}
+	n, err := strconv.Atoi(values[i])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// images декодирует все PNG, встроенные в HTML задания
+func images(page string) []*image.Paletted {
+	var imgs []*image.Paletted
+	for _, m := range pngURI.FindAllStringSubmatch(page, -1) {
+		raw, err := base64.StdEncoding.DecodeString(html.UnescapeString(m[1]))
+		if err != nil {
+			continue
+		}
+		if img, ok := decodePaletted(raw); ok {
+			imgs = append(imgs, img)
+		}
+	}
+	return imgs
+}
+
+func decodePaletted(raw []byte) (*image.Paletted, bool) {
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	p, ok := img.(*image.Paletted)
+	return p, ok
+}
+
+// dragPiece находит в drag-drop поле и перетаскиваемую фигуру: фигура меньше
+func dragPiece(page string) (field, piece *image.Paletted, ok bool) {
+	imgs := images(page)
+	if len(imgs) < 2 {
+		return nil, nil, false
+	}
+	field, piece = imgs[0], imgs[1]
+	if piece.Bounds().Dx() > field.Bounds().Dx() {
+		field, piece = piece, field
+	}
+	return field, piece, true
+}
+
+// dragTarget ищет цель drag-drop по палитре: фигура и контур цели одного оттенка,
+// и контур нарисован уровнем яркости, которого нет в светлом фоне. Окно размером
+// с фигуру, в котором больше всего пикселей этого цвета, и есть цель.
+func dragTarget(field, piece *image.Paletted) (image.Point, bool) {
+	counts := make(map[uint8]int)
+	for _, c := range piece.Pix {
+		if c != 0 {
+			counts[c]++
+		}
+	}
+	var fill uint8
+	for c, n := range counts {
+		if n > counts[fill] {
+			fill = c
+		}
+	}
+	if fill == 0 {
+		return image.Point{}, false
+	}
+	hue := (int(fill) - 1) / paletteLevels
+	outline := uint8(1 + hue*paletteLevels + 1)
+
+	// Суммы по прямоугольникам через интегральное изображение
+	b := field.Bounds()
+	w, h := b.Dx(), b.Dy()
+	sum := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		row := 0
+		for x := 0; x < w; x++ {
+			if field.Pix[field.PixOffset(b.Min.X+x, b.Min.Y+y)] == outline {
+				row++
+			}
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+	size := piece.Bounds().Dx()
+	best, at := 0, image.Point{}
+	for y := 0; y+size <= h; y++ {
+		for x := 0; x+size <= w; x++ {
+			n := sum[(y+size)*(w+1)+x+size] - sum[y*(w+1)+x+size] - sum[(y+size)*(w+1)+x] + sum[y*(w+1)+x]
+			if n > best {
+				best, at = n, image.Pt(x, y)
+			}
+		}
+	}
+	return at, best > size
+}
+
+// powSolve перебирает nonce по параметрам из разметки: seed, число нулевых бит
+// и объём памяти memory-hard варианта. Хеш повторяет воркер задания.
+func powSolve(page string) (uint64, bool) {
+	values := attrs(page)
+	if len(values) < 4 {
+		return 0, false
+	}
+	seed := values[1]
+	zeros, err1 := strconv.Atoi(values[2])
+	memory, err2 := strconv.Atoi(values[3])
+	if err1 != nil || err2 != nil || zeros > 32 {
+		return 0, false
+	}
+	v := make([]byte, memory*32)
+	for nonce := uint64(0); nonce < 1<<32; nonce++ {
+		if leadingZeros(powHash(seed, nonce, v)) >= zeros {
+			return nonce, true
+		}
+	}
+	return 0, false
+}
+
+func powHash(seed string, nonce uint64, v []byte) []byte {
+	x := sha256.Sum256([]byte(seed + ":" + strconv.FormatUint(nonce, 10)))
+	memory := len(v) / 32
+	for i := 0; i < memory; i++ {
+		copy(v[i*32:], x[:])
+		x = sha256.Sum256(x[:])
+	}
+	var t [32]byte
+	for i := 0; i < memory; i++ {
+		j := int(binary.BigEndian.Uint32(x[:4]) % uint32(memory))
+		for k := range t {
+			t[k] = x[k] ^ v[j*32+k]
+		}
+		x = sha256.Sum256(t[:])
+	}
+	return x[:]
+}
+
+func leadingZeros(hash []byte) int {
+	n := 0
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,357 @@
+// Package solver — боты, которые отвечают на задания через harness.Session так,
+// как отвечал бы скрипт в iframe: от случайных ответов до разбора разметки и
+// картинок задания (scrape.go). Ими пользуются red team и нагрузочный тест.
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/harness"
+)
+
+// Style — чем бот отличается от остальных
+type Style struct {
+	Forge    bool // подделывает проверки окружения
+	Informed bool // разбирает задание (scrape.go), иначе отвечает наугад
+	Human    bool // выжидает с разбросом и двигается неровно; иначе паузы и шаги одинаковые
+}
+
+// Манеры ботов red team, см. cmd/redteam
+var (
+	Scraper = Style{Forge: true, Informed: true, Human: true}
+	Linear  = Style{Informed: true}
+	Random  = Style{Human: true}
+)
+
+// wait выдерживает паузу: человекоподобный бот растягивает её случайно, линейный — нет
+func (st Style) wait(ctx context.Context, d time.Duration) error {
+	if st.Human {
+		d = time.Duration(float64(d) * (0.8 + rand.Float64()*0.8))
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// jitter — случайное отклонение ±n для человекоподобного бота
+func (st Style) jitter(n int) int {
+	if !st.Human || n <= 0 {
+		return 0
+	}
+	return rand.Intn(2*n+1) - n
+}
+
+// path ведёт из from в to за n шагов: по прямой равными шагами или по дуге
+// с переменной скоростью и дрожанием
+func (st Style) path(from, to image.Point, n int) []image.Point {
+	points := make([]image.Point, n)
+	bend := float64(st.jitter(20))
+	for i := range points {
+		t := float64(i+1) / float64(n)
+		if st.Human {
+			t = (1 - math.Cos(t*math.Pi)) / 2
+		}
+		x := float64(from.X) + float64(to.X-from.X)*t
+		y := float64(from.Y) + float64(to.Y-from.Y)*t + bend*math.Sin(t*math.Pi)
+		points[i] = image.Pt(int(math.Round(x))+st.jitter(1), int(math.Round(y))+st.jitter(1))
+	}
+	points[n-1] = to
+	return points
+}
+
+// solveFunc отвечает на задание одного типа. Если итог или следующий этап пришёл
+// по ходу ответа, он возвращается, иначе его дождётся вызывающий.
+type solveFunc func(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error)
+
+var solvers = map[string]solveFunc{
+	"drag-drop-v1":      solveDragDrop,
+	"pow-v1":            solvePoW,
+	"pow-memory-v1":     solvePoW,
+	"click-sequence-v1": solveClickSequence,
+	"rotate-v1":         solveRotate,
+	"odd-one-out-v1":    solveOddOneOut,
+	"maze-v1":           solveMaze,
+	"audio-v1":          solveAudio,
+	"rhythm-v1":         solveRhythm,
+}
+
+// ErrUnknownType — для типа задания нет решателя
+var ErrUnknownType = errors.New("no solver for challenge type")
+
+// settle разбирает сообщения сервиса в течение d: обновления задания копятся,
+// итог или следующий этап прерывает ожидание
+func settle(ctx context.Context, s *harness.Session, d time.Duration) (*harness.ServerEvent, []harness.Op, error) {
+	wctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	var ops []harness.Op
+	for {
+		ev, err := s.Next(wctx)
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return nil, ops, nil
+			}
+			return nil, ops, err
+		}
+		if ev.Result != nil || ev.Stage != nil {
+			return &ev, ops, nil
+		}
+		ops = append(ops, harness.DecodeOps(ev.Data)...)
+	}
+}
+
+func solveDragDrop(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	field, piece, ok := dragPiece(s.Page.HTML)
+	target := image.Pt(rand.Intn(260), rand.Intn(140))
+	if err := s.Send(harness.Event{Name: "drag_start"}); err != nil {
+		return nil, err
+	}
+	// На начало перетаскивания сервер может перенести цель и прислать новое поле
+	done, ops, err := settle(ctx, s, 300*time.Millisecond)
+	if done != nil || err != nil {
+		return done, err
+	}
+	for _, op := range ops {
+		if op.Code == harness.OpField {
+			if img, decoded := decodePaletted(op.Data); decoded {
+				field = img
+			}
+		}
+	}
+	if st.Informed && ok {
+		if at, found := dragTarget(field, piece); found {
+			target = at
+		}
+	}
+	if err := st.wait(ctx, 1200*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return nil, s.Send(harness.Event{
+		Name:    "drag_drop",
+		X:       target.X + st.jitter(1),
+		Y:       target.Y + st.jitter(1),
+		Success: true,
+	})
+}
+
+func solvePoW(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	nonce := uint64(rand.Uint32())
+	if st.Informed {
+		if n, ok := powSolve(s.Page.HTML); ok {
+			nonce = n
+		}
+	}
+	return nil, s.Send(harness.Event{Name: "pow", Data: strconv.FormatUint(nonce, 10)})
+}
+
+func solveClickSequence(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	count := 3
+	if st.Informed {
+		count = attrInt(s.Page.HTML, 1, count)
+	}
+	// Без распознавания цифр остаётся кликать по случайным местам поля
+	clicks := make([]harness.Click, count)
+	for i := range clicks {
+		clicks[i] = harness.Click{P: image.Pt(20+rand.Intn(280), 20+rand.Intn(160))}
+		if i > 0 {
+			clicks[i].Pause = 500 * time.Millisecond
+			if st.Human {
+				clicks[i].Pause = time.Duration(300+rand.Intn(700)) * time.Millisecond
+			}
+		}
+	}
+	for _, c := range clicks {
+		if err := st.wait(ctx, c.Pause); err != nil {
+			return nil, err
+		}
+		if err := s.Send(harness.Event{Name: "click", X: c.P.X, Y: c.P.Y}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func solveRotate(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	if err := s.Send(harness.Event{Name: "rotate_start"}); err != nil {
+		return nil, err
+	}
+	// Где у предмета верх, без распознавания не понять: угол наугад
+	final := rand.Intn(360)
+	steps := 20
+	var total time.Duration
+	for _, p := range st.path(image.Pt(0, 0), image.Pt(final, 0), steps) {
+		dt := 50 * time.Millisecond
+		if st.Human {
+			dt = time.Duration(30+rand.Intn(60)) * time.Millisecond
+		}
+		if err := st.wait(ctx, dt); err != nil {
+			return nil, err
+		}
+		if err := s.Send(harness.Event{Name: "turn", X: p.X}); err != nil {
+			return nil, err
+		}
+		total += dt
+	}
+	if err := st.wait(ctx, max(1500*time.Millisecond-total, 0)); err != nil {
+		return nil, err
+	}
+	return nil, s.Send(harness.Event{Name: "rotate", X: final})
+}
+
+func solveOddOneOut(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	n := 3
+	if st.Informed {
+		n = attrInt(s.Page.HTML, 1, n)
+	}
+	const grid = 240
+	tile := grid / n
+	if err := st.wait(ctx, time.Second); err != nil {
+		return nil, err
+	}
+	for _, i := range rand.Perm(n * n) {
+		x := i%n*tile + tile/2 + st.jitter(tile/4)
+		y := i/n*tile + tile/2 + st.jitter(tile/4)
+		if err := s.Send(harness.Event{Name: "tap", X: x, Y: y}); err != nil {
+			return nil, err
+		}
+		done, _, err := settle(ctx, s, 400*time.Millisecond)
+		if done != nil || err != nil {
+			return done, err
+		}
+	}
+	return nil, nil
+}
+
+func solveMaze(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	// Стены только на картинке: путь по диагонали от входа к выходу
+	start := image.Pt(22+st.jitter(3), 22+st.jitter(3))
+	points := st.path(start, image.Pt(298, 178), 60)
+	prev, first := start, true
+	for chunk := 0; chunk < len(points); chunk += 10 {
+		var steps []image.Point
+		for _, p := range points[chunk:min(chunk+10, len(points))] {
+			steps = append(steps, p.Sub(prev))
+			prev = p
+		}
+		name := "trace"
+		if chunk+10 >= len(points) {
+			name = "trace_end"
+		}
+		var from *image.Point
+		if first {
+			from, first = &start, false
+		}
+		if err := s.Send(harness.Event{Name: name, Data: harness.Trace(from, steps)}); err != nil {
+			return nil, err
+		}
+		if name == "trace_end" {
+			break
+		}
+		// Путь сквозь стену сервер обрывает сразу, не дожидаясь конца
+		done, _, err := settle(ctx, s, time.Duration(200+st.jitter(40))*time.Millisecond)
+		if done != nil || err != nil {
+			return done, err
+		}
+	}
+	return nil, nil
+}
+
+var maxLength = regexp.MustCompile(`maxlength="(\d+)"`)
+
+func solveAudio(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	count := 4
+	if m := maxLength.FindStringSubmatch(s.Page.HTML); st.Informed && m != nil {
+		count, _ = strconv.Atoi(m[1])
+	}
+	if err := s.Send(harness.Event{Name: "audio_play"}); err != nil {
+		return nil, err
+	}
+	// Запись нужно дослушать, иначе ответ не принимается
+	if err := st.wait(ctx, time.Duration(count)*time.Second+time.Second); err != nil {
+		return nil, err
+	}
+	var digits strings.Builder
+	for i := 0; i < count; i++ {
+		digits.WriteByte(byte('0' + rand.Intn(10)))
+	}
+	return nil, s.Send(harness.Event{Name: "audio_answer", Data: digits.String()})
+}
+
+func solveRhythm(ctx context.Context, s *harness.Session, st Style) (*harness.ServerEvent, error) {
+	count := 4
+	if st.Informed {
+		count = attrInt(s.Page.HTML, 1, count)
+	}
+	if err := s.Send(harness.Event{Name: "rhythm_start"}); err != nil {
+		return nil, err
+	}
+	// На каждую подсказку — нажатие после реакции; цифру без распознавания не прочесть
+	for seq := 0; seq < count; {
+		ev, err := s.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ev.Result != nil || ev.Stage != nil {
+			return &ev, nil
+		}
+		for _, op := range harness.DecodeOps(ev.Data) {
+			if op.Code != harness.OpPrompt {
+				continue
+			}
+			reaction := 250 * time.Millisecond
+			if st.Human {
+				reaction = time.Duration(300+rand.Intn(400)) * time.Millisecond
+			}
+			// Реакция по часам клиента должна совпасть с настоящей, поэтому без разброса
+			if err := (Style{}).wait(ctx, reaction); err != nil {
+				return nil, err
+			}
+			if err := s.Send(harness.Event{Name: "key", Data: harness.KeyPress(seq, 1+rand.Intn(9), reaction)}); err != nil {
+				return nil, err
+			}
+			seq++
+		}
+	}
+	return nil, nil
+}
+
+// Solve отвечает на задание и все следующие этапы цепочки, возвращая итог
+func Solve(ctx context.Context, s *harness.Session, st Style) (*harness.Result, error) {
+	for {
+		fn := solvers[s.Page.Type]
+		if fn == nil {
+			return nil, fmt.Errorf("%w %q", ErrUnknownType, s.Page.Type)
+		}
+		// Человеку нужно время, чтобы увидеть задание, прежде чем начать
+		if st.Human {
+			if err := st.wait(ctx, 600*time.Millisecond); err != nil {
+				return nil, err
+			}
+		}
+		ev, err := fn(ctx, s, st)
+		if err != nil {
+			return nil, err
+		}
+		if ev == nil {
+			got, err := s.Await(ctx)
+			if err != nil {
+				return nil, err
+			}
+			ev = &got
+		}
+		if ev.Result != nil {
+			return ev.Result, nil
+		}
+	}
+}
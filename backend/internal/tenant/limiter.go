@@ -0,0 +1,50 @@
+package tenant
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter — не больше limit запросов одного клиента за окно фиксированной длины
+type limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]counter
+	lastSweep time.Time
+}
+
+type counter struct {
+	start time.Time
+	count int
+}
+
+func newLimiter(limit int, window time.Duration) *limiter {
+	return &limiter{limit: limit, window: window, clients: make(map[string]counter)}
+}
+
+func (l *limiter) allow(clientKey string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Закончившиеся окна больше ничего не ограничивают
+	if now.Sub(l.lastSweep) > l.window {
+		for key, c := range l.clients {
+			if now.Sub(c.start) >= l.window {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c := l.clients[clientKey]
+	if now.Sub(c.start) >= l.window {
+		c = counter{start: now}
+	}
+	if c.count >= l.limit {
+		return false
+	}
+	c.count++
+	l.clients[clientKey] = c
+	return true
+}
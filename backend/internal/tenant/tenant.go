@@ -0,0 +1,158 @@
+// Package tenant — сайты, встраивающие капчу под своим ключом, и их политика:
+// с каких origin капчу можно встраивать, какие задания и какой сложности
+// выдавать, как часто одному клиенту, сколько задание живёт и каким ключом
+// подписываются пропуски. Запросы без ключа сайта идут по общим настройкам.
+package tenant
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/challenge"
+	"github.com/theborzet/captcha_service/internal/config"
+	"github.com/theborzet/captcha_service/internal/security"
+)
+
+var ErrUnknownSite = errors.New("unknown site key")
+
+// Tenant — сайт и его политика
+type Tenant struct {
+	SiteKey string // пусто у сайта по умолчанию
+	Name    string
+	Origins *security.OriginPolicy
+	Passes  *security.PassIssuer
+
+	types                        []string // пусто — любые
+	minComplexity, maxComplexity int      // 0 — без границы
+	ttl                          time.Duration
+	verifySecret                 string
+	limiter                      *limiter // nil — без ограничения частоты
+}
+
+func newTenant(cfg config.TenantConfig, redeemed security.Redeemed) (*Tenant, error) {
+	for _, kind := range cfg.ChallengeTypes {
+		if !challenge.Known(kind) {
+			return nil, fmt.Errorf("site %q: unknown challenge type %q", cfg.Name, kind)
+		}
+	}
+	t := &Tenant{
+		SiteKey:       cfg.SiteKey,
+		Name:          cfg.Name,
+		Origins:       security.NewOriginPolicy(cfg.AllowedOrigins),
+		Passes:        security.NewPassIssuer(cfg.PassSecret, time.Duration(cfg.PassTTL)*time.Second, redeemed),
+		types:         cfg.ChallengeTypes,
+		minComplexity: cfg.MinComplexity,
+		maxComplexity: cfg.MaxComplexity,
+		ttl:           time.Duration(cfg.ChallengeTTL) * time.Second,
+		verifySecret:  cfg.VerifySecret,
+	}
+	if cfg.RateLimit > 0 {
+		t.limiter = newLimiter(cfg.RateLimit, time.Minute)
+	}
+	return t, nil
+}
+
+// Allows проверяет, можно ли выдавать сайту задания такого типа
+func (t *Tenant) Allows(kind string) bool {
+	return len(t.types) == 0 || slices.Contains(t.types, kind)
+}
+
+// Type — тип задания для сайта: предложенный, если сайт его разрешает, иначе первый из разрешённых
+func (t *Tenant) Type(kind string) string {
+	if t.Allows(kind) {
+		return kind
+	}
+	return t.types[0]
+}
+
+// Complexity приводит запрошенную complexity к границам сайта. Границы ограничивают
+// то, что сайт просит для всех клиентов; подъём сложности по риску клиента идёт
+// после них, до risk.max_complexity, и сайтом не ограничивается.
+func (t *Tenant) Complexity(complexity int) int {
+	if t.minComplexity > 0 {
+		complexity = max(complexity, t.minComplexity)
+	}
+	if t.maxComplexity > 0 {
+		complexity = min(complexity, t.maxComplexity)
+	}
+	return complexity
+}
+
+// TTL — срок жизни заданий сайта, 0 — по умолчанию
+func (t *Tenant) TTL() time.Duration {
+	return t.ttl
+}
+
+// Allow учитывает запрос задания клиентом и сообщает, укладывается ли он в ограничение частоты.
+// Квота у каждого сайта своя. Запрос без ключа клиента при ограничении отклоняется: иначе все
+// такие запросы делили бы одну квоту.
+func (t *Tenant) Allow(clientKey string) bool {
+	if t.limiter == nil {
+		return true
+	}
+	return clientKey != "" && t.limiter.allow(clientKey, time.Now())
+}
+
+// Registry — сайты по ключам
+type Registry struct {
+	def   *Tenant
+	sites map[string]*Tenant
+	order []*Tenant // для поиска по секрету в порядке конфига
+}
+
+// NewRegistry собирает сайты из конфига; def — политика запросов без ключа,
+// redeemed — общее для сайтов хранилище погашенных пропусков
+func NewRegistry(def config.TenantConfig, tenants []config.TenantConfig, redeemed security.Redeemed) (*Registry, error) {
+	def.SiteKey = ""
+	d, err := newTenant(def, redeemed)
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{def: d, sites: make(map[string]*Tenant, len(tenants))}
+	for _, cfg := range tenants {
+		t, err := newTenant(cfg, redeemed)
+		if err != nil {
+			return nil, err
+		}
+		r.sites[t.SiteKey] = t
+		r.order = append(r.order, t)
+	}
+	return r, nil
+}
+
+// Lookup возвращает сайт по ключу; пустой ключ — сайт по умолчанию
+func (r *Registry) Lookup(siteKey string) (*Tenant, error) {
+	if siteKey == "" {
+		return r.def, nil
+	}
+	if t, ok := r.sites[siteKey]; ok {
+		return t, nil
+	}
+	return nil, ErrUnknownSite
+}
+
+// BySecret находит сайт по секрету, который его сервер передаёт в /siteverify.
+// Подходит только точное совпадение с непустым секретом: пропуски сайта без
+// verify_secret, в том числе сайта по умолчанию, проверить нельзя.
+func (r *Registry) BySecret(secret string) (*Tenant, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	for _, t := range r.order {
+		if t.matches(secret) {
+			return t, true
+		}
+	}
+	if r.def.matches(secret) {
+		return r.def, true
+	}
+	return nil, false
+}
+
+// matches сравнивает секрет с непустым verify_secret сайта
+func (t *Tenant) matches(secret string) bool {
+	return t.verifySecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(t.verifySecret)) == 1
+}
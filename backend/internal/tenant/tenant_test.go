@@ -0,0 +1,118 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/theborzet/captcha_service/internal/config"
+	"github.com/theborzet/captcha_service/internal/security"
+)
+
+func newTestRegistry(t *testing.T, tenants ...config.TenantConfig) *Registry {
+	t.Helper()
+	r, err := NewRegistry(config.TenantConfig{Name: "default", PassTTL: 60, VerifySecret: "default-secret"}, tenants, security.NewMemoryRedeemed())
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	return r
+}
+
+// Окно ограничения фиксированное: квота клиента восстанавливается, когда окно кончается,
+// и не зависит от квот других клиентов
+func TestLimiter(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newLimiter(2, time.Minute)
+	steps := []struct {
+		key  string
+		at   time.Duration
+		want bool
+	}{
+		{"a", 0, true},
+		{"a", 10 * time.Second, true},
+		{"a", 20 * time.Second, false},
+		{"b", 20 * time.Second, true},
+		{"a", 59 * time.Second, false},
+		{"a", 61 * time.Second, true},
+		{"a", 62 * time.Second, true},
+		{"a", 63 * time.Second, false},
+		{"b", 90 * time.Second, true},
+	}
+	for i, s := range steps {
+		if got := l.allow(s.key, start.Add(s.at)); got != s.want {
+			t.Fatalf("step %d: client %s at %v: got %v, want %v", i, s.key, s.at, got, s.want)
+		}
+	}
+	// Закончившиеся окна забываются
+	l.allow("c", start.Add(5*time.Minute))
+	if len(l.clients) != 1 {
+		t.Fatalf("kept %d clients after the windows ended, want 1", len(l.clients))
+	}
+}
+
+// Квота считается по ключу клиента отдельно для каждого сайта; без ограничения
+// сайт пропускает всех, а с ограничением запрос без ключа отклоняется
+func TestTenantAllow(t *testing.T) {
+	r := newTestRegistry(t,
+		config.TenantConfig{SiteKey: "limited", RateLimit: 2},
+		config.TenantConfig{SiteKey: "other", RateLimit: 2},
+	)
+	limited, _ := r.Lookup("limited")
+	other, _ := r.Lookup("other")
+	def, _ := r.Lookup("")
+
+	for i := range 2 {
+		if !limited.Allow("client") {
+			t.Fatalf("request %d rejected within the limit", i+1)
+		}
+	}
+	if limited.Allow("client") {
+		t.Fatal("request over the limit allowed")
+	}
+	if !limited.Allow("another-client") {
+		t.Fatal("another client shares the exhausted quota")
+	}
+	if !other.Allow("client") {
+		t.Fatal("another site shares the exhausted quota")
+	}
+	if limited.Allow("") {
+		t.Fatal("request without client key allowed under a limit")
+	}
+	for i := range 10 {
+		if !def.Allow("") || !def.Allow("client") {
+			t.Fatalf("request %d rejected without a limit", i+1)
+		}
+	}
+}
+
+// Сайт находится только по точному непустому verify_secret, сначала среди сайтов
+// из конфига, затем сайт по умолчанию
+func TestBySecret(t *testing.T) {
+	r := newTestRegistry(t,
+		config.TenantConfig{SiteKey: "shop", VerifySecret: "shop-secret"},
+		config.TenantConfig{SiteKey: "blog"},
+	)
+	tests := []struct {
+		name    string
+		secret  string
+		siteKey string
+		ok      bool
+	}{
+		{name: "site secret", secret: "shop-secret", siteKey: "shop", ok: true},
+		{name: "default secret", secret: "default-secret", siteKey: "", ok: true},
+		{name: "empty secret", secret: ""},
+		{name: "unknown secret", secret: "guess"},
+		{name: "secret prefix", secret: "shop-secre"},
+		{name: "secret with suffix", secret: "shop-secret2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, ok := r.BySecret(tt.secret)
+			if ok != tt.ok {
+				t.Fatalf("got found %v, want %v", ok, tt.ok)
+			}
+			if ok && site.SiteKey != tt.siteKey {
+				t.Fatalf("got site %q, want %q", site.SiteKey, tt.siteKey)
+			}
+		})
+	}
+}
@@ -7,32 +7,64 @@ import (
 	"net/http"
 
 	"github.com/gorilla/websocket"
+	"github.com/theborzet/captcha_service/internal/security"
+	"github.com/theborzet/captcha_service/internal/tenant"
 	pb "github.com/theborzet/captcha_service/pkg/api/pb/captcha/v1"
 )
 
 // Proxy - проксирует WebSocket-соединение между браузером и gRPC-сервером капчи.
 type Proxy struct {
-	client pb.CaptchaServiceClient
-	log    *slog.Logger
-	ctx    context.Context // Добавлен контекст
+	client  pb.CaptchaServiceClient
+	tenants *tenant.Registry
+	tickets *security.TicketIssuer
+	log     *slog.Logger
+	ctx     context.Context // Добавлен контекст
 }
 
 // NewProxy создаёт новый WebSocket-прокси.
-func NewProxy(client pb.CaptchaServiceClient, log *slog.Logger, ctx context.Context) *Proxy {
+func NewProxy(
+	client pb.CaptchaServiceClient,
+	tenants *tenant.Registry,
+	tickets *security.TicketIssuer,
+	log *slog.Logger,
+	ctx context.Context,
+) *Proxy {
 	return &Proxy{
-		client: client,
-		log:    log,
-		ctx:    ctx,
+		client:  client,
+		tenants: tenants,
+		tickets: tickets,
+		log:     log,
+		ctx:     ctx,
 	}
 }
 
 // ServeHTTP обрабатывает входящие WebSocket-запросы и проксирует события
-// между клиентом (браузером) и gRPC-сервисом капчи.
+// между клиентом (браузером) и gRPC-сервисом капчи. Соединение принимается
+// только с origin, разрешённого сайтом из параметра sitekey, и с билетом
+// сессии, выданным вместе с заданием для этого сайта.
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	siteKey := r.URL.Query().Get("sitekey")
+	site, err := p.tenants.Lookup(siteKey)
+	if err != nil {
+		p.log.Warn("WebSocket site key rejected", slog.String("site_key", siteKey))
+		http.Error(w, "Unknown site key", http.StatusForbidden)
+		return
+	}
+	if !site.Origins.Allowed(r) {
+		p.log.Warn("WebSocket origin rejected", slog.String("origin", r.Header.Get("Origin")), slog.String("site", site.Name))
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	challengeID, err := p.tickets.Verify(r.URL.Query().Get("ticket"), security.RequestOrigin(r), site.SiteKey)
+	if err != nil {
+		p.log.Warn("WebSocket ticket rejected", slog.Any("error", err), slog.String("origin", r.Header.Get("Origin")))
+		http.Error(w, "Invalid session ticket", http.StatusForbidden)
+		return
+	}
+
 	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		CheckOrigin: site.Origins.Allowed,
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -42,7 +74,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	p.log.Info("WebSocket connection established")
+	p.log.Info("WebSocket connection established", slog.String("challenge_id", challengeID))
 
 	// Используем переданный контекст с возможностью отмены
 	ctx, cancel := context.WithCancel(p.ctx)
@@ -87,9 +119,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			p.log.Info("Processed event", slog.String("type", event.Type), slog.String("data", event.Data)) // Логируем обработанное событие
 
 			// Отправляем на gRPC сервер
+			// ID задания берём из билета: события по другим заданиям сервис отклонит
 			clientEvent := &pb.ClientEvent{
-				EventType: pb.ClientEvent_FRONTEND_EVENT,
-				Data:      []byte(event.Data),
+				EventType:   pb.ClientEvent_FRONTEND_EVENT,
+				ChallengeId: challengeID,
+				Data:        []byte(event.Data),
 			}
 
 			if err := stream.Send(clientEvent); err != nil {
@@ -73,6 +73,11 @@ func (ClientEvent_EventType) EnumDescriptor() ([]byte, []int) {
 type ChallengeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Complexity    int32                  `protobuf:"varint,1,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	ClientKey     string                 `protobuf:"bytes,2,opt,name=client_key,json=clientKey,proto3" json:"client_key,omitempty"`
+	Accessible    bool                   `protobuf:"varint,3,opt,name=accessible,proto3" json:"accessible,omitempty"`
+	Hostname      string                 `protobuf:"bytes,4,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Action        string                 `protobuf:"bytes,5,opt,name=action,proto3" json:"action,omitempty"`
+	SiteKey       string                 `protobuf:"bytes,6,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -114,12 +119,50 @@ func (x *ChallengeRequest) GetComplexity() int32 {
 	return 0
 }
 
+func (x *ChallengeRequest) GetClientKey() string {
+	if x != nil {
+		return x.ClientKey
+	}
+	return ""
+}
+
+func (x *ChallengeRequest) GetAccessible() bool {
+	if x != nil {
+		return x.Accessible
+	}
+	return false
+}
+
+func (x *ChallengeRequest) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *ChallengeRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ChallengeRequest) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
+
 type ChallengeResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
-	Html          string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId           string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Html                  string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+	Complexity            int32                  `protobuf:"varint,3,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	ChallengeType         string                 `protobuf:"bytes,4,opt,name=challenge_type,json=challengeType,proto3" json:"challenge_type,omitempty"`
+	ContentSecurityPolicy string                 `protobuf:"bytes,5,opt,name=content_security_policy,json=contentSecurityPolicy,proto3" json:"content_security_policy,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
 }
 
 func (x *ChallengeResponse) Reset() {
@@ -166,6 +209,27 @@ func (x *ChallengeResponse) GetHtml() string {
 	return ""
 }
 
+func (x *ChallengeResponse) GetComplexity() int32 {
+	if x != nil {
+		return x.Complexity
+	}
+	return 0
+}
+
+func (x *ChallengeResponse) GetChallengeType() string {
+	if x != nil {
+		return x.ChallengeType
+	}
+	return ""
+}
+
+func (x *ChallengeResponse) GetContentSecurityPolicy() string {
+	if x != nil {
+		return x.ContentSecurityPolicy
+	}
+	return ""
+}
+
 type ClientEvent struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	EventType     ClientEvent_EventType  `protobuf:"varint,1,opt,name=event_type,json=eventType,proto3,enum=captcha.v1.ClientEvent_EventType" json:"event_type,omitempty"`
@@ -233,6 +297,7 @@ type ServerEvent struct {
 	//	*ServerEvent_Result
 	//	*ServerEvent_ClientJs
 	//	*ServerEvent_ClientData
+	//	*ServerEvent_Stage
 	Event         isServerEvent_Event `protobuf_oneof:"event"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -302,6 +367,15 @@ func (x *ServerEvent) GetClientData() *ServerEvent_SendClientData {
 	return nil
 }
 
+func (x *ServerEvent) GetStage() *ServerEvent_NextStage {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Stage); ok {
+			return x.Stage
+		}
+	}
+	return nil
+}
+
 type isServerEvent_Event interface {
 	isServerEvent_Event()
 }
@@ -318,23 +392,198 @@ type ServerEvent_ClientData struct {
 	ClientData *ServerEvent_SendClientData `protobuf:"bytes,3,opt,name=client_data,json=clientData,proto3,oneof"`
 }
 
+type ServerEvent_Stage struct {
+	Stage *ServerEvent_NextStage `protobuf:"bytes,4,opt,name=stage,proto3,oneof"`
+}
+
 func (*ServerEvent_Result) isServerEvent_Event() {}
 
 func (*ServerEvent_ClientJs) isServerEvent_Event() {}
 
 func (*ServerEvent_ClientData) isServerEvent_Event() {}
 
+func (*ServerEvent_Stage) isServerEvent_Event() {}
+
+type VerifyTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Hostname      string                 `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Action        string                 `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	SiteKey       string                 `protobuf:"bytes,4,opt,name=site_key,json=siteKey,proto3" json:"site_key,omitempty"`
+	Secret        string                 `protobuf:"bytes,5,opt,name=secret,proto3" json:"secret,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyTokenRequest) Reset() {
+	*x = VerifyTokenRequest{}
+	mi := &file_captcha_v1_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyTokenRequest) ProtoMessage() {}
+
+func (x *VerifyTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_captcha_v1_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyTokenRequest.ProtoReflect.Descriptor instead.
+func (*VerifyTokenRequest) Descriptor() ([]byte, []int) {
+	return file_captcha_v1_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *VerifyTokenRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *VerifyTokenRequest) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *VerifyTokenRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *VerifyTokenRequest) GetSiteKey() string {
+	if x != nil {
+		return x.SiteKey
+	}
+	return ""
+}
+
+func (x *VerifyTokenRequest) GetSecret() string {
+	if x != nil {
+		return x.Secret
+	}
+	return ""
+}
+
+type VerifyTokenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Score         int32                  `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
+	ChallengeId   string                 `protobuf:"bytes,3,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Hostname      string                 `protobuf:"bytes,4,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Action        string                 `protobuf:"bytes,5,opt,name=action,proto3" json:"action,omitempty"`
+	IssuedAt      int64                  `protobuf:"varint,6,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"`
+	ErrorCode     string                 `protobuf:"bytes,7,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyTokenResponse) Reset() {
+	*x = VerifyTokenResponse{}
+	mi := &file_captcha_v1_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyTokenResponse) ProtoMessage() {}
+
+func (x *VerifyTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_captcha_v1_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyTokenResponse.ProtoReflect.Descriptor instead.
+func (*VerifyTokenResponse) Descriptor() ([]byte, []int) {
+	return file_captcha_v1_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *VerifyTokenResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *VerifyTokenResponse) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *VerifyTokenResponse) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *VerifyTokenResponse) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *VerifyTokenResponse) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *VerifyTokenResponse) GetIssuedAt() int64 {
+	if x != nil {
+		return x.IssuedAt
+	}
+	return 0
+}
+
+func (x *VerifyTokenResponse) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
 type ServerEvent_ChallengeResult struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId       string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
 	ConfidencePercent int32                  `protobuf:"varint,2,opt,name=confidence_percent,json=confidencePercent,proto3" json:"confidence_percent,omitempty"`
+	Token             string                 `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
 
 func (x *ServerEvent_ChallengeResult) Reset() {
 	*x = ServerEvent_ChallengeResult{}
-	mi := &file_captcha_v1_proto_msgTypes[4]
+	mi := &file_captcha_v1_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -346,7 +595,7 @@ func (x *ServerEvent_ChallengeResult) String() string {
 func (*ServerEvent_ChallengeResult) ProtoMessage() {}
 
 func (x *ServerEvent_ChallengeResult) ProtoReflect() protoreflect.Message {
-	mi := &file_captcha_v1_proto_msgTypes[4]
+	mi := &file_captcha_v1_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -376,6 +625,13 @@ func (x *ServerEvent_ChallengeResult) GetConfidencePercent() int32 {
 	return 0
 }
 
+func (x *ServerEvent_ChallengeResult) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
 type ServerEvent_RunClientJS struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
@@ -386,7 +642,7 @@ type ServerEvent_RunClientJS struct {
 
 func (x *ServerEvent_RunClientJS) Reset() {
 	*x = ServerEvent_RunClientJS{}
-	mi := &file_captcha_v1_proto_msgTypes[5]
+	mi := &file_captcha_v1_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -398,7 +654,7 @@ func (x *ServerEvent_RunClientJS) String() string {
 func (*ServerEvent_RunClientJS) ProtoMessage() {}
 
 func (x *ServerEvent_RunClientJS) ProtoReflect() protoreflect.Message {
-	mi := &file_captcha_v1_proto_msgTypes[5]
+	mi := &file_captcha_v1_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -438,7 +694,7 @@ type ServerEvent_SendClientData struct {
 
 func (x *ServerEvent_SendClientData) Reset() {
 	*x = ServerEvent_SendClientData{}
-	mi := &file_captcha_v1_proto_msgTypes[6]
+	mi := &file_captcha_v1_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -450,7 +706,7 @@ func (x *ServerEvent_SendClientData) String() string {
 func (*ServerEvent_SendClientData) ProtoMessage() {}
 
 func (x *ServerEvent_SendClientData) ProtoReflect() protoreflect.Message {
-	mi := &file_captcha_v1_proto_msgTypes[6]
+	mi := &file_captcha_v1_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -480,19 +736,116 @@ func (x *ServerEvent_SendClientData) GetData() []byte {
 	return nil
 }
 
+type ServerEvent_NextStage struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId           string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Html                  string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+	ChallengeType         string                 `protobuf:"bytes,3,opt,name=challenge_type,json=challengeType,proto3" json:"challenge_type,omitempty"`
+	ContentSecurityPolicy string                 `protobuf:"bytes,4,opt,name=content_security_policy,json=contentSecurityPolicy,proto3" json:"content_security_policy,omitempty"`
+	Stage                 int32                  `protobuf:"varint,5,opt,name=stage,proto3" json:"stage,omitempty"`
+	Stages                int32                  `protobuf:"varint,6,opt,name=stages,proto3" json:"stages,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *ServerEvent_NextStage) Reset() {
+	*x = ServerEvent_NextStage{}
+	mi := &file_captcha_v1_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_NextStage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_NextStage) ProtoMessage() {}
+
+func (x *ServerEvent_NextStage) ProtoReflect() protoreflect.Message {
+	mi := &file_captcha_v1_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_NextStage.ProtoReflect.Descriptor instead.
+func (*ServerEvent_NextStage) Descriptor() ([]byte, []int) {
+	return file_captcha_v1_proto_rawDescGZIP(), []int{3, 3}
+}
+
+func (x *ServerEvent_NextStage) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ServerEvent_NextStage) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
+func (x *ServerEvent_NextStage) GetChallengeType() string {
+	if x != nil {
+		return x.ChallengeType
+	}
+	return ""
+}
+
+func (x *ServerEvent_NextStage) GetContentSecurityPolicy() string {
+	if x != nil {
+		return x.ContentSecurityPolicy
+	}
+	return ""
+}
+
+func (x *ServerEvent_NextStage) GetStage() int32 {
+	if x != nil {
+		return x.Stage
+	}
+	return 0
+}
+
+func (x *ServerEvent_NextStage) GetStages() int32 {
+	if x != nil {
+		return x.Stages
+	}
+	return 0
+}
+
 var File_captcha_v1_proto protoreflect.FileDescriptor
 
 const file_captcha_v1_proto_rawDesc = "" +
 	"\n" +
 	"\x10captcha_v1.proto\x12\n" +
-	"captcha.v1\"2\n" +
+	"captcha.v1\"\xc0\x01\n" +
 	"\x10ChallengeRequest\x12\x1e\n" +
 	"\n" +
 	"complexity\x18\x01 \x01(\x05R\n" +
-	"complexity\"J\n" +
+	"complexity\x12\x1d\n" +
+	"\n" +
+	"client_key\x18\x02 \x01(\tR\tclientKey\x12\x1e\n" +
+	"\n" +
+	"accessible\x18\x03 \x01(\bR\n" +
+	"accessible\x12\x1a\n" +
+	"\bhostname\x18\x04 \x01(\tR\bhostname\x12\x16\n" +
+	"\x06action\x18\x05 \x01(\tR\x06action\x12\x19\n" +
+	"\bsite_key\x18\x06 \x01(\tR\asiteKey\"\xc9\x01\n" +
 	"\x11ChallengeResponse\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04html\x18\x02 \x01(\tR\x04html\"\xd2\x01\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html\x12\x1e\n" +
+	"\n" +
+	"complexity\x18\x03 \x01(\x05R\n" +
+	"complexity\x12%\n" +
+	"\x0echallenge_type\x18\x04 \x01(\tR\rchallengeType\x126\n" +
+	"\x17content_security_policy\x18\x05 \x01(\tR\x15contentSecurityPolicy\"\xd2\x01\n" +
 	"\vClientEvent\x12@\n" +
 	"\n" +
 	"event_type\x18\x01 \x01(\x0e2!.captcha.v1.ClientEvent.EventTypeR\teventType\x12!\n" +
@@ -501,25 +854,50 @@ const file_captcha_v1_proto_rawDesc = "" +
 	"\tEventType\x12\x12\n" +
 	"\x0eFRONTEND_EVENT\x10\x00\x12\x15\n" +
 	"\x11CONNECTION_CLOSED\x10\x01\x12\x12\n" +
-	"\x0eBALANCER_EVENT\x10\x02\"\xe1\x03\n" +
+	"\x0eBALANCER_EVENT\x10\x02\"\x84\x06\n" +
 	"\vServerEvent\x12A\n" +
 	"\x06result\x18\x01 \x01(\v2'.captcha.v1.ServerEvent.ChallengeResultH\x00R\x06result\x12B\n" +
 	"\tclient_js\x18\x02 \x01(\v2#.captcha.v1.ServerEvent.RunClientJSH\x00R\bclientJs\x12I\n" +
 	"\vclient_data\x18\x03 \x01(\v2&.captcha.v1.ServerEvent.SendClientDataH\x00R\n" +
-	"clientData\x1ac\n" +
+	"clientData\x129\n" +
+	"\x05stage\x18\x04 \x01(\v2!.captcha.v1.ServerEvent.NextStageH\x00R\x05stage\x1ay\n" +
 	"\x0fChallengeResult\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12-\n" +
-	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x1aI\n" +
+	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x12\x14\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\x1aI\n" +
 	"\vRunClientJS\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x17\n" +
 	"\ajs_code\x18\x02 \x01(\tR\x06jsCode\x1aG\n" +
 	"\x0eSendClientData\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\fR\x04dataB\a\n" +
-	"\x05event2\xaa\x01\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x1a\xcf\x01\n" +
+	"\tNextStage\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html\x12%\n" +
+	"\x0echallenge_type\x18\x03 \x01(\tR\rchallengeType\x126\n" +
+	"\x17content_security_policy\x18\x04 \x01(\tR\x15contentSecurityPolicy\x12\x14\n" +
+	"\x05stage\x18\x05 \x01(\x05R\x05stage\x12\x16\n" +
+	"\x06stages\x18\x06 \x01(\x05R\x06stagesB\a\n" +
+	"\x05event\"\x91\x01\n" +
+	"\x12VerifyTokenRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x1a\n" +
+	"\bhostname\x18\x02 \x01(\tR\bhostname\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x19\n" +
+	"\bsite_key\x18\x04 \x01(\tR\asiteKey\x12\x16\n" +
+	"\x06secret\x18\x05 \x01(\tR\x06secret\"\xd8\x01\n" +
+	"\x13VerifyTokenResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x05R\x05score\x12!\n" +
+	"\fchallenge_id\x18\x03 \x01(\tR\vchallengeId\x12\x1a\n" +
+	"\bhostname\x18\x04 \x01(\tR\bhostname\x12\x16\n" +
+	"\x06action\x18\x05 \x01(\tR\x06action\x12\x1b\n" +
+	"\tissued_at\x18\x06 \x01(\x03R\bissuedAt\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\a \x01(\tR\terrorCode2\xfa\x01\n" +
 	"\x0eCaptchaService\x12M\n" +
 	"\fNewChallenge\x12\x1c.captcha.v1.ChallengeRequest\x1a\x1d.captcha.v1.ChallengeResponse\"\x00\x12I\n" +
-	"\x0fMakeEventStream\x12\x17.captcha.v1.ClientEvent\x1a\x17.captcha.v1.ServerEvent\"\x00(\x010\x01B\x11Z\x0f./pb/captcha/v1b\x06proto3"
+	"\x0fMakeEventStream\x12\x17.captcha.v1.ClientEvent\x1a\x17.captcha.v1.ServerEvent\"\x00(\x010\x01\x12N\n" +
+	"\vVerifyToken\x12\x1e.captcha.v1.VerifyTokenRequest\x1a\x1f.captcha.v1.VerifyTokenResponseB\x11Z\x0f./pb/captcha/v1b\x06proto3"
 
 var (
 	file_captcha_v1_proto_rawDescOnce sync.Once
@@ -534,31 +912,37 @@ func file_captcha_v1_proto_rawDescGZIP() []byte {
 }
 
 var file_captcha_v1_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_captcha_v1_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_captcha_v1_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_captcha_v1_proto_goTypes = []any{
 	(ClientEvent_EventType)(0),          // 0: captcha.v1.ClientEvent.EventType
 	(*ChallengeRequest)(nil),            // 1: captcha.v1.ChallengeRequest
 	(*ChallengeResponse)(nil),           // 2: captcha.v1.ChallengeResponse
 	(*ClientEvent)(nil),                 // 3: captcha.v1.ClientEvent
 	(*ServerEvent)(nil),                 // 4: captcha.v1.ServerEvent
-	(*ServerEvent_ChallengeResult)(nil), // 5: captcha.v1.ServerEvent.ChallengeResult
-	(*ServerEvent_RunClientJS)(nil),     // 6: captcha.v1.ServerEvent.RunClientJS
-	(*ServerEvent_SendClientData)(nil),  // 7: captcha.v1.ServerEvent.SendClientData
+	(*VerifyTokenRequest)(nil),          // 5: captcha.v1.VerifyTokenRequest
+	(*VerifyTokenResponse)(nil),         // 6: captcha.v1.VerifyTokenResponse
+	(*ServerEvent_ChallengeResult)(nil), // 7: captcha.v1.ServerEvent.ChallengeResult
+	(*ServerEvent_RunClientJS)(nil),     // 8: captcha.v1.ServerEvent.RunClientJS
+	(*ServerEvent_SendClientData)(nil),  // 9: captcha.v1.ServerEvent.SendClientData
+	(*ServerEvent_NextStage)(nil),       // 10: captcha.v1.ServerEvent.NextStage
 }
 var file_captcha_v1_proto_depIdxs = []int32{
-	0, // 0: captcha.v1.ClientEvent.event_type:type_name -> captcha.v1.ClientEvent.EventType
-	5, // 1: captcha.v1.ServerEvent.result:type_name -> captcha.v1.ServerEvent.ChallengeResult
-	6, // 2: captcha.v1.ServerEvent.client_js:type_name -> captcha.v1.ServerEvent.RunClientJS
-	7, // 3: captcha.v1.ServerEvent.client_data:type_name -> captcha.v1.ServerEvent.SendClientData
-	1, // 4: captcha.v1.CaptchaService.NewChallenge:input_type -> captcha.v1.ChallengeRequest
-	3, // 5: captcha.v1.CaptchaService.MakeEventStream:input_type -> captcha.v1.ClientEvent
-	2, // 6: captcha.v1.CaptchaService.NewChallenge:output_type -> captcha.v1.ChallengeResponse
-	4, // 7: captcha.v1.CaptchaService.MakeEventStream:output_type -> captcha.v1.ServerEvent
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	0,  // 0: captcha.v1.ClientEvent.event_type:type_name -> captcha.v1.ClientEvent.EventType
+	7,  // 1: captcha.v1.ServerEvent.result:type_name -> captcha.v1.ServerEvent.ChallengeResult
+	8,  // 2: captcha.v1.ServerEvent.client_js:type_name -> captcha.v1.ServerEvent.RunClientJS
+	9,  // 3: captcha.v1.ServerEvent.client_data:type_name -> captcha.v1.ServerEvent.SendClientData
+	10, // 4: captcha.v1.ServerEvent.stage:type_name -> captcha.v1.ServerEvent.NextStage
+	1,  // 5: captcha.v1.CaptchaService.NewChallenge:input_type -> captcha.v1.ChallengeRequest
+	3,  // 6: captcha.v1.CaptchaService.MakeEventStream:input_type -> captcha.v1.ClientEvent
+	5,  // 7: captcha.v1.CaptchaService.VerifyToken:input_type -> captcha.v1.VerifyTokenRequest
+	2,  // 8: captcha.v1.CaptchaService.NewChallenge:output_type -> captcha.v1.ChallengeResponse
+	4,  // 9: captcha.v1.CaptchaService.MakeEventStream:output_type -> captcha.v1.ServerEvent
+	6,  // 10: captcha.v1.CaptchaService.VerifyToken:output_type -> captcha.v1.VerifyTokenResponse
+	8,  // [8:11] is the sub-list for method output_type
+	5,  // [5:8] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_captcha_v1_proto_init() }
@@ -570,6 +954,7 @@ func file_captcha_v1_proto_init() {
 		(*ServerEvent_Result)(nil),
 		(*ServerEvent_ClientJs)(nil),
 		(*ServerEvent_ClientData)(nil),
+		(*ServerEvent_Stage)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -577,7 +962,7 @@ func file_captcha_v1_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_captcha_v1_proto_rawDesc), len(file_captcha_v1_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   7,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	CaptchaService_NewChallenge_FullMethodName    = "/captcha.v1.CaptchaService/NewChallenge"
 	CaptchaService_MakeEventStream_FullMethodName = "/captcha.v1.CaptchaService/MakeEventStream"
+	CaptchaService_VerifyToken_FullMethodName     = "/captcha.v1.CaptchaService/VerifyToken"
 )
 
 // CaptchaServiceClient is the client API for CaptchaService service.
@@ -29,6 +30,7 @@ const (
 type CaptchaServiceClient interface {
 	NewChallenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeResponse, error)
 	MakeEventStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ClientEvent, ServerEvent], error)
+	VerifyToken(ctx context.Context, in *VerifyTokenRequest, opts ...grpc.CallOption) (*VerifyTokenResponse, error)
 }
 
 type captchaServiceClient struct {
@@ -62,12 +64,23 @@ func (c *captchaServiceClient) MakeEventStream(ctx context.Context, opts ...grpc
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type CaptchaService_MakeEventStreamClient = grpc.BidiStreamingClient[ClientEvent, ServerEvent]
 
+func (c *captchaServiceClient) VerifyToken(ctx context.Context, in *VerifyTokenRequest, opts ...grpc.CallOption) (*VerifyTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyTokenResponse)
+	err := c.cc.Invoke(ctx, CaptchaService_VerifyToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CaptchaServiceServer is the server API for CaptchaService service.
 // All implementations must embed UnimplementedCaptchaServiceServer
 // for forward compatibility.
 type CaptchaServiceServer interface {
 	NewChallenge(context.Context, *ChallengeRequest) (*ChallengeResponse, error)
 	MakeEventStream(grpc.BidiStreamingServer[ClientEvent, ServerEvent]) error
+	VerifyToken(context.Context, *VerifyTokenRequest) (*VerifyTokenResponse, error)
 	mustEmbedUnimplementedCaptchaServiceServer()
 }
 
@@ -84,6 +97,9 @@ func (UnimplementedCaptchaServiceServer) NewChallenge(context.Context, *Challeng
 func (UnimplementedCaptchaServiceServer) MakeEventStream(grpc.BidiStreamingServer[ClientEvent, ServerEvent]) error {
 	return status.Errorf(codes.Unimplemented, "method MakeEventStream not implemented")
 }
+func (UnimplementedCaptchaServiceServer) VerifyToken(context.Context, *VerifyTokenRequest) (*VerifyTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyToken not implemented")
+}
 func (UnimplementedCaptchaServiceServer) mustEmbedUnimplementedCaptchaServiceServer() {}
 func (UnimplementedCaptchaServiceServer) testEmbeddedByValue()                        {}
 
@@ -130,6 +146,24 @@ func _CaptchaService_MakeEventStream_Handler(srv interface{}, stream grpc.Server
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type CaptchaService_MakeEventStreamServer = grpc.BidiStreamingServer[ClientEvent, ServerEvent]
 
+func _CaptchaService_VerifyToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaServiceServer).VerifyToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaService_VerifyToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaServiceServer).VerifyToken(ctx, req.(*VerifyTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CaptchaService_ServiceDesc is the grpc.ServiceDesc for CaptchaService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -141,6 +175,10 @@ var CaptchaService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "NewChallenge",
 			Handler:    _CaptchaService_NewChallenge_Handler,
 		},
+		{
+			MethodName: "VerifyToken",
+			Handler:    _CaptchaService_VerifyToken_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{